@@ -0,0 +1,58 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// loadBaselineCoverage sets the bits in the coverage mask written to
+// opts.BaselineCoveragePath by an earlier run, so coverage found then isn't
+// reported as new. The mask must have as many counters as the test binary.
+func (c *coordinator) loadBaselineCoverage() error {
+	path := c.opts.BaselineCoveragePath
+	if c.coverageMask == nil {
+		return errors.New("BaselineCoveragePath is set, but the test binary was not built with coverage instrumentation")
+	}
+	mask, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading baseline coverage: %v", err)
+	}
+	if len(mask) != len(c.coverageMask) {
+		return fmt.Errorf("baseline coverage in %s has %d counters, but the test binary has %d; it was probably written for a different version of the code", path, len(mask), len(c.coverageMask))
+	}
+	c.updateCoverage(mask)
+	c.logf(LogInfo, "fuzz: loaded %d coverage bits from %s; only coverage beyond them is new\n", countBits(mask), path)
+	return nil
+}
+
+// writeCoverage writes the coordinator's coverage mask to
+// opts.WriteCoveragePath, so a later run can start from it with
+// opts.BaselineCoveragePath. The mask is written to a temporary file first,
+// then renamed, so an existing file is only replaced by a complete mask.
+func (c *coordinator) writeCoverage() error {
+	if c.coverageMask == nil {
+		return nil
+	}
+	path := c.opts.WriteCoveragePath
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(c.coverageMask); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,46 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBaselineCoverage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "coverage")
+	c := &coordinator{
+		opts:         CoordinateFuzzingOpts{Log: io.Discard, WriteCoveragePath: path},
+		coverageMask: []byte{1, 0, 3, 0},
+	}
+	if err := c.writeCoverage(); err != nil {
+		t.Fatal(err)
+	}
+
+	c = &coordinator{
+		opts:         CoordinateFuzzingOpts{Log: io.Discard, BaselineCoveragePath: path},
+		coverageMask: make([]byte, 4),
+	}
+	if err := c.loadBaselineCoverage(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{1, 0, 3, 0}; !bytes.Equal(c.coverageMask, want) {
+		t.Errorf("got coverage mask %v; want %v", c.coverageMask, want)
+	}
+	if n := c.updateCoverage([]byte{1, 0, 4, 0}); n != 1 {
+		t.Errorf("got %d new bits beyond the baseline; want 1", n)
+	}
+
+	if err := os.WriteFile(path, []byte{1, 2}, 0666); err != nil {
+		t.Fatal(err)
+	}
+	c.coverageMask = make([]byte, 4)
+	if err := c.loadBaselineCoverage(); err == nil {
+		t.Error("loaded a baseline with the wrong number of counters")
+	}
+}
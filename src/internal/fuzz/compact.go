@@ -0,0 +1,99 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"sort"
+	"time"
+)
+
+// compactPeriodically compacts the corpus if opts.CompactInterval has passed
+// since it was last compacted. It's called periodically. Errors are logged
+// once, and compacting is retried the next time.
+func (c *coordinator) compactPeriodically() {
+	if c.opts.CompactInterval <= 0 || c.coverageMask == nil || c.warmupRun() {
+		return
+	}
+	if c.lastCompact.IsZero() {
+		c.lastCompact = c.startTime
+	}
+	if time.Since(c.lastCompact) < c.opts.CompactInterval {
+		return
+	}
+	c.lastCompact = time.Now()
+	if err := c.compactCorpus(); err != nil && !c.warned["compact"] {
+		c.logf(LogWarn, "warning: compacting the corpus: %v\n", err)
+		c.warned["compact"] = true
+	}
+}
+
+// compactCorpus removes the entries chosen by chooseRedundant from the corpus
+// and from opts.CacheDir.
+func (c *coordinator) compactCorpus() error {
+	total := len(c.corpus.entries)
+	redundant := c.chooseRedundant()
+	for _, path := range redundant {
+		if err := c.evictCorpusEntry(path, "while compacting: other entries have all its coverage"); err != nil {
+			return err
+		}
+		c.compactedEntries++
+	}
+	if len(redundant) > 0 {
+		c.logf(LogInfo, "fuzz: compacted corpus: removed %d of %d entries whose coverage other entries have\n", len(redundant), total)
+	}
+	return nil
+}
+
+// chooseRedundant returns the paths of the corpus entries in opts.CacheDir
+// that can be removed without losing coverage. It works on a snapshot of the
+// corpus: entries are considered in order of how many coverage bits they
+// have, most first, then smallest first, and an entry is redundant if the
+// entries kept before it, along with seeds and entries that can't be
+// removed, already have all of its bits. Entries whose coverage isn't known
+// are kept.
+func (c *coordinator) chooseRedundant() []string {
+	type candidate struct {
+		path string
+		cov  []byte
+		bits int
+		size int64
+	}
+	var covered []byte
+	var candidates []candidate
+	for _, e := range c.corpus.entries {
+		cov, ok := c.entryCoverage[e.Path]
+		if !ok {
+			continue
+		}
+		if covered == nil {
+			covered = make([]byte, len(cov))
+		}
+		if e.IsSeed || c.entrySize[e.Path] == 0 {
+			for i := range cov {
+				covered[i] |= cov[i]
+			}
+			continue
+		}
+		candidates = append(candidates, candidate{e.Path, cov, countBits(cov), c.entrySize[e.Path]})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].bits != candidates[j].bits {
+			return candidates[i].bits > candidates[j].bits
+		}
+		return candidates[i].size < candidates[j].size
+	})
+
+	var redundant []string
+	for _, cand := range candidates {
+		if countNewCoverageBits(covered, cand.cov) == 0 {
+			redundant = append(redundant, cand.path)
+			continue
+		}
+		for i := range cand.cov {
+			covered[i] |= cand.cov[i]
+		}
+	}
+	return redundant
+}
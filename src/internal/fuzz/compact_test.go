@@ -0,0 +1,100 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChooseRedundant(t *testing.T) {
+	c := &coordinator{
+		entryCoverage: map[string][]byte{
+			"seed":    {1, 0, 0, 0},
+			"big":     {1, 1, 1, 0},
+			"small":   {0, 1, 1, 0},
+			"same":    {1, 1, 1, 0},
+			"unique":  {0, 0, 0, 1},
+			"covered": {1, 0, 0, 0},
+		},
+		entrySize: map[string]int64{"big": 10, "small": 5, "same": 20, "unique": 10, "covered": 10},
+	}
+	c.corpus.entries = []CorpusEntry{
+		{Path: "seed", IsSeed: true},
+		{Path: "big"},
+		{Path: "small"},
+		{Path: "same"},
+		{Path: "unique"},
+		{Path: "covered"},
+		{Path: "unknown"},
+	}
+	// "same" has the same coverage as "big" but is larger, so "big" is kept.
+	want := []string{"same", "small", "covered"}
+	if got := c.chooseRedundant(); !reflect.DeepEqual(got, want) {
+		t.Errorf("chooseRedundant() = %v; want %v", got, want)
+	}
+}
+
+func TestCompactCorpus(t *testing.T) {
+	dir := t.TempDir()
+	var entries []CorpusEntry
+	for _, name := range []string{"a", "b"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, marshalCorpusFile([]byte(name)), 0666); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, CorpusEntry{Path: path})
+	}
+	c := &coordinator{
+		opts:          CoordinateFuzzingOpts{CacheDir: dir, CompactInterval: 1, Log: io.Discard},
+		entryCoverage: map[string][]byte{entries[0].Path: {1, 1}, entries[1].Path: {0, 1}},
+		entrySize:     map[string]int64{entries[0].Path: 10, entries[1].Path: 10},
+	}
+	c.corpus.entries = entries
+	if err := c.compactCorpus(); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.corpus.entries) != 1 || c.corpus.entries[0].Path != entries[0].Path || c.compactedEntries != 1 {
+		t.Errorf("got corpus %v after removing %d entries; want only %s", c.corpus.entries, c.compactedEntries, entries[0].Path)
+	}
+	if _, err := os.Stat(entries[1].Path); !os.IsNotExist(err) {
+		t.Errorf("redundant entry still in the cache: %v", err)
+	}
+}
+
+func TestCompactLoadedEntry(t *testing.T) {
+	// An entry in the cache from a previous run has the same coverage as the
+	// seed, so compacting removes it. Compacting is checked every 3s.
+	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+	defer cancel()
+	cacheDir := t.TempDir()
+	loaded := filepath.Join(cacheDir, "loaded")
+	if err := os.WriteFile(loaded, marshalCorpusFile([]byte("aa")), 0666); err != nil {
+		t.Fatal(err)
+	}
+	var sum Summary
+	err := coordinateForTestContext(ctx, t, "cover", CoordinateFuzzingOpts{
+		CacheDir:        cacheDir,
+		CompactInterval: time.Nanosecond,
+		Seed: []CorpusEntry{
+			{Path: "seed#0", Data: marshalCorpusFile([]byte("a")), Values: []interface{}{[]byte("a")}, IsSeed: true},
+		},
+		OnFinish: func(s Summary) { sum = s },
+	})
+	if err != nil && err != ctx.Err() {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(loaded); !os.IsNotExist(err) {
+		t.Errorf("entry loaded from the cache wasn't compacted: %v", err)
+	}
+	if sum.CompactedEntries == 0 {
+		t.Error("got CompactedEntries 0; want the loaded entry counted")
+	}
+}
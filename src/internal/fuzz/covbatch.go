@@ -0,0 +1,89 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"sort"
+	"time"
+)
+
+// defaultCoverageDedupWindow is the default for
+// CoordinateFuzzingOpts.CoverageDedupWindow with more than one worker. It's
+// short enough not to delay fuzzing noticeably, and long enough to catch
+// workers that found the same coverage at about the same time.
+const defaultCoverageDedupWindow = 50 * time.Millisecond
+
+// coverageDedupWindow returns how long results reporting new coverage are
+// held before they're handled, or 0 if they're handled right away. By
+// default, results are only held with more than one worker: a single worker
+// can't report the same coverage twice at about the same time.
+func (c *coordinator) coverageDedupWindow() time.Duration {
+	switch w := c.opts.CoverageDedupWindow; {
+	case w < 0:
+		return 0
+	case w == 0:
+		if c.opts.Parallel > 1 {
+			return defaultCoverageDedupWindow
+		}
+		return 0
+	default:
+		return w
+	}
+}
+
+// batchCoverage adds a result reporting new coverage to the batch handled by
+// processCoverageBatch once the dedup window, which starts with the first
+// result in the batch, has passed.
+func (c *coordinator) batchCoverage(result fuzzResult) {
+	if len(c.coverageBatch) == 0 {
+		c.coverageBatchTimer = time.NewTimer(c.coverageDedupWindow())
+	}
+	c.coverageBatch = append(c.coverageBatch, result)
+}
+
+// coverageBatchC returns a channel that receives when the current batch of
+// results should be processed, or nil if there's no batch.
+func (c *coordinator) coverageBatchC() <-chan time.Time {
+	if len(c.coverageBatch) == 0 {
+		return nil
+	}
+	return c.coverageBatchTimer.C
+}
+
+// processCoverageBatch handles the batched results with handleCoverage,
+// smallest value first, skipping those whose new coverage bits are all
+// reported by results handled before them, which would only repeat their
+// work. With minimize false, the values aren't minimized, because fuzzing is
+// stopping. processCoverageBatch returns the first error handleCoverage
+// returns.
+func (c *coordinator) processCoverageBatch(minimize bool) error {
+	batch := c.coverageBatch
+	c.coverageBatch = nil
+	if c.coverageBatchTimer != nil {
+		c.coverageBatchTimer.Stop()
+		c.coverageBatchTimer = nil
+	}
+	sort.SliceStable(batch, func(i, j int) bool {
+		return len(batch[i].entry.Data) < len(batch[j].entry.Data)
+	})
+	claimed := append([]byte(nil), c.coverageMask...)
+	var firstErr error
+	for _, result := range batch {
+		if diffCoverage(claimed, result.coverageData) == nil {
+			c.dedupedCoverage++
+			continue
+		}
+		for i := range claimed {
+			claimed[i] |= result.coverageData[i]
+		}
+		if !minimize {
+			result.canMinimize = false
+		}
+		if err := c.handleCoverage(result); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestProcessCoverageBatch(t *testing.T) {
+	c := &coordinator{
+		opts:         CoordinateFuzzingOpts{Log: io.Discard, CoverageDedupWindow: -1},
+		coverageMask: []byte{1, 0, 0},
+	}
+	for _, r := range []struct {
+		data string
+		cov  []byte
+	}{
+		{"large input", []byte{1, 1, 0}},
+		{"small", []byte{0, 1, 0}},
+		{"other", []byte{0, 1, 1}},
+		{"old", []byte{1, 0, 0}},
+	} {
+		c.batchCoverage(fuzzResult{entry: CorpusEntry{Data: []byte(r.data)}, coverageData: r.cov})
+	}
+	if c.coverageBatchC() == nil {
+		t.Fatal("no timer for the batch")
+	}
+	if err := c.processCoverageBatch(false); err != nil {
+		t.Fatal(err)
+	}
+	// "small" is handled first; "large input" only adds the bit "small"
+	// added, and "old" adds nothing, so only "other" is kept besides it.
+	if c.dedupedCoverage != 2 {
+		t.Errorf("deduped %d results; want 2", c.dedupedCoverage)
+	}
+	if len(c.corpus.entries) != 2 || string(c.corpus.entries[0].Data) != "small" || string(c.corpus.entries[1].Data) != "other" {
+		t.Errorf("got corpus %v; want small and other", c.corpus.entries)
+	}
+	if c.coverageBatchC() != nil {
+		t.Error("batch still pending after processing")
+	}
+}
+
+func TestCoverageDedupWindow(t *testing.T) {
+	for _, tc := range []struct {
+		window   time.Duration
+		parallel int
+		want     time.Duration
+	}{
+		{0, 1, 0},
+		{0, 4, defaultCoverageDedupWindow},
+		{-1, 4, 0},
+		{time.Second, 1, time.Second},
+	} {
+		c := &coordinator{opts: CoordinateFuzzingOpts{CoverageDedupWindow: tc.window, Parallel: tc.parallel}}
+		if got := c.coverageDedupWindow(); got != tc.want {
+			t.Errorf("CoverageDedupWindow %v with %d workers: got window %v; want %v", tc.window, tc.parallel, got, tc.want)
+		}
+	}
+}
@@ -5,32 +5,87 @@
 package fuzz
 
 import (
+	"errors"
 	"fmt"
 	"math/bits"
 )
 
 // ResetCovereage sets all of the counters for each edge of the instrumented
 // source code to 0.
+// With a FeedbackSource other than edge coverage selected, ResetCoverage
+// resets that source instead.
 func ResetCoverage() {
-	cov := coverage()
-	for i := range cov {
-		cov[i] = 0
-	}
+	activeFeedback.Reset()
 }
 
 // SnapshotCoverage copies the current counter values into coverageSnapshot,
-// preserving them for later inspection. SnapshotCoverage also rounds each
-// counter down to the nearest power of two. This lets the coordinator store
-// multiple values for each counter by OR'ing them together.
+// preserving them for later inspection. SnapshotCoverage also replaces each
+// counter with a single bit for the range of hit counts it's in; see
+// counterBuckets. This lets the coordinator store multiple values for each
+// counter by OR'ing them together, so an input that runs a block a new number
+// of times, like a loop body run many more times, counts as new coverage.
+// With a FeedbackSource other than edge coverage selected, SnapshotCoverage
+// copies that source's bitmap instead.
 func SnapshotCoverage() {
-	cov := coverage()
-	for i, b := range cov {
-		b |= b >> 1
-		b |= b >> 2
-		b |= b >> 4
-		b -= b >> 1
-		coverageSnapshot[i] = b
+	activeFeedback.Snapshot(coverageSnapshot)
+}
+
+// counterBuckets maps each counter value to a bit for its bucket of hit
+// counts: 1, 2, 3, 4-7, 8-15, 16-31, 32-127, and 128-255, the same buckets
+// libFuzzer uses. The buckets are narrow for small counts, which distinguish
+// paths through a block, and wide for large ones, which mostly distinguish
+// the sizes of inputs.
+var counterBuckets = func() (b [256]byte) {
+	for v := 1; v < len(b); v++ {
+		switch {
+		case v <= 3:
+			b[v] = 1 << (v - 1)
+		case v <= 7:
+			b[v] = 1 << 3
+		case v <= 15:
+			b[v] = 1 << 4
+		case v <= 31:
+			b[v] = 1 << 5
+		case v <= 127:
+			b[v] = 1 << 6
+		default:
+			b[v] = 1 << 7
+		}
+	}
+	return b
+}()
+
+// CoverageFor runs fn once with the input encoded in data, in the current
+// process, and returns the coverage snapshot for it, in the format produced
+// by SnapshotCoverage. It's a building block for tools that analyze a corpus,
+// one input at a time. If fn returns an error, CoverageFor returns the
+// coverage along with the error.
+//
+// The coverage counters and snapshot are restored afterward, so CoverageFor
+// doesn't change the coverage a later call to the fuzz function reports, but
+// it must not be called while the fuzz function runs in the same process.
+// CoverageFor returns an error if the binary wasn't built with coverage
+// instrumentation.
+func CoverageFor(fn func(CorpusEntry) error, data []byte) ([]byte, error) {
+	if !coverageEnabled {
+		return nil, errors.New("coverage instrumentation is not enabled")
 	}
+	vals, err := unmarshalCorpusFile(data)
+	if err != nil {
+		return nil, err
+	}
+	cov := coverage()
+	savedCounters := append([]byte(nil), cov...)
+	savedSnapshot := append([]byte(nil), coverageSnapshot...)
+	defer func() {
+		copy(cov, savedCounters)
+		copy(coverageSnapshot, savedSnapshot)
+	}()
+
+	ResetCoverage()
+	err = fn(CorpusEntry{Data: data, Values: vals})
+	SnapshotCoverage()
+	return append([]byte(nil), coverageSnapshot...), err
 }
 
 // diffCoverage returns a set of bits set in snapshot but not in base.
@@ -77,6 +132,18 @@ func hasCoverageBit(base, snapshot []byte) bool {
 	return false
 }
 
+// coverageIndexes returns the indexes of the counters in cov that have at
+// least one bit set.
+func coverageIndexes(cov []byte) []int {
+	var indexes []int
+	for i, c := range cov {
+		if c != 0 {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
 func countBits(cov []byte) int {
 	n := 0
 	for _, c := range cov {
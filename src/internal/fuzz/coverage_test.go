@@ -0,0 +1,59 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import "testing"
+
+func TestCoverageFor(t *testing.T) {
+	called := false
+	fn := func(e CorpusEntry) error {
+		called = true
+		if got := string(e.Values[0].([]byte)); got != "abc" {
+			t.Errorf("fn called with %q; want %q", got, "abc")
+		}
+		return nil
+	}
+	cov, err := CoverageFor(fn, marshalCorpusFile([]byte("abc")))
+	if !coverageEnabled {
+		if err == nil || called {
+			t.Errorf("CoverageFor succeeded without coverage instrumentation")
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !called || len(cov) != len(coverageSnapshot) {
+		t.Errorf("got %d bytes of coverage, called = %v; want %d bytes, true", len(cov), called, len(coverageSnapshot))
+	}
+	if _, err := CoverageFor(fn, []byte("not a corpus file")); err == nil {
+		t.Error("CoverageFor succeeded with a malformed input")
+	}
+}
+
+func TestCounterBuckets(t *testing.T) {
+	for _, tc := range []struct {
+		count int
+		want  byte
+	}{
+		{0, 0},
+		{1, 1},
+		{2, 2},
+		{3, 4},
+		{4, 8},
+		{7, 8},
+		{8, 16},
+		{16, 32},
+		{31, 32},
+		{32, 64},
+		{127, 64},
+		{128, 128},
+		{255, 128},
+	} {
+		if got := counterBuckets[tc.count]; got != tc.want {
+			t.Errorf("counterBuckets[%d] = %#x; want %#x", tc.count, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+// runCrashHook calls opts.OnCrash for a crasher that was just written, on a
+// new goroutine so fuzzing doesn't wait for it. Crashers with the same stack
+// signature as one found earlier in the run, or as one already in
+// opts.CorpusDir, are probably caused by the same bug, so the hook is only
+// called for the first. Errors from the hook are logged.
+func (c *coordinator) runCrashHook(result *fuzzResult) {
+	if c.opts.OnCrash == nil {
+		return
+	}
+	if sig := result.signature; sig != "" {
+		if c.hookedSignatures[sig] || c.sameSignatureCrasher(result.entry.Path, sig) != "" {
+			return
+		}
+		if c.hookedSignatures == nil {
+			c.hookedSignatures = make(map[string]bool)
+		}
+		c.hookedSignatures[sig] = true
+	}
+	entry := CorpusEntry{Path: result.entry.Path, Data: result.entry.Data}
+	errMsg := result.crasherMsg
+	c.crashHooks.Add(1)
+	go func() {
+		defer c.crashHooks.Done()
+		if err := c.opts.OnCrash(entry, errMsg); err != nil {
+			c.logf(LogWarn, "warning: OnCrash for %s: %v\n", entry.Path, err)
+		}
+	}()
+}
@@ -0,0 +1,53 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRunCrashHook(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	var log bytes.Buffer
+	c := &coordinator{opts: CoordinateFuzzingOpts{
+		Log: &log,
+		OnCrash: func(entry CorpusEntry, errMsg string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, entry.Path+": "+errMsg)
+			if entry.Path == "c" {
+				return errors.New("tracker unavailable")
+			}
+			return nil
+		},
+	}}
+	for _, r := range []fuzzResult{
+		{entry: CorpusEntry{Path: "a"}, crasherMsg: "panic: a", signature: "sig1"},
+		{entry: CorpusEntry{Path: "b"}, crasherMsg: "panic: b", signature: "sig1"},
+		{entry: CorpusEntry{Path: "c"}, crasherMsg: "panic: c", signature: "sig2"},
+		{entry: CorpusEntry{Path: "d"}, crasherMsg: "panic: d"},
+		{entry: CorpusEntry{Path: "e"}, crasherMsg: "panic: e"},
+	} {
+		c.runCrashHook(&r)
+	}
+	c.crashHooks.Wait()
+
+	// Crashers without a signature can't be compared, so each is reported.
+	want := []string{"a: panic: a", "c: panic: c", "d: panic: d", "e: panic: e"}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("OnCrash called for %q; want %q", got, want)
+	}
+	if !strings.Contains(log.String(), "OnCrash for c: tracker unavailable") {
+		t.Errorf("error from OnCrash not logged; log:\n%s", log.String())
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"sort"
+	"strings"
+)
+
+// crashSignatureFrames is the number of stack frames, innermost first, that
+// make up a crash signature. Frames further out mostly show how the fuzz
+// function was reached, which is the same for every crash.
+const crashSignatureFrames = 5
+
+// crashSignature returns a signature of the stack trace in msg, the error
+// reported for a crasher or the output of a worker process that terminated,
+// so that crashes caused by the same bug have the same signature. It's made
+// of the names of the innermost functions of the first goroutine in the
+// trace, leaving out the runtime, the testing package, and this package.
+// Argument values, program counter offsets, file paths and goroutine numbers
+// are left out, since they change from one crash to the next.
+// crashSignature returns "" if msg contains no stack trace.
+func crashSignature(msg string) string {
+	lines := strings.Split(msg, "\n")
+	for i, line := range lines {
+		// The testing package indents the trace of a panic in the fuzz
+		// function along with the rest of the test's output.
+		lines[i] = strings.TrimLeft(line, " ")
+	}
+	var funcs []string
+	inTrace := false
+	for i := 0; i+1 < len(lines) && len(funcs) < crashSignatureFrames; i++ {
+		line := lines[i]
+		if strings.HasPrefix(line, "goroutine ") && strings.HasSuffix(line, ":") {
+			if inTrace {
+				// Only the first goroutine's trace is used.
+				break
+			}
+			inTrace = true
+			continue
+		}
+		// A frame is a function call followed by an indented file position.
+		if !inTrace || !strings.HasPrefix(lines[i+1], "\t") || !strings.Contains(lines[i+1], ".go:") {
+			continue
+		}
+		if strings.HasPrefix(line, "created by ") {
+			continue
+		}
+		name := line
+		if j := strings.LastIndex(name, "("); j > 0 {
+			name = name[:j]
+		}
+		if !isSignatureFunc(name) {
+			continue
+		}
+		funcs = append(funcs, name)
+	}
+	return strings.Join(funcs, ";")
+}
+
+// isSignatureFunc reports whether the function name, as printed in a stack
+// trace, can be part of a crash signature.
+func isSignatureFunc(name string) bool {
+	if name == "panic" {
+		return false
+	}
+	for _, prefix := range []string{"runtime.", "runtime/", "testing.", "internal/fuzz.", "reflect."} {
+		if strings.HasPrefix(name, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// CrasherGroup is a set of crashers with the same stack signature, which are
+// probably caused by the same bug.
+type CrasherGroup struct {
+	// Signature is the signature the crashers share. It's empty for a group
+	// of one crasher whose error had no stack trace.
+	Signature string
+
+	// Crashers are the crashers in the group, in the order they were found.
+	// The first one is the group's representative: the first crash found for
+	// a bug is often the simplest.
+	Crashers []Crasher
+}
+
+// GroupCrashers groups crashers, for example, those returned by
+// ListCrashers, by their stack signatures, so each bug can be triaged once.
+// Groups are ordered by when their first crasher was found. Crashers without
+// a signature each form a group of their own.
+func GroupCrashers(crashers []Crasher) []CrasherGroup {
+	sorted := append([]Crasher(nil), crashers...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Found.Before(sorted[j].Found)
+	})
+	var groups []CrasherGroup
+	index := make(map[string]int)
+	for _, cr := range sorted {
+		if i, ok := index[cr.Signature]; ok && cr.Signature != "" {
+			groups[i].Crashers = append(groups[i].Crashers, cr)
+			continue
+		}
+		index[cr.Signature] = len(groups)
+		groups = append(groups, CrasherGroup{Signature: cr.Signature, Crashers: []Crasher{cr}})
+	}
+	return groups
+}
+
+// sameSignatureCrasher returns the path of a crasher in opts.CorpusDir, other
+// than the one at path, with the stack signature sig, or "" if there's none.
+func (c *coordinator) sameSignatureCrasher(path, sig string) string {
+	if sig == "" || c.opts.CorpusDir == "" {
+		return ""
+	}
+	crashers, _ := readCrashers(c.opts.CorpusDir, c.opts.CacheDir)
+	for _, cr := range crashers {
+		if cr.Signature == sig && cr.Path != path {
+			return cr.Path
+		}
+	}
+	return ""
+}
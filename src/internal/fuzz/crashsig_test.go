@@ -0,0 +1,87 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCrashSignature(t *testing.T) {
+	// A panic in the fuzz function, as reported by the testing package.
+	panicMsg := func(goid, addr string) string {
+		return `    fuzz_test.go:12: panic: index out of range [3] with length 3
+        goroutine ` + goid + ` [running]:
+        runtime/debug.Stack()
+        	/go/src/runtime/debug/stack.go:24 +0x65
+        testing.tRunner.func1()
+        	/go/src/testing/testing.go:1287 +0x1d2
+        panic({0x5a1c40, ` + addr + `})
+        	/go/src/runtime/panic.go:1038 +0x215
+        example.com/p.(*decoder).next(` + addr + `, 0x3)
+        	/home/u/p/decode.go:40 +0x1b1
+        example.com/p.Decode({` + addr + `, 0x3, 0x3})
+        	/home/u/p/decode.go:12 +0x4e
+        example.com/p.FuzzDecode.func1(` + addr + `, {` + addr + `, 0x3, 0x3})
+        	/home/u/p/fuzz_test.go:9 +0x3d
+        reflect.Value.call({0x59a5a0, 0x5d8e48, 0x13}, {0x5c3a8c, 0x4}, {` + addr + `, 0x2, 0x2})
+        	/go/src/reflect/value.go:543 +0x814
+        testing.tRunner(` + addr + `, ` + addr + `)
+        	/go/src/testing/testing.go:1319 +0x102
+        created by testing.(*F).Fuzz.func1.1
+        	/go/src/testing/fuzz.go:413 +0x2d1
+`
+	}
+	const want = "example.com/p.(*decoder).next;example.com/p.Decode;example.com/p.FuzzDecode.func1"
+	a := crashSignature(panicMsg("7", "0xc0000b2000"))
+	b := crashSignature(panicMsg("31", "0xc000418a80"))
+	if a != want || b != want {
+		t.Errorf("got signatures %q and %q; want %q", a, b, want)
+	}
+
+	// Output of a worker process that terminated. Only the first goroutine
+	// is used.
+	output := `fatal error: stack overflow
+
+goroutine 19 [running]:
+example.com/p.walk(0xc000120000)
+	/home/u/p/walk.go:5 +0x45 fp=0xc020160398 sp=0xc020160390 pc=0x4f2c05
+
+goroutine 1 [chan receive]:
+example.com/p.other()
+	/home/u/p/other.go:1 +0x1
+`
+	if got, want := crashSignature(output), "example.com/p.walk"; got != want {
+		t.Errorf("got signature %q for process output; want %q", got, want)
+	}
+
+	if got := crashSignature("fuzz_test.go:10: bad value"); got != "" {
+		t.Errorf("got signature %q for an error without a trace; want none", got)
+	}
+}
+
+func TestGroupCrashers(t *testing.T) {
+	t0 := time.Date(2021, time.June, 1, 12, 0, 0, 0, time.UTC)
+	crashers := []Crasher{
+		{Path: "c", Signature: "p.f", Found: t0.Add(3 * time.Minute)},
+		{Path: "a", Signature: "p.f", Found: t0.Add(time.Minute)},
+		{Path: "b", Signature: "p.g", Found: t0.Add(2 * time.Minute)},
+		{Path: "d", Found: t0.Add(4 * time.Minute)},
+		{Path: "e", Found: t0.Add(5 * time.Minute)},
+	}
+	var got [][]string
+	for _, g := range GroupCrashers(crashers) {
+		var paths []string
+		for _, cr := range g.Crashers {
+			paths = append(paths, cr.Path)
+		}
+		got = append(got, paths)
+	}
+	want := [][]string{{"a", "c"}, {"b"}, {"d"}, {"e"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got groups %v; want %v", got, want)
+	}
+}
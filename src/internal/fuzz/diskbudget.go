@@ -0,0 +1,145 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// recordEntryCoverage records the coverage snapshot cov of the corpus entry
+// at path, before it's added to the coordinator's coverage, if an option
+// needs it: opts.SnapshotPath needs the number of new bits, and
+// opts.MaxCorpusDiskBytes and opts.CompactInterval need the snapshot itself.
+func (c *coordinator) recordEntryCoverage(path string, cov []byte) {
+	if cov == nil || c.coverageMask == nil {
+		return
+	}
+	if c.opts.SnapshotPath != "" || c.opts.MaxCorpusDiskBytes > 0 {
+		c.entryNewBits[path] = countNewCoverageBits(c.coverageMask, cov)
+	}
+	if c.opts.MaxCorpusDiskBytes > 0 || c.opts.CompactInterval > 0 {
+		c.entryCoverage[path] = cov
+	}
+}
+
+// loadCorpusDiskUsage records the size of each entry in opts.CacheDir, which
+// counts toward opts.MaxCorpusDiskBytes.
+func (c *coordinator) loadCorpusDiskUsage() error {
+	for _, e := range c.corpus.entries {
+		if e.IsSeed || filepath.Dir(e.Path) != filepath.Clean(c.opts.CacheDir) {
+			continue
+		}
+		fi, err := os.Stat(e.Path)
+		if err != nil {
+			return err
+		}
+		c.entrySize[e.Path] = fi.Size()
+		c.corpusDiskBytes += fi.Size()
+	}
+	return nil
+}
+
+// addCorpusDiskUsage records that entry, just added to the corpus, uses n
+// bytes in opts.CacheDir, then evicts entries if the corpus uses more than
+// opts.MaxCorpusDiskBytes.
+func (c *coordinator) addCorpusDiskUsage(entry CorpusEntry, n int64) error {
+	c.entrySize[entry.Path] = n
+	c.corpusDiskBytes += n
+	for c.opts.MaxCorpusDiskBytes > 0 && c.corpusDiskBytes > c.opts.MaxCorpusDiskBytes {
+		path, ok := c.chooseEviction()
+		if !ok {
+			if !c.warned["corpus disk budget"] {
+				c.logf(LogWarn, "warning: corpus uses %d bytes, more than MaxCorpusDiskBytes (%d), but every entry in the cache has coverage no other entry has\n", c.corpusDiskBytes, c.opts.MaxCorpusDiskBytes)
+				c.warned["corpus disk budget"] = true
+			}
+			return nil
+		}
+		if err := c.evictCorpusEntry(path, "to stay within MaxCorpusDiskBytes"); err != nil {
+			return err
+		}
+		c.evictedEntries++
+	}
+	return nil
+}
+
+// chooseEviction returns the path of the least valuable corpus entry that can
+// be removed from opts.CacheDir without losing coverage: an entry with no
+// coverage bits that other entries don't also have. Among those, the entry
+// that added the fewest new coverage bits when it was added is chosen, then
+// the one that's been in the corpus longest. Entries whose coverage isn't
+// known are never chosen.
+func (c *coordinator) chooseEviction() (string, bool) {
+	var once, twice []byte
+	for _, e := range c.corpus.entries {
+		cov := c.entryCoverage[e.Path]
+		if cov == nil {
+			continue
+		}
+		if once == nil {
+			once = make([]byte, len(cov))
+			twice = make([]byte, len(cov))
+		}
+		for i := range cov {
+			twice[i] |= once[i] & cov[i]
+			once[i] |= cov[i]
+		}
+	}
+
+	best, bestBits := "", -1
+	for _, e := range c.corpus.entries {
+		cov, ok := c.entryCoverage[e.Path]
+		if !ok || e.IsSeed || c.entrySize[e.Path] == 0 {
+			continue
+		}
+		unique := false
+		for i := range cov {
+			if cov[i]&^twice[i] != 0 {
+				unique = true
+				break
+			}
+		}
+		if unique {
+			continue
+		}
+		if bits := c.entryNewBits[e.Path]; bestBits < 0 || bits < bestBits {
+			best, bestBits = e.Path, bits
+		}
+	}
+	return best, bestBits >= 0
+}
+
+// evictCorpusEntry removes the entry at path from the corpus and from
+// opts.CacheDir. why is logged.
+func (c *coordinator) evictCorpusEntry(path, why string) error {
+	c.logf(LogDebug, "DEBUG evicting corpus entry %s %s\n", path, why)
+	pending := false
+	for i, e := range c.pendingCorpus {
+		if e.Path == path {
+			c.pendingCorpus = append(c.pendingCorpus[:i:i], c.pendingCorpus[i+1:]...)
+			pending = true
+			break
+		}
+	}
+	if !pending {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Remove(entryMetaPath(path)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	delete(c.pendingDerivations, path)
+	c.removeCorpusEntry(path)
+	c.corpusDiskBytes -= c.entrySize[path]
+	delete(c.entrySize, path)
+	delete(c.entryCoverage, path)
+	delete(c.entryNewBits, path)
+	delete(c.focused, path)
+	// The input queue may still hold the entry. It's refilled from the
+	// corpus when it's empty.
+	c.inputQueue.clear()
+	return nil
+}
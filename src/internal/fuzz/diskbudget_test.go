@@ -0,0 +1,74 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChooseEviction(t *testing.T) {
+	c := &coordinator{
+		entryCoverage: map[string][]byte{
+			"seed":   {1, 0, 0},
+			"unique": {1, 1, 0},
+			"dup1":   {1, 0, 1},
+			"dup2":   {0, 0, 1},
+		},
+		entryNewBits: map[string]int{"unique": 1, "dup1": 1, "dup2": 0},
+		entrySize:    map[string]int64{"unique": 10, "dup1": 10, "dup2": 10},
+	}
+	c.corpus.entries = []CorpusEntry{
+		{Path: "seed", IsSeed: true},
+		{Path: "unique"},
+		{Path: "dup1"},
+		{Path: "dup2"},
+	}
+	if path, ok := c.chooseEviction(); !ok || path != "dup2" {
+		t.Fatalf("chooseEviction() = %q, %v; want dup2, true", path, ok)
+	}
+
+	// Once dup2 is gone, dup1 has a bit no other entry has, and so does
+	// unique, so nothing can be evicted.
+	c.corpus.entries = c.corpus.entries[:3]
+	delete(c.entryCoverage, "dup2")
+	if path, ok := c.chooseEviction(); ok {
+		t.Errorf("chooseEviction() = %q, true; want no eviction", path)
+	}
+}
+
+func TestMaxCorpusDiskBytesLoadedEntry(t *testing.T) {
+	// An entry in the cache from a previous run has the same coverage as the
+	// seed, so it's evicted to stay within the budget.
+	cacheDir := t.TempDir()
+	loaded := filepath.Join(cacheDir, "loaded")
+	if err := ioutil.WriteFile(loaded, marshalCorpusFile([]byte("aa")), 0666); err != nil {
+		t.Fatal(err)
+	}
+	var sum Summary
+	err := coordinateForTest(t, "cover", CoordinateFuzzingOpts{
+		Limit:              2000,
+		CacheDir:           cacheDir,
+		MaxCorpusDiskBytes: 1,
+		Seed: []CorpusEntry{
+			{Path: "seed#0", Data: marshalCorpusFile([]byte("a")), Values: []interface{}{[]byte("a")}, IsSeed: true},
+		},
+		OnFinish: func(s Summary) { sum = s },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.CorpusGrowth == 0 {
+		t.Fatal("no interesting values were found")
+	}
+	if _, err := os.Stat(loaded); !os.IsNotExist(err) {
+		t.Errorf("entry loaded from the cache wasn't evicted: %v", err)
+	}
+	if sum.EvictedEntries == 0 {
+		t.Error("got EvictedEntries 0; want the loaded entry counted")
+	}
+}
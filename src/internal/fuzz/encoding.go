@@ -10,12 +10,29 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strconv"
 )
 
 // encVersion1 will be the first line of a file with version 1 encoding.
 var encVersion1 = "go test fuzz v1"
 
+// encVersionPrefix starts the first line of every corpus file. It's followed
+// by the version of the encoding.
+const encVersionPrefix = "go test fuzz "
+
+// encParsers maps the first line of a corpus file to a function that decodes
+// the remaining lines with that version of the encoding. marshalCorpusFile
+// always writes encVersion1, the current version. When the format changes,
+// a new version should be added here, and the parser for each old version
+// kept, so old corpus files stay readable and can be upgraded with
+// MigrateCorpus.
+var encParsers = map[string]func(lines [][]byte) ([]interface{}, error){
+	encVersion1: unmarshalCorpusLinesV1,
+}
+
 // marshalCorpusFile encodes an arbitrary number of arguments into the file format for the
 // corpus.
 func marshalCorpusFile(vals ...interface{}) []byte {
@@ -53,11 +70,21 @@ func unmarshalCorpusFile(b []byte) ([]interface{}, error) {
 	if len(lines) < 2 {
 		return nil, fmt.Errorf("must include version and at least one value")
 	}
-	if string(lines[0]) != encVersion1 {
-		return nil, fmt.Errorf("unknown encoding version: %s", lines[0])
+	parse, ok := encParsers[string(lines[0])]
+	if !ok {
+		if !bytes.HasPrefix(lines[0], []byte(encVersionPrefix)) {
+			return nil, fmt.Errorf("missing encoding version: first line must be %q", encVersion1)
+		}
+		return nil, fmt.Errorf("unsupported encoding version: %s (the newest supported version is %q)", lines[0], encVersion1)
 	}
+	return parse(lines[1:])
+}
+
+// unmarshalCorpusLinesV1 decodes the lines after the version line of a corpus
+// file with version 1 encoding.
+func unmarshalCorpusLinesV1(lines [][]byte) ([]interface{}, error) {
 	var vals []interface{}
-	for _, line := range lines[1:] {
+	for _, line := range lines {
 		line = bytes.TrimSpace(line)
 		if len(line) == 0 {
 			continue
@@ -71,6 +98,50 @@ func unmarshalCorpusFile(b []byte) ([]interface{}, error) {
 	return vals, nil
 }
 
+// MigrateCorpus rewrites each corpus file in dir that uses an older supported
+// version of the encoding with the current version, keeping its name. It
+// returns the number of files rewritten. Files that can't be decoded are left
+// as they are and reported in a MalformedCorpusError.
+func MigrateCorpus(dir string) (n int, err error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	var errs []error
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		filename := filepath.Join(dir, file.Name())
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return n, err
+		}
+		if bytes.HasPrefix(data, []byte(encVersion1+"\n")) {
+			continue // already current
+		}
+		vals, err := unmarshalCorpusFile(data)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%q: %v", filename, err))
+			continue
+		}
+		tmp := filename + ".tmp"
+		if err := ioutil.WriteFile(tmp, marshalCorpusFile(vals...), file.Mode().Perm()); err != nil {
+			os.Remove(tmp)
+			return n, err
+		}
+		if err := os.Rename(tmp, filename); err != nil {
+			os.Remove(tmp)
+			return n, err
+		}
+		n++
+	}
+	if len(errs) > 0 {
+		return n, &MalformedCorpusError{errs: errs}
+	}
+	return n, nil
+}
+
 func parseCorpusValue(line []byte) (interface{}, error) {
 	fs := token.NewFileSet()
 	expr, err := parser.ParseExprFrom(fs, "(test)", line, 0)
@@ -5,6 +5,8 @@
 package fuzz
 
 import (
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
@@ -170,3 +172,46 @@ func BenchmarkUnmarshalCorpusFile(b *testing.B) {
 		})
 	}
 }
+
+func TestUnmarshalVersion(t *testing.T) {
+	_, err := unmarshalCorpusFile([]byte("go test fuzz v99\nint(1)\n"))
+	if err == nil || !strings.Contains(err.Error(), "unsupported encoding version") {
+		t.Errorf("got error %v; want unsupported encoding version", err)
+	}
+}
+
+func TestMigrateCorpus(t *testing.T) {
+	// Pretend there's an older version that uses the same syntax.
+	const encVersion0 = "go test fuzz v0"
+	encParsers[encVersion0] = unmarshalCorpusLinesV1
+	defer delete(encParsers, encVersion0)
+
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old")
+	cur := filepath.Join(dir, "cur")
+	bad := filepath.Join(dir, "bad")
+	for name, data := range map[string]string{
+		old: encVersion0 + "\nint(1)\n",
+		cur: encVersion1 + "\nint(2)\n",
+		bad: "go test fuzz v99\nint(3)\n",
+	} {
+		if err := os.WriteFile(name, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := MigrateCorpus(dir)
+	if _, ok := err.(*MalformedCorpusError); !ok {
+		t.Errorf("got error %v; want MalformedCorpusError for %s", err, bad)
+	}
+	if n != 1 {
+		t.Errorf("migrated %d files; want 1", n)
+	}
+	data, err := os.ReadFile(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := string(marshalCorpusFile(1)); string(data) != want {
+		t.Errorf("migrated file contains %q; want %q", data, want)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Evaluator lets another goroutine run single inputs in the worker processes
+// of a running call to CoordinateFuzzing, for tools that probe the fuzz target
+// while it's being fuzzed: does this input crash, and what does it cover?
+// Inputs run this way aren't mutated or added to the corpus, and don't count
+// toward the limits on calls to the fuzz function.
+//
+// An Evaluator is set in CoordinateFuzzingOpts.Evaluator and may only be
+// used for one call to CoordinateFuzzing.
+type Evaluator struct {
+	reqC      chan evalRequest
+	doneC     chan struct{}
+	closeOnce sync.Once
+}
+
+// EvalResult describes the outcome of running one input with
+// Evaluator.Evaluate.
+type EvalResult struct {
+	// Crashed reports whether the fuzz function failed for the input, and
+	// Err is the error it reported.
+	Crashed bool
+	Err     string
+
+	// Coverage is the coverage snapshot for the input, in the format of
+	// SnapshotCoverage. It's nil if the test binary wasn't built with
+	// coverage instrumentation.
+	Coverage []byte
+
+	// Duration is the time the call to the fuzz function took.
+	Duration time.Duration
+}
+
+// evalRequest is an input for the coordinator to run for Evaluate. The result
+// is sent on resultC, which has room for it, so the worker never waits.
+type evalRequest struct {
+	data    []byte
+	resultC chan fuzzResult
+}
+
+// NewEvaluator returns a new Evaluator.
+func NewEvaluator() *Evaluator {
+	return &Evaluator{
+		reqC:  make(chan evalRequest),
+		doneC: make(chan struct{}),
+	}
+}
+
+// Evaluate runs the input encoded in data, in the format of corpus files, once
+// in a worker process, and returns what happened. It's safe to call Evaluate
+// from any goroutine while CoordinateFuzzing is running. If CoordinateFuzzing
+// has not started, Evaluate waits for it. Evaluate returns an error if ctx is
+// done or CoordinateFuzzing returns before the input is run, or if data
+// can't be decoded.
+func (ev *Evaluator) Evaluate(ctx context.Context, data []byte) (EvalResult, error) {
+	req := evalRequest{data: data, resultC: make(chan fuzzResult, 1)}
+	select {
+	case ev.reqC <- req:
+	case <-ev.doneC:
+		return EvalResult{}, errors.New("fuzzing has stopped")
+	case <-ctx.Done():
+		return EvalResult{}, ctx.Err()
+	}
+	var result fuzzResult
+	select {
+	case result = <-req.resultC:
+	case <-ev.doneC:
+		return EvalResult{}, errors.New("fuzzing stopped before the input was run")
+	case <-ctx.Done():
+		return EvalResult{}, ctx.Err()
+	}
+	if result.parseErr != "" {
+		return EvalResult{}, fmt.Errorf("decoding input: %s", result.parseErr)
+	}
+	er := EvalResult{
+		Crashed:  result.crasherMsg != "",
+		Err:      result.crasherMsg,
+		Coverage: result.coverageData,
+		Duration: result.totalDuration,
+	}
+	if er.Crashed && result.crashCoverage != nil {
+		er.Coverage = result.crashCoverage
+	}
+	return er, nil
+}
+
+// done is called when CoordinateFuzzing returns. Evaluate returns immediately
+// after done is called.
+func (ev *Evaluator) done() {
+	ev.closeOnce.Do(func() { close(ev.doneC) })
+}
+
+// evalInput returns the input sent to a worker to run req.
+func evalInput(req evalRequest) fuzzInput {
+	return fuzzInput{
+		entry:  CorpusEntry{Data: req.data},
+		limit:  1,
+		warmup: true,
+		evalC:  req.resultC,
+	}
+}
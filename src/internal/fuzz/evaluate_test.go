@@ -0,0 +1,37 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestEvaluator(t *testing.T) {
+	ev := NewEvaluator()
+	data := marshalCorpusFile([]byte("x"))
+	go func() {
+		// Play the part of the coordinator and a worker.
+		req := <-ev.reqC
+		in := evalInput(req)
+		if !in.warmup || in.limit != 1 || !bytes.Equal(in.entry.Data, data) {
+			panic("bad input for Evaluate")
+		}
+		in.evalC <- fuzzResult{crasherMsg: "boom", coverageData: []byte{1, 0}, crashCoverage: []byte{1, 1}}
+	}()
+	got, err := ev.Evaluate(context.Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Crashed || got.Err != "boom" || !bytes.Equal(got.Coverage, []byte{1, 1}) {
+		t.Errorf("got %+v; want a crash with the crash coverage", got)
+	}
+
+	ev.done()
+	if _, err := ev.Evaluate(context.Background(), data); err == nil {
+		t.Error("Evaluate succeeded after fuzzing stopped")
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"os"
+	"reflect"
+	"sort"
+)
+
+// ExportCorpus writes the corpus entries in the directories in srcs, along
+// with those already in dst, to dst in a canonical form, for committing to
+// version control. Each entry is encoded again in the current corpus file
+// format and named after a hash of its contents, and entries are written in
+// order of their names, so exporting the same values again leaves dst
+// unchanged, whatever names and encodings the values had in srcs. Files
+// that already have the right contents aren't rewritten.
+//
+// An entry in dst with another name is renamed. Crashers in dst, recognized
+// by their metadata, are left alone. Files that don't match types are
+// skipped and reported in a MalformedCorpusError once the other entries
+// have been exported.
+func ExportCorpus(dst string, srcs []string, types []reflect.Type) error {
+	files := make(map[string][]byte) // canonical path -> contents
+	var stale []string
+	var errs []error
+	for i, dir := range append([]string{dst}, srcs...) {
+		entries, err := readCorpus(dir, types, false)
+		if merr, ok := err.(*MalformedCorpusError); ok {
+			errs = append(errs, merr.errs...)
+		} else if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if i == 0 {
+				if meta, ok, _ := readEntryMeta(e.Path); ok && meta.Err != "" {
+					continue
+				}
+			}
+			data := marshalCorpusFile(e.Values...)
+			path := corpusEntryPath(data, dst)
+			files[path] = data
+			if i == 0 && e.Path != path {
+				stale = append(stale, e.Path)
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		data := files[path]
+		if existing, err := os.ReadFile(path); err == nil && string(existing) == string(data) {
+			continue
+		}
+		if err := writeEntryFile(&CorpusEntry{Path: path, Data: data}); err != nil {
+			return err
+		}
+	}
+	for _, path := range stale {
+		if files[path] != nil {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	if len(errs) > 0 {
+		return &MalformedCorpusError{errs: errs}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExportCorpus(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	write := func(path, s string) {
+		if err := ioutil.WriteFile(path, []byte(s), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The same values in a different encoding, under arbitrary names.
+	write(filepath.Join(src, "seq-1"), "go test fuzz v1\n[]byte(\"\\x61\")\n")
+	write(filepath.Join(dst, "old-name"), "go test fuzz v1\n[]byte(\"b\")\n")
+	crasher := filepath.Join(dst, "crasher")
+	write(crasher, "go test fuzz v1\n[]byte(\"c\")\n")
+	if err := writeEntryMeta(crasher, entryMeta{Err: "boom"}); err != nil {
+		t.Fatal(err)
+	}
+
+	types := []reflect.Type{reflect.TypeOf([]byte(nil))}
+	list := func() map[string]string {
+		files, err := ioutil.ReadDir(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := make(map[string]string)
+		for _, f := range files {
+			if f.IsDir() {
+				continue
+			}
+			data, err := ioutil.ReadFile(filepath.Join(dst, f.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			m[f.Name()] = string(data)
+		}
+		return m
+	}
+	if err := ExportCorpus(dst, []string{src}, types); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"crasher": "go test fuzz v1\n[]byte(\"c\")\n"}
+	for _, s := range []string{"a", "b"} {
+		data := marshalCorpusFile([]byte(s))
+		want[filepath.Base(corpusEntryPath(data, dst))] = string(data)
+	}
+	got := list()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("exported %v; want %v", got, want)
+	}
+
+	if err := ExportCorpus(dst, []string{src}, types); err != nil {
+		t.Fatal(err)
+	}
+	if again := list(); !reflect.DeepEqual(again, got) {
+		t.Errorf("exporting again changed the corpus from %v to %v", got, again)
+	}
+}
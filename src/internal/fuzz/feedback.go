@@ -0,0 +1,49 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import "sync"
+
+// feedback holds the value most recently reported with ReportFeedback while
+// the fuzz function runs in a worker process.
+var feedback struct {
+	mu  sync.Mutex
+	v   int64
+	set bool
+}
+
+// ReportFeedback reports a score for the input the fuzz function is currently
+// testing. Inputs that produce a higher score than any input seen before are
+// considered interesting and added to the corpus, even if they don't expand
+// coverage. This can direct fuzzing toward a goal, like reaching a target
+// value. If ReportFeedback is called more than once for an input, the highest
+// score is used.
+//
+// ReportFeedback may be called from any goroutine. It has no effect outside a
+// fuzzing worker process.
+func ReportFeedback(v int64) {
+	feedback.mu.Lock()
+	defer feedback.mu.Unlock()
+	if !feedback.set || v > feedback.v {
+		feedback.v = v
+		feedback.set = true
+	}
+}
+
+// takeFeedback returns the score reported for the last input, if any, and
+// clears it before the next input.
+func takeFeedback() (v int64, ok bool) {
+	feedback.mu.Lock()
+	defer feedback.mu.Unlock()
+	v, ok = feedback.v, feedback.set
+	feedback.v, feedback.set = 0, false
+	return v, ok
+}
+
+// improvesFeedback returns whether v is higher than best, the highest score
+// seen so far. best is nil if no score has been seen.
+func improvesFeedback(best *int64, v int64) bool {
+	return best == nil || v > *best
+}
@@ -0,0 +1,41 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import "testing"
+
+func TestReportFeedback(t *testing.T) {
+	seed := []CorpusEntry{
+		{Path: "seed#0", Data: marshalCorpusFile([]byte("seed")), Values: []interface{}{[]byte("seed")}, IsSeed: true},
+	}
+
+	// The fuzz function reports no coverage, so only its scores can make
+	// inputs interesting.
+	var sum Summary
+	err := coordinateForTest(t, "feedback", CoordinateFuzzingOpts{
+		Limit:    2000,
+		Seed:     seed,
+		OnFinish: func(s Summary) { sum = s },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.CorpusGrowth == 0 {
+		t.Error("no inputs with higher scores were added to the corpus")
+	}
+
+	// Scores that don't repeat when an input is run again are dropped.
+	err = coordinateForTest(t, "randfeedback", CoordinateFuzzingOpts{
+		Limit:    2000,
+		Seed:     seed,
+		OnFinish: func(s Summary) { sum = s },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.DeflakeRuns == 0 || sum.CorpusGrowth >= sum.DeflakeRuns {
+		t.Errorf("%d inputs added after %d deflake runs; want fewer inputs than runs", sum.CorpusGrowth, sum.DeflakeRuns)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"fmt"
+	"math/bits"
+	"sync"
+)
+
+// FeedbackSource is a source of the feedback that decides which inputs are
+// interesting: a bitmap that running the fuzz function sets bits in. By
+// default, it's the edge coverage the compiler instruments code with, but
+// other sources, like data-flow coverage or progress toward satisfying
+// comparisons, can be registered with RegisterFeedbackSource and selected
+// with CoordinateFuzzingOpts.FeedbackSource.
+//
+// An input is interesting if its bitmap has bits set that no input before it
+// had. The coordinator and each worker process select the source by name, so
+// it must be registered in every process, for example, in an init function in
+// a file of the test package built only with a build tag.
+type FeedbackSource interface {
+	// Len returns the number of bytes in the bitmap. It must not change once
+	// the source is selected.
+	Len() int
+
+	// Reset clears the feedback collected so far. It's called before the
+	// fuzz function is called with each input.
+	Reset()
+
+	// Snapshot writes the bitmap for the feedback collected since Reset to
+	// dst, which is Len bytes long.
+	Snapshot(dst []byte)
+
+	// NewBits returns the number of bits set in snapshot that aren't set in
+	// accumulated, the bitmaps of all the inputs found interesting so far.
+	NewBits(accumulated, snapshot []byte) int
+
+	// Merge adds the bits set in snapshot to accumulated, and returns the
+	// number of them that weren't already set.
+	Merge(accumulated, snapshot []byte) int
+}
+
+// defaultFeedbackSource is the name of the edge coverage source, used unless
+// another is selected.
+const defaultFeedbackSource = "edge"
+
+var (
+	feedbackSourcesMu sync.Mutex
+	feedbackSources   = map[string]FeedbackSource{defaultFeedbackSource: edgeCoverage{}}
+
+	// activeFeedback is the source SnapshotCoverage and ResetCoverage use,
+	// set by setFeedbackSource.
+	activeFeedback FeedbackSource = edgeCoverage{}
+)
+
+// RegisterFeedbackSource makes src available as a FeedbackSource under name.
+// It panics if name is empty or already registered.
+func RegisterFeedbackSource(name string, src FeedbackSource) {
+	feedbackSourcesMu.Lock()
+	defer feedbackSourcesMu.Unlock()
+	if name == "" || src == nil {
+		panic("fuzz: RegisterFeedbackSource called with an empty name or nil source")
+	}
+	if _, ok := feedbackSources[name]; ok {
+		panic(fmt.Sprintf("fuzz: RegisterFeedbackSource called twice for %q", name))
+	}
+	feedbackSources[name] = src
+}
+
+// setFeedbackSource makes the source registered as name, or edge coverage if
+// name is empty, the one used to decide which inputs are interesting, and
+// sizes coverageSnapshot for it. It must not be called while the fuzz
+// function runs.
+func setFeedbackSource(name string) error {
+	if name == "" {
+		name = defaultFeedbackSource
+	}
+	feedbackSourcesMu.Lock()
+	src, ok := feedbackSources[name]
+	feedbackSourcesMu.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown feedback source %q", name)
+	}
+	if src == activeFeedback {
+		return nil
+	}
+	activeFeedback = src
+	coverageSnapshot = make([]byte, src.Len())
+	coverageEnabled = src.Len() > 0
+	return nil
+}
+
+// edgeCoverage is the default FeedbackSource: the 8-bit counters for each
+// edge of the instrumented code, bucketed by counterBuckets.
+type edgeCoverage struct{}
+
+func (edgeCoverage) Len() int { return len(coverage()) }
+
+func (edgeCoverage) Reset() {
+	cov := coverage()
+	for i := range cov {
+		cov[i] = 0
+	}
+}
+
+func (edgeCoverage) Snapshot(dst []byte) {
+	for i, b := range coverage() {
+		dst[i] = counterBuckets[b]
+	}
+}
+
+func (edgeCoverage) NewBits(accumulated, snapshot []byte) int {
+	return countNewCoverageBits(accumulated, snapshot)
+}
+
+func (edgeCoverage) Merge(accumulated, snapshot []byte) int {
+	n := 0
+	for i := range snapshot {
+		n += bits.OnesCount8(snapshot[i] &^ accumulated[i])
+		accumulated[i] |= snapshot[i]
+	}
+	return n
+}
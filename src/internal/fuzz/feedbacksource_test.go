@@ -0,0 +1,74 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// testFeedback is a FeedbackSource whose bitmap is set directly by tests.
+type testFeedback struct {
+	cur    []byte
+	merges int
+}
+
+func (f *testFeedback) Len() int            { return len(f.cur) }
+func (f *testFeedback) Reset()              { f.cur = make([]byte, len(f.cur)) }
+func (f *testFeedback) Snapshot(dst []byte) { copy(dst, f.cur) }
+
+func (f *testFeedback) NewBits(accumulated, snapshot []byte) int {
+	return countNewCoverageBits(accumulated, snapshot)
+}
+
+func (f *testFeedback) Merge(accumulated, snapshot []byte) int {
+	f.merges++
+	return edgeCoverage{}.Merge(accumulated, snapshot)
+}
+
+var (
+	registerTestFeedback sync.Once
+	theTestFeedback      = &testFeedback{cur: make([]byte, 4)}
+)
+
+func TestFeedbackSource(t *testing.T) {
+	registerTestFeedback.Do(func() {
+		RegisterFeedbackSource("test", theTestFeedback)
+	})
+	defer setFeedbackSource("")
+
+	opts := CoordinateFuzzingOpts{
+		Types:          []reflect.Type{reflect.TypeOf([]byte(nil))},
+		Log:            io.Discard,
+		CacheDir:       t.TempDir(),
+		FeedbackSource: "unknown",
+	}
+	if _, err := newCoordinator(opts); err == nil {
+		t.Fatal("newCoordinator accepted an unknown feedback source")
+	}
+
+	opts.FeedbackSource = "test"
+	c, err := newCoordinator(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !coverageEnabled || len(c.coverageMask) != 4 {
+		t.Fatalf("coverage enabled = %v with a %d byte mask; want true and 4 bytes", coverageEnabled, len(c.coverageMask))
+	}
+
+	theTestFeedback.cur[2] = 0x3
+	theTestFeedback.merges = 0
+	SnapshotCoverage()
+	if n := c.updateCoverage(coverageSnapshot); n != 2 || theTestFeedback.merges != 1 {
+		t.Errorf("updateCoverage returned %d after %d merges; want 2 after 1", n, theTestFeedback.merges)
+	}
+	ResetCoverage()
+	SnapshotCoverage()
+	if countBits(coverageSnapshot) != 0 {
+		t.Errorf("snapshot after ResetCoverage is %v; want no bits set", coverageSnapshot)
+	}
+}
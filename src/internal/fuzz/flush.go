@@ -0,0 +1,50 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import "sync"
+
+// CorpusFlusher lets another goroutine ask CoordinateFuzzing to write
+// interesting values it's holding in memory to the cache directory, for
+// example, before a tool reads the cache while fuzzing is running. Values are
+// only held in memory when CoordinateFuzzingOpts.DeferCorpusWrite is set.
+//
+// A CorpusFlusher is set in CoordinateFuzzingOpts.Flusher and may only be
+// used for one call to CoordinateFuzzing.
+type CorpusFlusher struct {
+	reqC      chan chan error
+	doneC     chan struct{}
+	closeOnce sync.Once
+}
+
+// NewCorpusFlusher returns a new CorpusFlusher.
+func NewCorpusFlusher() *CorpusFlusher {
+	return &CorpusFlusher{
+		reqC:  make(chan chan error),
+		doneC: make(chan struct{}),
+	}
+}
+
+// Flush writes all interesting values found so far to the cache directory
+// and returns once they've been written. It's safe to call Flush from any
+// goroutine while CoordinateFuzzing is running. If CoordinateFuzzing has not
+// started, Flush waits for it. If CoordinateFuzzing already returned, Flush
+// returns nil immediately: values are always written before it returns, and
+// an error doing so is returned by CoordinateFuzzing.
+func (f *CorpusFlusher) Flush() error {
+	errC := make(chan error, 1)
+	select {
+	case f.reqC <- errC:
+		return <-errC
+	case <-f.doneC:
+		return nil
+	}
+}
+
+// done is called when CoordinateFuzzing returns. Flush returns immediately
+// after done is called.
+func (f *CorpusFlusher) done() {
+	f.closeOnce.Do(func() { close(f.doneC) })
+}
@@ -10,15 +10,18 @@ package fuzz
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"math/bits"
+	"math"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -52,6 +55,27 @@ type CoordinateFuzzingOpts struct {
 	// minimization will be disabled.
 	MinimizeLimit int64
 
+	// TotalMinimizeBudget is the total amount of wall clock time to spend
+	// minimizing crashers over the whole run. Once the budget is spent, new
+	// crashers are recorded without being minimized. If zero, there is no
+	// limit on total minimization time.
+	TotalMinimizeBudget time.Duration
+
+	// MinimizeCoverageBits makes minimization of values that expanded
+	// coverage prefer values that hit fewer coverage bits in total, in
+	// addition to being smaller. The minimized value still hits at least one
+	// of the new bits but exercises less unrelated code. It has no effect on
+	// minimizing crashers.
+	MinimizeCoverageBits bool
+
+	// MinimizeCoverageSubset makes minimization of values that expanded
+	// coverage stricter: a smaller value is only accepted if every coverage
+	// bit it hits was also hit by the original value. Without it, a value
+	// that keeps one of the new bits may also reach code the original
+	// didn't, so the minimized entry exercises a broader set of behaviors
+	// than the one it was kept for. It has no effect on minimizing crashers.
+	MinimizeCoverageSubset bool
+
 	// parallel is the number of worker processes to run in parallel. If zero,
 	// CoordinateFuzzing will run GOMAXPROCS workers.
 	Parallel int
@@ -71,6 +95,485 @@ type CoordinateFuzzingOpts struct {
 	// CacheDir is a directory containing additional "interesting" values.
 	// The fuzzer may derive new values from these, and may write new values here.
 	CacheDir string
+
+	// ExtraCacheDirs is a list of additional directories containing
+	// "interesting" values, like a curated corpus shared by a team. They're
+	// read after CacheDir, in order, and values found in more than one
+	// directory are only loaded once. New values are only written to
+	// CacheDir; ExtraCacheDirs may be read-only.
+	ExtraCacheDirs []string
+
+	// DeferCorpusWrite causes interesting values to be kept in memory instead
+	// of being written to CacheDir as soon as they are found. They are written
+	// together when fuzzing stops, including when fuzzing is interrupted.
+	// Crashers are still written to CorpusDir immediately.
+	DeferCorpusWrite bool
+
+	// Flusher, if non-nil, may be used by another goroutine to write values
+	// held in memory because of DeferCorpusWrite while fuzzing is running.
+	Flusher *CorpusFlusher
+
+	// Evaluator, if non-nil, may be used by another goroutine to run single
+	// inputs in the workers while fuzzing is running.
+	Evaluator *Evaluator
+
+	// OnFinish, if non-nil, is called once with a summary of the run after
+	// all workers have stopped and all crashers and interesting values
+	// have been written.
+	OnFinish func(Summary)
+
+	// OnCrash, if non-nil, is called for each new crasher once it's written,
+	// with the entry, whose Path is the file it was written to, and the
+	// error it caused, for example, to file an issue or send an alert. It's
+	// only called for the first crasher with each stack signature; see
+	// Crasher.Signature. OnCrash runs on its own goroutine, so fuzzing
+	// doesn't wait for it, but CoordinateFuzzing doesn't return until it
+	// has. An error it returns is logged but doesn't change the result of
+	// fuzzing.
+	OnCrash func(entry CorpusEntry, errMsg string) error
+
+	// WorkerEnv is a list of additional environment variables in the form
+	// "key=value" to set in worker processes. Workers otherwise run with the
+	// same environment as the coordinator.
+	WorkerEnv []string
+
+	// DetectGoroutineLeaks causes workers to periodically sample the number
+	// of running goroutines and report a warning if it keeps growing, which
+	// likely means the fuzz function starts goroutines that never stop.
+	DetectGoroutineLeaks bool
+
+	// InputTimeout is the amount of time a single call to the fuzz function
+	// may take. If positive, workers call the fuzz function on a separate
+	// goroutine and record the input as a crasher if the call doesn't return
+	// in time, without restarting the worker process. The call can't be
+	// stopped, so this only works for code that yields to the scheduler; if
+	// too many calls are left running, the worker process is terminated
+	// instead. While a call is left running, workers don't report coverage,
+	// since the call keeps updating the counters. If zero, there is no time
+	// limit.
+	InputTimeout time.Duration
+
+	// WorkerStartStagger is the time between starting each worker process.
+	// Workers are started in a random order. If zero, all workers are started
+	// at once. A short delay spreads out the memory and CPU used while worker
+	// processes initialize, which helps on machines with little memory when
+	// Parallel is high.
+	WorkerStartStagger time.Duration
+
+	// MutatorWeights sets the relative weights of the classes of mutations
+	// applied to []byte and string values: "remove", "insert", "overwrite",
+	// "bitflip", "arithmetic", "interesting", "splice", and "dictionary".
+	// "splice" copies bytes from another corpus entry the input is being
+	// spliced with, and "dictionary" inserts or overwrites tokens from
+	// Dictionary; neither applies without such an entry or tokens. A class
+	// with weight 0 is not used. Classes that aren't listed keep their
+	// default weight, which is the number of mutations in the class, so that
+	// by default every mutation is equally likely.
+	MutatorWeights map[string]int
+
+	// Dictionary holds tokens, like keywords of the input format, that the
+	// "dictionary" class of mutations copies into []byte and string values.
+	Dictionary [][]byte
+
+	// InputSchemas holds the layout of []byte arguments to the fuzz function,
+	// by argument index, not counting *testing.T. Arguments with a schema
+	// are mutated field by field; see Schema.
+	InputSchemas map[int]Schema
+
+	// MaxInterestingPerSec limits the rate at which values that expand
+	// coverage are added to the corpus, smoothing corpus and memory growth
+	// when coverage grows quickly. Short bursts of up to MaxInterestingPerSec
+	// values are allowed. Beyond the limit, only values with rare coverage are
+	// kept: values that reach code not reached before, or that expand
+	// coverage of code few other values have expanded. The rest are dropped.
+	// If zero, there is no limit.
+	MaxInterestingPerSec float64
+
+	// FocusCoverage lists the indexes of coverage counters in code that
+	// fuzzing should focus on, for example, a recently changed function.
+	// Corpus entries that reach any of these counters are fuzzed focusWeight
+	// times as often as other entries. The indexes match the counters in
+	// coverage profiles and in the NewCoverage metadata recorded for
+	// crashers; the instrumentation doesn't record which source location each
+	// counter belongs to, so the caller must map locations to counters.
+	// FocusCoverage is ignored if coverage instrumentation is disabled.
+	FocusCoverage []int
+
+	// MinExecPerSec is the lowest acceptable rate of calls to the fuzz
+	// function. If, after fuzzing for execRateGracePeriod following warmup,
+	// the average rate is lower, fuzzing stops with an error, since a fuzz
+	// function that slow is unlikely to find anything. If zero, any rate
+	// is accepted.
+	MinExecPerSec float64
+
+	// WorkerRSSLimit is the resident set size, in bytes, above which a worker
+	// process is restarted. Workers are checked every WorkerRSSCheckInterval
+	// while they wait for input, so a worker is only stopped between calls to
+	// the fuzz function, and the corpus and coverage found so far are kept.
+	// This keeps a fuzz function that leaks memory from being killed by the
+	// operating system during a long run. WorkerRSSLimit is only supported on
+	// Linux. If zero, workers are not restarted.
+	WorkerRSSLimit int64
+
+	// WorkerRSSCheckInterval is how often worker memory use is compared with
+	// WorkerRSSLimit. If zero, defaultRSSCheckInterval is used.
+	WorkerRSSCheckInterval time.Duration
+
+	// RunID, if set, determines all the random choices made while fuzzing:
+	// the seed of each worker's mutator and the order in which workers start.
+	// Each seed is derived from RunID by hashing, and RunID is printed to Log
+	// when fuzzing starts. Fuzzing again with the same RunID, binary, and
+	// corpus generates the same inputs in the same order, though which worker
+	// tests each input still depends on timing, as do results that depend on
+	// anything but the input. If empty, seeds are chosen at random.
+	RunID string
+
+	// MaxInputSize is the largest total length, in bytes, of the []byte and
+	// string values in a mutated input. Workers skip mutated inputs that are
+	// larger without calling the fuzz function, and mutate the value from
+	// before the skipped mutation again. The coordinator warns if
+	// many inputs are skipped, since a limit that's too small keeps fuzzing
+	// from reaching code that needs larger inputs. Corpus entries are tested
+	// regardless of their size. If zero, there is no limit.
+	MaxInputSize int
+
+	// CorpusOnly makes CoordinateFuzzing test each entry in the corpus once,
+	// print the number of coverage bits reached by the corpus as a whole, and
+	// return without fuzzing. Tracking that number over time shows how
+	// effective the corpus is. The number is also reported as
+	// Summary.CorpusCoverageBits.
+	CorpusOnly bool
+
+	// RandomizeRuntime makes each worker process run with randomly chosen
+	// runtime settings: GOMAXPROCS between 1 and the number of CPUs, and
+	// GODEBUG settings that toggle concurrent garbage collection and
+	// asynchronous preemption. The settings are chosen again each time a
+	// worker process starts, which makes bugs that depend on scheduling or
+	// garbage collection more likely to show up. The settings are recorded
+	// with each crasher, and crashers are minimized with the settings they
+	// were found with.
+	RandomizeRuntime bool
+
+	// CrasherFilter, if non-nil, is called with each crashing input found by
+	// fuzzing and the error message it caused, before the input is minimized
+	// or written. If CrasherFilter returns false, the crash is ignored and
+	// fuzzing continues, which lets the caller skip crashes caused by known
+	// bugs, for example, by matching the error message. Crashes caused by
+	// seed corpus entries are always reported.
+	CrasherFilter func(entry CorpusEntry, errMsg string) bool
+
+	// NoMinimize disables minimization regardless of MinimizeTimeout and
+	// MinimizeLimit. Crashers are written exactly as they were found, and
+	// interesting values are added to the corpus without being minimized.
+	// Crashers may be minimized later, one at a time.
+	NoMinimize bool
+
+	// LogLevel is the least severe level of messages written to Log. If zero,
+	// it's LogInfo: progress messages and warnings are written, and debug
+	// messages are not.
+	LogLevel LogLevel
+
+	// NamingScheme, if non-nil, returns the file name for a new corpus entry
+	// or crasher with the given encoded data, for example, to include the time
+	// it was found or a sequence number. If the name is already used by a
+	// different entry, a numeric suffix is added. If nil, or if the name is
+	// empty, files are named with a hash of their contents.
+	NamingScheme func(data []byte, info EntryNameInfo) string
+
+	// FailOnSeedCrash makes CoordinateFuzzing return an error without
+	// fuzzing if any seed corpus entry crashes. Either way, every seed corpus
+	// entry is tested first, each crashing seed is printed to Log, and they're
+	// reported as Summary.SeedCrashers. A crashing seed usually means the
+	// fuzz function is broken or a bug is already known. If FailOnSeedCrash
+	// is false, the crashing seeds are removed from the corpus and the rest
+	// is fuzzed.
+	FailOnSeedCrash bool
+
+	// SnapshotPath, if set, is where a snapshot of the fuzzing session is
+	// written when fuzzing stops: a gzip-compressed tar archive with the
+	// corpus, the crashers found, and a manifest.json file describing the
+	// lineage of each entry and the coverage it added. The archive is
+	// written to a temporary file first and renamed, so it's never left
+	// partially written.
+	SnapshotPath string
+
+	// FlakyWorkerRate, if positive, is the fraction of attempts to deflake new
+	// coverage that may fail in a worker before the worker is quarantined.
+	// A worker whose process keeps reporting coverage that doesn't reproduce,
+	// for example, because of a bad CPU core or contention on its host, wastes
+	// time on deflake runs. A quarantined worker process is stopped for
+	// FlakyWorkerQuarantine, then restarted fresh. The rate is only checked
+	// once a worker process has made flakyWorkerMinDeflakes deflake attempts.
+	FlakyWorkerRate float64
+
+	// FlakyWorkerQuarantine is how long a worker is stopped when its rate of
+	// flaky coverage exceeds FlakyWorkerRate. If zero,
+	// defaultFlakyWorkerQuarantine is used.
+	FlakyWorkerQuarantine time.Duration
+
+	// SharedMemDir is the directory where the files backing the memory shared
+	// with each worker process are created. Each file is as large as the
+	// largest input (100 MB), so a small or slow default temporary directory
+	// may be a poor fit. If empty, the default directory for temporary files
+	// is used.
+	SharedMemDir string
+
+	// MetricsFile, if set, is a file where the coordinator writes metrics in
+	// the Prometheus text format every few seconds while fuzzing, and once
+	// more when it stops: calls to the fuzz function, the rate of calls,
+	// coverage bits, corpus size, crashers, and worker restarts. The file is
+	// meant for a collector that reads metrics from files, like the node
+	// exporter's textfile collector, so a long fuzzing run can be monitored
+	// with the usual tools. Serving metrics over HTTP from here would link
+	// net/http into every test binary.
+	MetricsFile string
+
+	// MaxCorpusDiskBytes, if positive, limits the total size of the corpus
+	// files in CacheDir, so fuzzing can run indefinitely on a fixed amount of
+	// disk. When a new entry would exceed the limit, the least valuable
+	// entries are removed: those that added the fewest coverage bits, then
+	// the oldest. Entries aren't removed in least recently used order, since
+	// every entry is fuzzed in turn, so how recently an entry was used says
+	// nothing about its value. Entries in CacheDir from previous runs are
+	// ranked by the coverage bits they added when the corpus was tested at
+	// the start of the run. An entry is never removed if it has coverage bits
+	// that no other entry in the corpus has, so the coverage of the corpus as
+	// a whole is kept. If no entry can be removed, the limit is exceeded.
+	MaxCorpusDiskBytes int64
+
+	// TargetExecLimit is the number of calls to the fuzz function to make
+	// after the seed corpus and cache have been tested. Unlike Limit, it
+	// doesn't count calls made while testing the corpus, so a run makes the
+	// same number of fuzzing calls however large the corpus is and however
+	// fast the machine is. This is meant for smoke testing many fuzz targets
+	// for a bounded time, for example, on every change. If Limit is also set,
+	// fuzzing stops when either is reached.
+	TargetExecLimit int64
+
+	// VerifyMinimizedCrashers makes the coordinator run each minimized
+	// crasher once more in a newly started worker process, since the process
+	// that minimized it may have accumulated state that the crash depends on.
+	// If the minimized input doesn't cause an error in the new process, a
+	// warning is logged, and the crasher is recorded as it was found.
+	VerifyMinimizedCrashers bool
+
+	// IsolateRun makes the run safe to start while another run uses the same
+	// CacheDir and CorpusDir, for example, in another process. Interesting
+	// values are written to a subdirectory of CacheDir for this run, named
+	// after RunID if it's set, and moved into CacheDir one file at a time
+	// when fuzzing stops. Crashers are written to CorpusDir with the run's
+	// name appended to their file names, so runs don't overwrite each other's
+	// crashers.
+	IsolateRun bool
+
+	// MaxMutatedLen, if positive, is the largest length, in bytes, that the
+	// mutator grows a []byte or string value to, including when it inserts,
+	// duplicates, or splices bytes. Unlike MaxInputSize, it limits the inputs
+	// that are generated rather than skipping large ones, so it keeps fuzzing
+	// focused on small inputs. A value that's already longer, like a large
+	// seed, may still be mutated but doesn't grow. The limit can't exceed
+	// the size of the memory shared with workers, which applies regardless.
+	MaxMutatedLen int
+
+	// MinMutatedLen, if positive, is the shortest length, in bytes, that the
+	// mutator shrinks a []byte or string value to, including when it
+	// removes or splices bytes, and that minimization shrinks one to. It's
+	// for targets that reject short inputs outright, like those that need a
+	// fixed-size header. A value that's already shorter, like an empty seed,
+	// may still be mutated but doesn't shrink.
+	MinMutatedLen int
+
+	// SkipCorpusErrors makes the coordinator skip files in CacheDir and
+	// ExtraCacheDirs that can't be read, for example, because of their
+	// permissions, instead of failing. Skipped files, including those that
+	// can't be parsed, are logged and listed in the Summary, and fuzzing
+	// continues with the entries that were loaded. Without it, files that
+	// can't be parsed are skipped silently.
+	SkipCorpusErrors bool
+
+	// WarmupFn, if set, is the same function the worker processes pass to
+	// RunFuzzWorker. When the corpus is small, the coordinator calls it in its
+	// own process to test the corpus and gather the baseline coverage before
+	// fuzzing, instead of sending each entry to a worker, which makes fuzzing
+	// start sooner. The baseline is the same either way. If any entry fails,
+	// the corpus is tested by workers instead, so the failure is reported as
+	// usual. The function must not have side effects that would affect the
+	// coordinator, since it runs in the coordinator's process.
+	WarmupFn func(CorpusEntry) error
+
+	// CrasherReproduceRuns, if positive, is the number of times each crasher
+	// is run again in a newly started worker process before it's written, to
+	// measure how reliably it reproduces. The count is recorded in the
+	// crasher's metadata and reported in Summary.Crashers. A crasher that
+	// only fails some of the time may depend on timing or on state outside
+	// the input. At most crasherReproduceTimeout is spent measuring each
+	// crasher, so fewer runs may be made. Crashers that are written without
+	// being minimized because the minimization budget was spent aren't
+	// measured.
+	CrasherReproduceRuns int
+
+	// RawCorpus makes the coordinator read and write the files in CacheDir
+	// and ExtraCacheDirs as raw input bytes, the way libFuzzer and AFL store
+	// their corpora, instead of in the Go corpus file format. This allows
+	// sharing corpora with those tools. It's only allowed when the fuzz
+	// target takes a single []byte argument. Crashers are still written to
+	// CorpusDir in the Go format, so 'go test' can run them. The cache
+	// directory shouldn't contain files written without RawCorpus: they'd be
+	// read as raw inputs.
+	RawCorpus bool
+
+	// WorkerMaxThreads, if positive, is the most OS threads a worker process
+	// may use (see runtime/debug.SetMaxThreads). A fuzz function that leaks
+	// threads, for example, in blocking system calls, makes the process
+	// fail with a thread exhaustion error, which is recorded as a crasher
+	// for the input being tested. It must leave room for the threads the
+	// worker process uses before fuzzing.
+	WorkerMaxThreads int
+
+	// WorkerMaxOpenFiles, if positive, is the soft limit on open file
+	// descriptors set in each worker process, so a fuzz function that leaks
+	// them gets errors opening files while testing the input that reached
+	// the limit, rather than failing much later. It can't exceed the hard
+	// limit of the coordinator process. It's only supported on Linux and
+	// macOS.
+	WorkerMaxOpenFiles int
+
+	// RunUntilInterrupted makes fuzzing run until ctx is cancelled, for
+	// example, by ^C or a signal, for a dedicated fuzzing host. Timeout,
+	// Limit, TargetExecLimit and MinExecPerSec are ignored. Fuzzing still
+	// stops when a crasher is found. Interesting values held in memory
+	// because of DeferCorpusWrite are written to the cache every
+	// runForeverFlushInterval, so a run that's killed without a chance to
+	// clean up loses few of them.
+	RunUntilInterrupted bool
+
+	// Generator, if set, creates some of the inputs the coordinator sends to
+	// workers, instead of taking them from the corpus, for targets that parse
+	// structured formats that randomly mutated bytes rarely are. For example,
+	// it may be the Generate method of a Grammar. Workers fuzz generated
+	// inputs like corpus entries, and those that expand coverage are added
+	// to the corpus. It's only allowed when the fuzz target takes a single
+	// []byte or string argument. Generator is called in the coordinator's
+	// process, with a source of randomness derived from RunID if it's set.
+	Generator func(r *rand.Rand) []byte
+
+	// GenerateInterval is how often inputs are created with Generator: one of
+	// every GenerateInterval inputs sent to workers is. If zero,
+	// defaultGenerateInterval is used.
+	GenerateInterval int
+
+	// RecordDerivation makes the coordinator record how each interesting value
+	// written to the cache was derived: the entry it was mutated from, the
+	// state of the worker's random number generator, and the number of
+	// mutations applied. It's stored in a metadata file next to the entry,
+	// which tools that don't know about it ignore. With it, the mutations
+	// that produced any entry can be replayed, which helps when debugging the
+	// mutator. Values that were minimized or generated by Generator
+	// aren't recorded.
+	RecordDerivation bool
+
+	// FeedbackSource is the name of the FeedbackSource that decides which
+	// inputs are interesting, registered with RegisterFeedbackSource in both
+	// the coordinator's process and the workers'. If empty, edge coverage is
+	// used.
+	FeedbackSource string
+
+	// PerWorkerSummary makes the summary passed to OnFinish include a
+	// breakdown of the run by worker, in Summary.Workers.
+	PerWorkerSummary bool
+
+	// CompactInterval, if positive, is how often the coordinator compacts the
+	// corpus during a run: entries in CacheDir whose coverage bits other
+	// entries all have are removed, so the corpus doesn't keep growing with
+	// entries that better ones have made redundant. Fuzzing continues while
+	// the corpus is compacted. CacheDir must be set.
+	CompactInterval time.Duration
+
+	// IdleWorkersTimeout, if positive, is how long the coordinator may go
+	// without sending an input to a worker or receiving a result before it
+	// warns that every worker is idle. That means the coordinator has stopped
+	// scheduling inputs, because of a bug or a misconfiguration, and fuzzing
+	// is making no progress. With PanicOnIdleWorkers, the coordinator panics
+	// instead, printing the stacks of all its goroutines, which is more
+	// useful when developing this package.
+	IdleWorkersTimeout time.Duration
+	PanicOnIdleWorkers bool
+
+	// WriteCoveragePath, if set, is where the coverage reached by the run is
+	// written when fuzzing stops, as a file of coverage counters in the
+	// format of SnapshotCoverage. It includes the coverage loaded from
+	// BaselineCoveragePath.
+	WriteCoveragePath string
+
+	// BaselineCoveragePath, if set, is a file written by an earlier run with
+	// WriteCoveragePath. The coverage in it is considered already covered
+	// from the start, so fuzzing focuses on finding coverage beyond it, and
+	// each run of a campaign builds on the ones before. The file must have
+	// been written by a test binary with the same coverage counters.
+	BaselineCoveragePath string
+
+	// CoverageDedupWindow is how long the coordinator collects results
+	// reporting new coverage before handling them together. When several
+	// workers find the same new coverage at about the same time, only one
+	// of their results is minimized and added to the corpus, rather than
+	// each. No new inputs are sent to workers while results are held, so
+	// they aren't used up by fuzzing before the values are minimized. If
+	// zero, defaultCoverageDedupWindow is used with more than one worker,
+	// and results are handled as they arrive with one. If negative,
+	// results are always handled as they arrive.
+	CoverageDedupWindow time.Duration
+
+	// RecordSessionPath, if set, is where a log of every call the workers
+	// make to fuzz an input is written: the input, the state of the
+	// worker's random number generator, and the number of values tested.
+	// The log is compact, since each input's data is written only once.
+	// Warmup calls and inputs run for an Evaluator aren't recorded.
+	RecordSessionPath string
+
+	// ReplaySessionPath, if set, is a log written with RecordSessionPath.
+	// After the corpus is tested as usual, the calls in it are repeated
+	// one at a time, in the order they finished, instead of fuzzing:
+	// each tests exactly the values the recorded call did, in the same
+	// order. This helps debug the coordinator and workers, since a problem
+	// that shows up only after a particular sequence of inputs happens
+	// again. Values aren't minimized, and fuzzing stops at the end of the
+	// log. The other options should match the recorded run's.
+	ReplaySessionPath string
+
+	// MaxMinimizeAttempts, if positive, is the number of calls to the fuzz
+	// function each crasher may be minimized with. It's used instead of
+	// MinimizeTimeout and MinimizeLimit for crashers, so how far a crasher is
+	// minimized doesn't depend on how fast the machine is, which makes runs
+	// in CI predictable. Minimization must still be enabled with one of
+	// them, and TotalMinimizeBudget still applies.
+	MaxMinimizeAttempts int
+
+	// MinCoverageUtilization is the fraction of coverage counters below
+	// which the coordinator warns, once, if that's all inputs have reached
+	// after substantial fuzzing: the corpus probably isn't diverse enough, or
+	// the fuzz function can't reach much of the code. If zero,
+	// defaultMinCoverageUtilization is used. If negative, the coordinator
+	// doesn't warn.
+	MinCoverageUtilization float64
+
+	// DeterministicOrder makes the coordinator run corpus entries in a
+	// stable order: seed values first, in the order they were given, then
+	// other entries sorted by file name, across CacheDir and ExtraCacheDirs.
+	// Entries found while fuzzing are fuzzed in the same order. With
+	// Parallel set to 1, a run that only tests the corpus, like one with
+	// CorpusOnly, calls the fuzz function with the same values in the same
+	// order every time, which helps debug targets whose global state makes
+	// them depend on the order.
+	DeterministicOrder bool
+
+	// OnNewCoverage, if non-nil, is called each time a coverage bit is set
+	// for the first time, with the index of the bit in the coverage bitmap
+	// and the path of the corpus entry that set it. With edge coverage,
+	// bitIndex/8 is the index of the counter and bitIndex%8 its bucket; see
+	// counterBuckets. Coverage loaded from BaselineCoveragePath isn't
+	// reported. OnNewCoverage is called by the coordinator's main loop, so it
+	// must return quickly, for example, by sending to a buffered channel.
+	OnNewCoverage func(bitIndex int, byEntry string)
 }
 
 // CoordinateFuzzing creates several worker processes and communicates with
@@ -83,12 +586,26 @@ type CoordinateFuzzingOpts struct {
 // If a crash occurs, the function will return an error containing information
 // about the crash, which can be reported to the user.
 func CoordinateFuzzing(ctx context.Context, opts CoordinateFuzzingOpts) (err error) {
+	if opts.Flusher != nil {
+		// Deferred first so it runs last, after values are written.
+		defer opts.Flusher.done()
+	}
+	if opts.Evaluator != nil {
+		defer opts.Evaluator.done()
+	}
 	if err := ctx.Err(); err != nil {
 		return err
 	}
 	if opts.Log == nil {
 		opts.Log = io.Discard
 	}
+	if opts.RunUntilInterrupted {
+		// Only cancelling ctx stops fuzzing.
+		opts.Timeout = 0
+		opts.Limit = 0
+		opts.TargetExecLimit = 0
+		opts.MinExecPerSec = 0
+	}
 	if opts.Parallel == 0 {
 		opts.Parallel = runtime.GOMAXPROCS(0)
 	}
@@ -96,11 +613,32 @@ func CoordinateFuzzing(ctx context.Context, opts CoordinateFuzzingOpts) (err err
 		// Don't start more workers than we need.
 		opts.Parallel = int(opts.Limit)
 	}
+	if opts.ReplaySessionPath != "" {
+		// Repeat the recorded calls in order, and only those.
+		opts.Parallel = 1
+		opts.NoMinimize = true
+	}
 
 	c, err := newCoordinator(opts)
 	if err != nil {
 		return err
 	}
+	if opts.RunID != "" {
+		c.logf(LogInfo, "fuzz: run ID: %s\n", opts.RunID)
+	}
+	if opts.RunUntilInterrupted {
+		c.logf(LogInfo, "fuzz: running until interrupted\n")
+	}
+	for _, ferr := range c.corpus.skipped {
+		c.logf(LogWarn, "warning: skipping corpus file %s: %v\n", ferr.path, ferr.err)
+	}
+
+	// workers is set below. It's declared here so the summary can report on
+	// workers after every other deferred call has run.
+	var workers []*worker
+	if opts.OnFinish != nil {
+		defer func() { opts.OnFinish(c.summary(workers)) }()
+	}
 
 	if opts.Timeout > 0 {
 		var cancel func()
@@ -134,6 +672,76 @@ func CoordinateFuzzing(ctx context.Context, opts CoordinateFuzzingOpts) (err err
 		doneC = nil
 	}
 
+	// Move the values this run added to the cache into place once everything
+	// else is done with them.
+	if opts.IsolateRun && opts.CacheDir != "" {
+		defer func() {
+			if werr := c.mergeRunDir(); werr != nil {
+				werr = fmt.Errorf("merging cache: %v", werr)
+				if err == nil {
+					err = werr
+				} else {
+					err = fmt.Errorf("%w\n%v", err, werr)
+				}
+			}
+		}()
+	}
+
+	if opts.MetricsFile != "" {
+		if err := c.writeMetrics(); err != nil {
+			return fmt.Errorf("writing metrics: %w", err)
+		}
+		// Write the final values once everything else is done.
+		defer func() {
+			if werr := c.writeMetrics(); werr != nil && err == nil {
+				err = fmt.Errorf("writing metrics: %w", werr)
+			}
+		}()
+	}
+
+	if opts.RecordSessionPath != "" {
+		if c.sessionRec, err = newSessionRecorder(opts.RecordSessionPath); err != nil {
+			return fmt.Errorf("recording session: %w", err)
+		}
+		defer func() {
+			if werr := c.sessionRec.close(); werr != nil && err == nil {
+				err = fmt.Errorf("recording session: %w", werr)
+			}
+		}()
+	}
+	if opts.ReplaySessionPath != "" {
+		if c.replay, err = openSession(opts.ReplaySessionPath); err != nil {
+			return err
+		}
+		defer c.replay.close()
+	}
+
+	if opts.WriteCoveragePath != "" {
+		defer func() {
+			if werr := c.writeCoverage(); werr != nil && err == nil {
+				err = fmt.Errorf("writing coverage: %w", werr)
+			}
+		}()
+	}
+
+	// Write a snapshot once everything else has been written.
+	if opts.SnapshotPath != "" {
+		defer func() {
+			if werr := c.writeSnapshot(opts.SnapshotPath); werr != nil {
+				werr = fmt.Errorf("writing snapshot: %v", werr)
+				if err == nil {
+					err = werr
+				} else {
+					err = fmt.Errorf("%w\n%v", err, werr)
+				}
+			}
+		}()
+	}
+
+	// Wait for opts.OnCrash to return for every crasher, including those
+	// written by the deferred calls below.
+	defer c.crashHooks.Wait()
+
 	// Ensure that any crash we find is written to the corpus, even if an error
 	// or interruption occurs while minimizing it.
 	crashWritten := false
@@ -141,11 +749,13 @@ func CoordinateFuzzing(ctx context.Context, opts CoordinateFuzzingOpts) (err err
 		if c.crashMinimizing == nil || crashWritten {
 			return
 		}
-		werr := writeToCorpus(&c.crashMinimizing.entry, opts.CorpusDir)
+		werr := c.writeCrasher(c.crashMinimizing)
 		if werr != nil {
 			err = fmt.Errorf("%w\n%v", err, werr)
 			return
 		}
+		c.recordCrasher(c.crashMinimizing)
+		c.runCrashHook(c.crashMinimizing)
 		if err == nil {
 			err = &crashError{
 				path: c.crashMinimizing.entry.Path,
@@ -154,25 +764,58 @@ func CoordinateFuzzing(ctx context.Context, opts CoordinateFuzzingOpts) (err err
 		}
 	}()
 
+	var flushC chan chan error
+	if opts.Flusher != nil {
+		flushC = opts.Flusher.reqC
+	}
+
+	// pendingEval is an input from opts.Evaluator waiting for a worker.
+	var pendingEval *evalRequest
+
+	// Write interesting values held in memory to the cache once workers have
+	// stopped. This also runs after an interruption, so the values aren't lost.
+	defer func() {
+		if werr := c.flushCorpus(); werr != nil {
+			if err == nil {
+				err = werr
+			} else {
+				err = fmt.Errorf("%w\n%v", err, werr)
+			}
+		}
+	}()
+
+	c.warmupInProcess()
+
 	// Start workers.
 	// TODO(jayconrod): do we want to support fuzzing different binaries?
 	dir := "" // same as self
 	binPath := os.Args[0]
 	args := append([]string{"-test.fuzzworker"}, os.Args[1:]...)
-	env := os.Environ() // same as self
+	env := append(os.Environ(), opts.WorkerEnv...) // same as self, plus WorkerEnv
 
 	errC := make(chan error)
-	workers := make([]*worker, opts.Parallel)
+	workers = make([]*worker, opts.Parallel)
 	for i := range workers {
 		var err error
 		workers[i], err = newWorker(c, dir, binPath, args, env)
 		if err != nil {
 			return err
 		}
+		workers[i].id = i
 	}
+	delays := c.workerStartDelays(len(workers))
 	for i := range workers {
 		w := workers[i]
+		delay := delays[i]
 		go func() {
+			if delay > 0 {
+				t := time.NewTimer(delay)
+				select {
+				case <-t.C:
+				case <-fuzzCtx.Done():
+					t.Stop()
+				}
+			}
 			err := w.coordinate(fuzzCtx)
 			if fuzzCtx.Err() != nil || isInterruptError(err) {
 				err = nil
@@ -194,11 +837,60 @@ func CoordinateFuzzing(ctx context.Context, opts CoordinateFuzzingOpts) (err err
 	defer c.logStats()
 
 	c.logStats()
+	c.noteActivity()
 	for {
+		if len(c.seedCrashers) > 0 && !c.warmupRun() && !c.seedCrashesReported && !stopping {
+			c.seedCrashesReported = true
+			c.logf(LogWarn, "fuzz: %d seed corpus entries crashed\n", len(c.seedCrashers))
+			if c.opts.FailOnSeedCrash {
+				stop(errors.New(c.seedCrashers[0].Err))
+				continue
+			}
+			// Fuzz the rest of the corpus. Inputs derived from the crashing
+			// seeds would most likely crash the same way.
+			for _, sc := range c.seedCrashers {
+				c.removeCorpusEntry(sc.Path)
+			}
+			if len(c.corpus.entries) == 0 {
+				stop(errors.New(c.seedCrashers[0].Err))
+				continue
+			}
+		}
+		if c.opts.CorpusOnly && !c.warmupRun() && !stopping {
+			// Every corpus entry has been tested. Report the coverage they
+			// reach together instead of fuzzing.
+			c.logf(LogInfo, "fuzz: elapsed: %s, corpus of %d entries covers %d coverage bits\n", c.elapsed(), len(c.corpus.entries), c.baselineCoverageBits)
+			stop(nil)
+		}
+
+		if c.replay != nil && !c.warmupRun() && !stopping {
+			if done, err := c.replayDone(); done {
+				if err == nil {
+					c.logf(LogInfo, "fuzz: elapsed: %s, replayed %d recorded calls\n", c.elapsed(), c.replay.read)
+				}
+				stop(err)
+			}
+		}
+
+		if stopping && len(c.coverageBatch) > 0 {
+			// Keep the values found before fuzzing stopped, without
+			// minimizing them.
+			if err := c.processCoverageBatch(false); err != nil {
+				stop(err)
+			}
+		}
+
 		var inputC chan fuzzInput
-		input, ok := c.peekInput()
-		if ok && c.crashMinimizing == nil && !stopping {
-			inputC = c.inputC
+		var input fuzzInput
+		if !stopping {
+			// The corpus may be empty while stopping, if every seed crashed.
+			var ok bool
+			input, ok = c.peekInput()
+			// While new coverage is held in a batch, wait for it to be
+			// handled, so it's minimized before fuzzing uses up the limit.
+			if ok && c.crashMinimizing == nil && len(c.coverageBatch) == 0 {
+				inputC = c.inputC
+			}
 		}
 
 		var minimizeC chan fuzzMinimizeInput
@@ -207,6 +899,18 @@ func CoordinateFuzzing(ctx context.Context, opts CoordinateFuzzingOpts) (err err
 			minimizeC = c.minimizeC
 		}
 
+		var evalReqC chan evalRequest
+		var evalC chan fuzzInput
+		var evalIn fuzzInput
+		if opts.Evaluator != nil && !stopping {
+			if pendingEval == nil {
+				evalReqC = opts.Evaluator.reqC
+			} else if c.crashMinimizing == nil {
+				evalC = c.inputC
+				evalIn = evalInput(*pendingEval)
+			}
+		}
+
 		select {
 		case <-doneC:
 			// Interrupted, cancelled, or timed out.
@@ -218,23 +922,107 @@ func CoordinateFuzzing(ctx context.Context, opts CoordinateFuzzingOpts) (err err
 			stop(err)
 			activeWorkers--
 			if activeWorkers == 0 {
+				if len(c.coverageBatch) > 0 {
+					if err := c.processCoverageBatch(false); err != nil {
+						stop(err)
+					}
+				}
 				return fuzzErr
 			}
 
 		case result := <-c.resultC:
 			// Received response from worker.
+			c.noteActivity()
 			if stopping {
+				if result.crasherMsg != "" && c.minimizedCrasher(result) {
+					// Minimization was interrupted, but the worker may have
+					// made progress. Keep the partially minimized crasher so
+					// it's written instead of the original.
+					c.crashMinimizing.entry = result.entry
+				}
 				break
 			}
 			c.updateStats(result)
-
+			if result.parseErr != "" {
+				// The entry is corrupted or was written in a format this
+				// version doesn't understand. Stop using it and move on.
+				c.logf(LogInfo, "fuzz: skipping %s: %s\n", result.entry.Path, result.parseErr)
+				c.removeCorpusEntry(result.entry.Path)
+				if c.warmupRun() {
+					c.warmupInputLeft--
+					if c.warmupInputLeft == 0 {
+						c.baselineCoverageBits = countBits(c.coverageMask)
+					}
+				}
+				if len(c.corpus.entries) == 0 {
+					stop(errors.New("fuzz: no corpus entries could be parsed"))
+				}
+				break
+			}
+			if result.feedback != nil && improvesFeedback(c.bestFeedback, *result.feedback) {
+				// The input produced a better score than any before it.
+				// Save it unless it's already being handled below because it
+				// crashed or expanded coverage.
+				v := *result.feedback
+				c.bestFeedback = &v
+				if !c.warmupRun() && result.crasherMsg == "" && result.coverageData == nil {
+					if err := c.addInteresting(&result); err != nil {
+						stop(err)
+					}
+				}
+			}
+			if result.warning != "" && !c.warned[result.warning] {
+				c.logf(LogWarn, "warning: %s\n", result.warning)
+				c.warned[result.warning] = true
+			}
+			c.crashMinimizeDuration += result.minimizeDuration
 			if result.crasherMsg != "" {
+				// Record when the crash was first found. A minimized crasher
+				// was found when the input it was minimized from was.
+				if c.minimizedCrasher(result) {
+					result.found = c.crashMinimizing.found
+				} else if result.found.IsZero() {
+					result.found = time.Now()
+				}
+				if result.signature == "" {
+					result.signature = crashSignature(result.crasherMsg)
+				}
+				if result.signature == "" && c.minimizedCrasher(result) {
+					// The minimized crasher may have terminated the worker
+					// process without a trace.
+					result.signature = c.crashMinimizing.signature
+				}
+
 				if c.warmupRun() && result.entry.IsSeed {
+					// Keep testing the rest of the seed corpus, so all the
+					// seeds that crash are reported together once warmup
+					// finishes.
 					target := filepath.Base(c.opts.CorpusDir)
-					fmt.Fprintf(c.opts.Log, "found a crash while testing seed corpus entry: %s/%s\n", target, testName(result.entry.Parent))
-					stop(errors.New(result.crasherMsg))
+					c.logf(LogWarn, "found a crash while testing seed corpus entry: %s/%s\n", target, testName(result.entry.Parent))
+					c.seedCrashers = append(c.seedCrashers, Crasher{Path: result.entry.Parent, Err: result.crasherMsg, Signature: result.signature})
+					c.warmupInputLeft--
+					if c.warmupInputLeft == 0 {
+						c.baselineCoverageBits = countBits(c.coverageMask)
+					}
+					break
+				}
+				if crashWritten {
+					// Fuzzing is stopping once the crasher is written.
 					break
 				}
+				if !result.minimized && c.opts.CrasherFilter != nil && !c.opts.CrasherFilter(result.entry, result.crasherMsg) {
+					// The caller already knows about this crash. Ignore it
+					// and keep fuzzing.
+					c.filteredCrashers++
+					break
+				}
+				if c.canMinimize() && result.canMinimize && c.minimizeBudgetSpent() {
+					if !c.minimizeBudgetLogged {
+						c.logf(LogInfo, "fuzz: spent minimization budget of %v; saving crashers without minimizing\n", c.opts.TotalMinimizeBudget)
+						c.minimizeBudgetLogged = true
+					}
+					result.canMinimize = false
+				}
 				if c.canMinimize() && result.canMinimize {
 					if c.crashMinimizing != nil {
 						// This crash is not minimized, and another crash is being minimized.
@@ -245,22 +1033,22 @@ func CoordinateFuzzing(ctx context.Context, opts CoordinateFuzzingOpts) (err err
 					// Send it back to a worker for minimization. Disable inputC so
 					// other workers don't continue fuzzing.
 					c.crashMinimizing = &result
-					fmt.Fprintf(c.opts.Log, "fuzz: minimizing %d-byte crash input...\n", len(result.entry.Data))
+					c.logf(LogInfo, "fuzz: minimizing %d-byte crash input...\n", len(result.entry.Data))
 					c.queueForMinimization(result, nil)
 				} else if !crashWritten {
 					// Found a crasher that's either minimized or not minimizable.
 					// Write to corpus and stop.
-					err := writeToCorpus(&result.entry, opts.CorpusDir)
+					if result.unverified {
+						c.logf(LogWarn, "warning: minimized crasher did not reproduce in a new fuzzing process; recording the %d-byte input as it was found\n", len(result.entry.Data))
+					}
+					err := c.writeCrasher(&result)
 					if err == nil {
 						crashWritten = true
-						err = &crashError{
-							path: result.entry.Path,
-							err:  errors.New(result.crasherMsg),
-						}
+						err = c.crasherWritten(&result)
 					}
-					if shouldPrintDebugInfo() {
-						fmt.Fprintf(
-							c.opts.Log,
+					if c.logEnabled(LogDebug) {
+						c.logf(
+							LogDebug,
 							"DEBUG new crasher, elapsed: %s, id: %s, parent: %s, gen: %d, size: %d, exec time: %s\n",
 							c.elapsed(),
 							result.entry.Path,
@@ -274,24 +1062,30 @@ func CoordinateFuzzing(ctx context.Context, opts CoordinateFuzzingOpts) (err err
 				}
 			} else if result.coverageData != nil {
 				if c.warmupRun() {
-					if shouldPrintDebugInfo() {
-						fmt.Fprintf(
-							c.opts.Log,
+					if c.logEnabled(LogDebug) {
+						c.logf(
+							LogDebug,
 							"DEBUG processed an initial input, elapsed: %s, id: %s, new bits: %d, size: %d, exec time: %s\n",
 							c.elapsed(),
-							result.entry.Parent,
+							result.inputPath,
 							countBits(diffCoverage(c.coverageMask, result.coverageData)),
 							len(result.entry.Data),
 							result.entryDuration,
 						)
 					}
+					c.recordEntryCoverage(result.inputPath, result.coverageData)
+					c.reportNewCoverage(result.coverageData, result.inputPath)
 					c.updateCoverage(result.coverageData)
+					if c.reachesFocus(result.coverageData) {
+						c.focused[result.inputPath] = true
+					}
 					c.warmupInputLeft--
 					if c.warmupInputLeft == 0 {
-						fmt.Fprintf(c.opts.Log, "fuzz: elapsed: %s, gathering baseline coverage: %d/%d completed, now fuzzing with %d workers\n", c.elapsed(), c.warmupInputCount, c.warmupInputCount, c.opts.Parallel)
-						if shouldPrintDebugInfo() {
-							fmt.Fprintf(
-								c.opts.Log,
+						c.baselineCoverageBits = countBits(c.coverageMask)
+						c.logf(LogInfo, "fuzz: elapsed: %s, gathering baseline coverage: %d/%d completed, now fuzzing with %d workers\n", c.elapsed(), c.warmupInputCount, c.warmupInputCount, c.opts.Parallel)
+						if c.logEnabled(LogDebug) {
+							c.logf(
+								LogDebug,
 								"DEBUG finished processing input corpus, elapsed: %s, entries: %d, initial coverage bits: %d\n",
 								c.elapsed(),
 								len(c.corpus.entries),
@@ -299,69 +1093,22 @@ func CoordinateFuzzing(ctx context.Context, opts CoordinateFuzzingOpts) (err err
 							)
 						}
 					}
-				} else if keepCoverage := diffCoverage(c.coverageMask, result.coverageData); keepCoverage != nil {
-					// Found a value that expanded coverage.
-					// It's not a crasher, but we may want to add it to the on-disk
-					// corpus and prioritize it for future fuzzing.
-					// TODO(jayconrod, katiehockman): Prioritize fuzzing these
-					// values which expanded coverage, perhaps based on the
-					// number of new edges that this result expanded.
-					// TODO(jayconrod, katiehockman): Don't write a value that's already
-					// in the corpus.
-					if c.canMinimize() && result.canMinimize && c.crashMinimizing == nil {
-						// Send back to workers to find a smaller value that preserves
-						// at least one new coverage bit.
-						c.queueForMinimization(result, keepCoverage)
-					} else {
-						// Update the coordinator's coverage mask and save the value.
-						inputSize := len(result.entry.Data)
-						if opts.CacheDir != "" {
-							err := writeToCorpus(&result.entry, opts.CacheDir)
-							if err != nil {
-								stop(err)
-							}
-							result.entry.Data = nil
-						}
-						c.updateCoverage(keepCoverage)
-						c.corpus.entries = append(c.corpus.entries, result.entry)
-						c.inputQueue.enqueue(result.entry)
-						c.interestingCount++
-						if shouldPrintDebugInfo() {
-							fmt.Fprintf(
-								c.opts.Log,
-								"DEBUG new interesting input, elapsed: %s, id: %s, parent: %s, gen: %d, new bits: %d, total bits: %d, size: %d, exec time: %s\n",
-								c.elapsed(),
-								result.entry.Path,
-								result.entry.Parent,
-								result.entry.Generation,
-								countBits(keepCoverage),
-								countBits(c.coverageMask),
-								inputSize,
-								result.entryDuration,
-							)
-						}
-					}
-				} else {
-					if shouldPrintDebugInfo() {
-						fmt.Fprintf(
-							c.opts.Log,
-							"DEBUG worker reported interesting input that doesn't expand coverage, elapsed: %s, id: %s, parent: %s, canMinimize: %t\n",
-							c.elapsed(),
-							result.entry.Path,
-							result.entry.Parent,
-							result.canMinimize,
-						)
-					}
+				} else if !result.minimized && c.coverageDedupWindow() > 0 {
+					// Hold the result briefly, so reports of the same new
+					// coverage from several workers are handled once.
+					c.batchCoverage(result)
+				} else if err := c.handleCoverage(result); err != nil {
+					stop(err)
 				}
 			} else if c.warmupRun() {
 				// No error or coverage data was reported for this input during
 				// warmup, so continue processing results.
 				c.warmupInputLeft--
 				if c.warmupInputLeft == 0 {
-					fmt.Fprintf(c.opts.Log, "fuzz: elapsed: %s, testing seed corpus: %d/%d completed, now fuzzing with %d workers\n", c.elapsed(), c.warmupInputCount, c.warmupInputCount, c.opts.Parallel)
-					if shouldPrintDebugInfo() {
-						fmt.Fprintf(
-							c.opts.Log,
+					c.logf(LogInfo, "fuzz: elapsed: %s, testing seed corpus: %d/%d completed, now fuzzing with %d workers\n", c.elapsed(), c.warmupInputCount, c.warmupInputCount, c.opts.Parallel)
+					if c.logEnabled(LogDebug) {
+						c.logf(
+							LogDebug,
 							"DEBUG finished testing-only phase, elapsed: %s, entries: %d\n",
 							time.Since(c.startTime),
 							len(c.corpus.entries),
@@ -372,10 +1119,16 @@ func CoordinateFuzzing(ctx context.Context, opts CoordinateFuzzingOpts) (err err
 
 			// Once the result has been processed, stop the worker if we
 			// have reached the fuzzing limit.
-			if c.opts.Limit > 0 && c.count >= c.opts.Limit {
+			if limit := c.execLimit(); limit > 0 && c.count >= limit {
 				stop(nil)
 			}
 
+		case <-c.coverageBatchC():
+			// The dedup window for the batched results has passed.
+			if err := c.processCoverageBatch(true); err != nil {
+				stop(err)
+			}
+
 		case inputC <- input:
 			// Sent the next input to a worker.
 			c.sentInput(input)
@@ -386,6 +1139,36 @@ func CoordinateFuzzing(ctx context.Context, opts CoordinateFuzzingOpts) (err err
 
 		case <-statTicker.C:
 			c.logStats()
+			if err := c.checkExecRate(); err != nil {
+				stop(err)
+			}
+			c.checkSkippedOversize()
+			c.checkCoverageUtilization()
+			c.checkFlakyDeflakes()
+			c.checkSlowEntries()
+			c.flushPeriodically()
+			c.compactPeriodically()
+			if !stopping {
+				c.checkIdleWorkers()
+			}
+			if c.opts.MetricsFile != "" {
+				if err := c.writeMetrics(); err != nil && !c.warned["metrics"] {
+					c.logf(LogWarn, "warning: writing metrics: %v\n", err)
+					c.warned["metrics"] = true
+				}
+			}
+
+		case errC := <-flushC:
+			errC <- c.flushCorpus()
+
+		case req := <-evalReqC:
+			pendingEval = &req
+
+		case evalC <- evalIn:
+			// Sent an input from opts.Evaluator to a worker, which sends the
+			// result back to the Evaluator.
+			c.noteActivity()
+			pendingEval = nil
 		}
 	}
 
@@ -415,6 +1198,10 @@ func (e *crashError) CrashPath() string {
 
 type corpus struct {
 	entries []CorpusEntry
+
+	// skipped lists the files in the cache that couldn't be read or parsed
+	// when the corpus was loaded, if readCache was asked to skip them.
+	skipped []*corpusFileError
 }
 
 // CorpusEntry represents an individual input for fuzzing.
@@ -476,6 +1263,30 @@ type fuzzInput struct {
 
 	// coverageData reflects the coordinator's current coverageMask.
 	coverageData []byte
+
+	// checkGoroutines indicates whether the worker should watch for leaked
+	// goroutines while fuzzing.
+	checkGoroutines bool
+
+	// bestFeedback is the highest score reported with ReportFeedback so far,
+	// or nil if none has been reported.
+	bestFeedback *int64
+
+	// splice is another corpus entry that the worker should splice with entry
+	// before mutating it, or nil.
+	splice *CorpusEntry
+
+	// generated is true if entry was created with opts.Generator rather
+	// than taken from the input queue.
+	generated bool
+
+	// evalC, if not nil, receives the result instead of the coordinator: the
+	// input was sent to be run for an Evaluator.
+	evalC chan fuzzResult
+
+	// replay, if not nil, is the recorded call in opts.ReplaySessionPath the
+	// worker should repeat.
+	replay *sessionCall
 }
 
 type fuzzResult struct {
@@ -504,6 +1315,80 @@ type fuzzResult struct {
 
 	// entryDuration is the time the worker spent execution an interesting result
 	entryDuration time.Duration
+
+	// minimizeDuration is the time the worker spent minimizing, if this is
+	// the result of minimizing a crasher, whether or not it succeeded.
+	minimizeDuration time.Duration
+
+	// minimized is true if this is the result of minimization, even if
+	// minimization was interrupted.
+	minimized bool
+
+	// env is the list of environment settings, like GOMAXPROCS, that the
+	// worker process ran with when a crasher was found.
+	env []string
+
+	// warning is a message from the worker about a likely problem with the
+	// fuzz function, like leaking goroutines. It's "" if there is none.
+	warning string
+
+	// feedback is the score reported by the fuzz function with ReportFeedback
+	// if it was higher than the score the worker was given. It's nil otherwise.
+	feedback *int64
+
+	// crashCoverage is the coverage snapshot taken after the call to the fuzz
+	// function that crashed, if coverage is enabled and the call returned.
+	// For a minimized crasher, it's the snapshot for the original input.
+	crashCoverage []byte
+
+	// parseErr is set if the worker couldn't decode entry. The coordinator
+	// skips the entry instead of treating it as a crasher.
+	parseErr string
+
+	// found is when the coordinator received the crasher, or the crasher it
+	// was minimized from. It's only set for crashers.
+	found time.Time
+
+	// unverified is set if a minimized crasher didn't cause an error when it
+	// was run again in a new worker process, so entry is the crasher as it
+	// was found instead. See opts.VerifyMinimizedCrashers.
+	unverified bool
+
+	// reproduceRuns is the number of times a crasher was run again in a new
+	// worker process, and reproduced is the number of those runs that failed.
+	// See opts.CrasherReproduceRuns.
+	reproduceRuns, reproduced int
+
+	// signature is the stack signature of a crasher. The worker sets it from
+	// the output of a worker process that terminated; otherwise, the
+	// coordinator derives it from crasherMsg. See Crasher.Signature.
+	signature string
+
+	// inputPath is the path of the corpus entry the worker was given. Unless
+	// the result is from minimization, count and totalDuration are attributed
+	// to it.
+	inputPath string
+
+	// deflakes and flakes are the number of runs the worker made to confirm
+	// new coverage and the number that didn't reproduce it. See
+	// fuzzResponse.Deflakes.
+	deflakes, flakes int64
+
+	// derivation records how an interesting value was derived from the
+	// input the worker was given. It's only set with opts.RecordDerivation.
+	derivation *entryDerivation
+
+	// skippedOversize is the number of mutated values, included in count,
+	// that the worker didn't test because they exceeded opts.MaxInputSize.
+	skippedOversize int64
+
+	// mutatorOps is the number of times each mutation operator was applied
+	// by the worker, indexed by operator number. See fuzzResponse.MutatorOps.
+	mutatorOps []int64
+
+	// crashSignal is the signal that terminated the worker process while it
+	// was testing the crasher, if any.
+	crashSignal os.Signal
 }
 
 type fuzzMinimizeInput struct {
@@ -529,6 +1414,20 @@ type fuzzMinimizeInput struct {
 	// input that preserves at least one of these bits. keepCoverage is nil for
 	// crashing inputs.
 	keepCoverage []byte
+
+	// env is the list of environment settings the crasher was found with.
+	// The worker process should use the same settings while minimizing.
+	env []string
+
+	// crashCoverage is the coverage snapshot taken when the crasher was found.
+	// It's passed back with the minimized crasher.
+	crashCoverage []byte
+
+	// crashSignal is the signal that terminated the worker process when the
+	// crasher was found, if any. If set, the crasher is minimized by the
+	// coordinator, keeping only candidates that terminate the worker process
+	// with the same signal. See worker.minimizeSignal.
+	crashSignal os.Signal
 }
 
 // coordinator holds channels that workers can use to communicate with
@@ -585,6 +1484,10 @@ type coordinator struct {
 	// waiting on workers to complete.
 	countWaiting int64
 
+	// warmupCount is the number of calls to the fuzz function made while
+	// testing the corpus. It doesn't count toward opts.TargetExecLimit.
+	warmupCount int64
+
 	// corpus is a set of interesting values, including the seed corpus and
 	// generated values that workers reported as interesting.
 	corpus corpus
@@ -606,13 +1509,187 @@ type coordinator struct {
 	// crashMinimizing is the crash that is currently being minimized.
 	crashMinimizing *fuzzResult
 
+	// crashers is the list of crashers recorded so far, reported in the
+	// summary passed to opts.OnFinish.
+	crashers []Crasher
+
+	// baselineCoverageBits is the number of coverage bits set in coverageMask
+	// when the warmup run finished.
+	baselineCoverageBits int
+
+	// crashMinimizeDuration is the total time workers have spent minimizing
+	// crashers. It's limited by opts.TotalMinimizeBudget.
+	crashMinimizeDuration time.Duration
+
+	// minimizeBudgetLogged is true after the coordinator has logged that
+	// opts.TotalMinimizeBudget was spent.
+	minimizeBudgetLogged bool
+
+	// bestFeedback is the highest score reported by the fuzz function with
+	// ReportFeedback, or nil if none has been reported.
+	bestFeedback *int64
+
+	// warned is the set of warnings from workers that have been logged.
+	warned map[string]bool
+
+	// pendingCorpus is a list of interesting values that have not yet been
+	// written to the cache because opts.DeferCorpusWrite is set.
+	pendingCorpus []CorpusEntry
+
+	// lastFlush is when pendingCorpus was last written by flushPeriodically.
+	lastFlush time.Time
+
+	// generatorRand is the source of randomness passed to opts.Generator.
+	// generated is the input created with it that will be sent to a worker
+	// next, if any, and generatedCount is the number of inputs sent.
+	generatorRand  *rand.Rand
+	generated      *CorpusEntry
+	generatedCount int64
+
+	// pendingDerivations holds the derivations of entries in pendingCorpus,
+	// by path, with opts.RecordDerivation.
+	pendingDerivations map[string]*entryDerivation
+
+	// interestingTokens is the number of values that expand coverage that may
+	// be added to the corpus now without exceeding opts.MaxInterestingPerSec.
+	// It was last refilled at interestingTokensTime.
+	interestingTokens     float64
+	interestingTokensTime time.Time
+
+	// droppedInteresting is the number of values that expanded coverage but
+	// were dropped because of opts.MaxInterestingPerSec.
+	droppedInteresting int64
+
+	// counterFinds is the number of values, kept or dropped, that expanded
+	// coverage in each counter, indexed like coverageMask. It's kept with
+	// opts.MaxInterestingPerSec to tell which counters are rare.
+	counterFinds []int32
+
+	// fuzzStartTime and fuzzStartCount are the time and count when the
+	// coordinator first noticed warmup had finished. They're used to check
+	// opts.MinExecPerSec.
+	fuzzStartTime  time.Time
+	fuzzStartCount int64
+
+	// focusMask has a bit set in each counter listed in opts.FocusCoverage.
+	// It's nil if opts.FocusCoverage is empty or coverage is disabled.
+	focusMask []byte
+
+	// focused is the set of paths of corpus entries that reach the code
+	// in focusMask.
+	focused map[string]bool
+
+	// inputsSent is the number of inputs sent to workers to fuzz. It's used
+	// to decide when to splice inputs; see spliceInterval.
+	inputsSent int64
+
+	// skippedOversize is the number of mutated inputs workers skipped because
+	// they exceeded opts.MaxInputSize. oversizeWarned is true once the
+	// coordinator has warned about it.
+	skippedOversize int64
+	oversizeWarned  bool
+
+	// utilizationWarned is true once the coordinator has warned that inputs
+	// reach little of the coverage bitmap. See checkCoverageUtilization.
+	utilizationWarned bool
+
+	// deflakes and flakes are the number of times workers ran a value that
+	// expanded coverage again to confirm it, and the number of those runs
+	// that didn't reproduce the coverage. deflakeWarned is true once the
+	// coordinator has warned about them.
+	deflakes, flakes int64
+	deflakeWarned    bool
+
+	// coverageBatch holds results reporting new coverage, received within
+	// the dedup window that coverageBatchTimer ends. dedupedCoverage is the
+	// number of them dropped because other results in their batch reported
+	// the same coverage. See processCoverageBatch.
+	coverageBatch      []fuzzResult
+	coverageBatchTimer *time.Timer
+	dedupedCoverage    int64
+
+	// lastActivity is when the coordinator last sent an input to a worker or
+	// received a result. idleWarned is true once the coordinator has warned
+	// that workers have been idle since then. See checkIdleWorkers.
+	lastActivity time.Time
+	idleWarned   bool
+
+	// entryTimes is the time spent fuzzing each corpus entry, by path. See
+	// checkSlowEntries.
+	entryTimes map[string]entryTime
+
+	// mutatorApplied and mutatorFinds are the number of times each mutation
+	// operator was applied, and the number of inputs that expanded coverage
+	// it was applied to, indexed by operator number. They're allocated by
+	// addMutatorOps.
+	mutatorApplied, mutatorFinds []int64
+
+	// workerRestarts is the number of times worker processes were restarted.
+	// Workers update it atomically. countLastMetrics and timeLastMetrics are
+	// the number of calls and the time when metrics were last written to
+	// opts.MetricsFile.
+	workerRestarts   int64
+	countLastMetrics int64
+	timeLastMetrics  time.Time
+
+	// filteredCrashers is the number of crashes ignored because
+	// opts.CrasherFilter returned false.
+	filteredCrashers int64
+
+	// entryNewBits records the number of coverage bits each corpus entry added
+	// when it was added, by path. It's only kept for opts.SnapshotPath and
+	// opts.MaxCorpusDiskBytes.
+	entryNewBits map[string]int
+
+	// entryCoverage, entrySize, and corpusDiskBytes are kept for
+	// opts.MaxCorpusDiskBytes and opts.CompactInterval. entryCoverage is the
+	// coverage snapshot of each corpus entry, by path. entrySize is the size
+	// of each file in opts.CacheDir, and corpusDiskBytes is their total size.
+	// evictedEntries is the number of entries removed to stay within the
+	// limit, and compactedEntries the number removed by compactCorpus.
+	entryCoverage    map[string][]byte
+	entrySize        map[string]int64
+	corpusDiskBytes  int64
+	evictedEntries   int64
+	compactedEntries int64
+
+	// lastCompact is when the corpus was last compacted by
+	// compactPeriodically.
+	lastCompact time.Time
+
+	// seedCrashers lists the seed corpus entries that crashed during warmup.
+	// seedCrashesReported is true once they've been reported.
+	seedCrashers        []Crasher
+	seedCrashesReported bool
+
+	// namedEntries is the number of entries named by opts.NamingScheme, and
+	// entryPaths is the set of paths it was used to choose.
+	namedEntries int
+	entryPaths   map[string]bool
+
+	// runTag names this run's files if opts.IsolateRun is set. See
+	// cacheWriteDir.
+	runTag string
+
 	// coverageMask aggregates coverage that was found for all inputs in the
 	// corpus. Each byte represents a single basic execution block. Each set bit
-	// within the byte indicates that an input has triggered that block at least
-	// 1 << n times, where n is the position of the bit in the byte. For example, a
-	// value of 12 indicates that separate inputs have triggered this block
-	// between 4-7 times and 8-15 times.
+	// within the byte indicates that an input has triggered that block a
+	// number of times in the bit's bucket: 1, 2, 3, 4-7, 8-15, 16-31, 32-127,
+	// or 128-255, from the lowest bit. For example, a value of 24 indicates
+	// that separate inputs have triggered this block between 4-7 times and
+	// 8-15 times. See counterBuckets.
 	coverageMask []byte
+
+	// sessionRec records the calls workers make, with opts.RecordSessionPath.
+	sessionRec *sessionRecorder
+
+	// replay reads the calls to repeat, with opts.ReplaySessionPath.
+	replay *sessionReader
+
+	// crashHooks tracks the calls to opts.OnCrash that haven't returned, and
+	// hookedSignatures is the set of stack signatures they were made for.
+	crashHooks       sync.WaitGroup
+	hookedSignatures map[string]bool
 }
 
 func newCoordinator(opts CoordinateFuzzingOpts) (*coordinator, error) {
@@ -622,10 +1699,77 @@ func newCoordinator(opts CoordinateFuzzingOpts) (*coordinator, error) {
 			opts.Seed[i].Data = marshalCorpusFile(opts.Seed[i].Values...)
 		}
 	}
-	corpus, err := readCache(opts.Seed, opts.Types, opts.CacheDir)
+	opts.Seed = dedupCorpus(opts.Seed)
+	if opts.WorkerRSSLimit > 0 && runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("WorkerRSSLimit is not supported on %s", runtime.GOOS)
+	}
+	if err := checkMutatorWeights(opts.MutatorWeights); err != nil {
+		return nil, err
+	}
+	if err := checkInputSchemas(opts.InputSchemas, opts.Types); err != nil {
+		return nil, err
+	}
+	if opts.MaxMutatedLen < 0 {
+		return nil, fmt.Errorf("MaxMutatedLen %d is negative", opts.MaxMutatedLen)
+	}
+	if opts.MinMutatedLen < 0 {
+		return nil, fmt.Errorf("MinMutatedLen %d is negative", opts.MinMutatedLen)
+	}
+	if opts.MaxMutatedLen > 0 && opts.MinMutatedLen > opts.MaxMutatedLen {
+		return nil, fmt.Errorf("MinMutatedLen %d is greater than MaxMutatedLen %d", opts.MinMutatedLen, opts.MaxMutatedLen)
+	}
+	if opts.MaxMinimizeAttempts < 0 {
+		return nil, fmt.Errorf("MaxMinimizeAttempts %d is negative", opts.MaxMinimizeAttempts)
+	}
+	if opts.CrasherReproduceRuns < 0 {
+		return nil, fmt.Errorf("CrasherReproduceRuns %d is negative", opts.CrasherReproduceRuns)
+	}
+	if opts.WorkerMaxThreads < 0 {
+		return nil, fmt.Errorf("WorkerMaxThreads %d is negative", opts.WorkerMaxThreads)
+	}
+	if opts.WorkerMaxOpenFiles < 0 {
+		return nil, fmt.Errorf("WorkerMaxOpenFiles %d is negative", opts.WorkerMaxOpenFiles)
+	}
+	if opts.WorkerMaxOpenFiles > 0 && runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		return nil, fmt.Errorf("WorkerMaxOpenFiles is not supported on %s", runtime.GOOS)
+	}
+	if opts.Generator != nil {
+		if len(opts.Types) != 1 || (opts.Types[0] != reflect.TypeOf([]byte(nil)) && opts.Types[0].Kind() != reflect.String) {
+			return nil, errors.New("Generator requires a fuzz target with a single []byte or string argument")
+		}
+		if opts.GenerateInterval < 0 {
+			return nil, fmt.Errorf("GenerateInterval %d is negative", opts.GenerateInterval)
+		}
+	}
+	if opts.RawCorpus && (len(opts.Types) != 1 || opts.Types[0] != reflect.TypeOf([]byte(nil))) {
+		return nil, errors.New("RawCorpus requires a fuzz target with a single []byte argument")
+	}
+	if opts.SharedMemDir != "" {
+		if err := checkWritableDir(opts.SharedMemDir); err != nil {
+			return nil, fmt.Errorf("SharedMemDir: %v", err)
+		}
+	}
+	if err := setFeedbackSource(opts.FeedbackSource); err != nil {
+		return nil, err
+	}
+	var focusMask []byte
+	if len(opts.FocusCoverage) > 0 && coverageEnabled {
+		focusMask = make([]byte, len(coverageSnapshot))
+		for _, i := range opts.FocusCoverage {
+			if i < 0 || i >= len(focusMask) {
+				return nil, fmt.Errorf("focus coverage counter %d out of range; there are %d counters", i, len(focusMask))
+			}
+			focusMask[i] = 0xff
+		}
+	}
+	cacheDirs := append([]string{opts.CacheDir}, opts.ExtraCacheDirs...)
+	corpus, err := readCache(opts.Seed, opts.Types, cacheDirs, opts.SkipCorpusErrors, opts.RawCorpus)
 	if err != nil {
 		return nil, err
 	}
+	if opts.DeterministicOrder {
+		sortCorpus(corpus.entries)
+	}
 	c := &coordinator{
 		opts:        opts,
 		startTime:   time.Now(),
@@ -634,8 +1778,42 @@ func newCoordinator(opts CoordinateFuzzingOpts) (*coordinator, error) {
 		resultC:     make(chan fuzzResult),
 		corpus:      corpus,
 		timeLastLog: time.Now(),
+		warned:      make(map[string]bool),
+		focusMask:   focusMask,
+		focused:     make(map[string]bool),
+
+		entryNewBits:  make(map[string]int),
+		entryCoverage: make(map[string][]byte),
+		entrySize:     make(map[string]int64),
 	}
-	if opts.MinimizeLimit > 0 || opts.MinimizeTimeout > 0 {
+	if opts.IsolateRun {
+		c.runTag = newRunTag(opts)
+	}
+	if opts.Generator != nil {
+		seed := int64(c.runSeed("generator"))
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		c.generatorRand = rand.New(rand.NewSource(seed))
+	}
+	if opts.IdleWorkersTimeout < 0 {
+		return nil, fmt.Errorf("IdleWorkersTimeout %v is negative", opts.IdleWorkersTimeout)
+	}
+	if opts.CompactInterval < 0 {
+		return nil, fmt.Errorf("CompactInterval %v is negative", opts.CompactInterval)
+	}
+	if opts.CompactInterval > 0 && opts.CacheDir == "" {
+		return nil, errors.New("CompactInterval is set, but CacheDir is not")
+	}
+	if opts.MaxCorpusDiskBytes > 0 || opts.CompactInterval > 0 {
+		if opts.CacheDir == "" {
+			return nil, errors.New("MaxCorpusDiskBytes is set, but CacheDir is not")
+		}
+		if err := c.loadCorpusDiskUsage(); err != nil {
+			return nil, err
+		}
+	}
+	if !opts.NoMinimize && (opts.MinimizeLimit > 0 || opts.MinimizeTimeout > 0) {
 		for _, t := range opts.Types {
 			if isMinimizable(t) {
 				c.minimizationAllowed = true
@@ -644,9 +1822,9 @@ func newCoordinator(opts CoordinateFuzzingOpts) (*coordinator, error) {
 		}
 	}
 
-	covSize := len(coverage())
+	covSize := len(coverageSnapshot)
 	if covSize == 0 {
-		fmt.Fprintf(c.opts.Log, "warning: the test binary was not built with coverage instrumentation, so fuzzing will run without coverage guidance and may be inefficient\n")
+		c.logf(LogWarn, "warning: the test binary was not built with coverage instrumentation, so fuzzing will run without coverage guidance and may be inefficient\n")
 		// Even though a coverage-only run won't occur, we should still run all
 		// of the seed corpus to make sure there are no existing failures before
 		// we start fuzzing.
@@ -662,10 +1840,15 @@ func newCoordinator(opts CoordinateFuzzingOpts) (*coordinator, error) {
 		// Set c.coverageMask to a clean []byte full of zeros.
 		c.coverageMask = make([]byte, covSize)
 	}
+	if opts.BaselineCoveragePath != "" {
+		if err := c.loadBaselineCoverage(); err != nil {
+			return nil, err
+		}
+	}
 	c.warmupInputLeft = c.warmupInputCount
 
 	if len(c.corpus.entries) == 0 {
-		fmt.Fprintf(c.opts.Log, "warning: starting with empty corpus\n")
+		c.logf(LogWarn, "warning: starting with empty corpus\n")
 		var vals []interface{}
 		for _, t := range opts.Types {
 			vals = append(vals, zeroValue(t))
@@ -681,8 +1864,16 @@ func newCoordinator(opts CoordinateFuzzingOpts) (*coordinator, error) {
 
 func (c *coordinator) updateStats(result fuzzResult) {
 	c.count += result.count
+	if c.warmupRun() {
+		c.warmupCount += result.count
+	}
+	c.skippedOversize += result.skippedOversize
+	c.deflakes += result.deflakes
+	c.flakes += result.flakes
+	c.addMutatorOps(result.mutatorOps)
 	c.countWaiting -= result.limit
 	c.duration += result.totalDuration
+	c.recordEntryTime(result)
 }
 
 func (c *coordinator) logStats() {
@@ -690,19 +1881,19 @@ func (c *coordinator) logStats() {
 	if c.warmupRun() {
 		runSoFar := c.warmupInputCount - c.warmupInputLeft
 		if coverageEnabled {
-			fmt.Fprintf(c.opts.Log, "fuzz: elapsed: %s, gathering baseline coverage: %d/%d completed\n", c.elapsed(), runSoFar, c.warmupInputCount)
+			c.logf(LogInfo, "fuzz: elapsed: %s, gathering baseline coverage: %d/%d completed\n", c.elapsed(), runSoFar, c.warmupInputCount)
 		} else {
-			fmt.Fprintf(c.opts.Log, "fuzz: elapsed: %s, testing seed corpus: %d/%d completed\n", c.elapsed(), runSoFar, c.warmupInputCount)
+			c.logf(LogInfo, "fuzz: elapsed: %s, testing seed corpus: %d/%d completed\n", c.elapsed(), runSoFar, c.warmupInputCount)
 		}
 	} else if c.crashMinimizing != nil {
-		fmt.Fprintf(c.opts.Log, "fuzz: elapsed: %s, minimizing\n", c.elapsed())
+		c.logf(LogInfo, "fuzz: elapsed: %s, minimizing\n", c.elapsed())
 	} else {
 		rate := float64(c.count-c.countLastLog) / now.Sub(c.timeLastLog).Seconds()
 		if coverageEnabled {
 			interestingTotalCount := int64(c.warmupInputCount-len(c.opts.Seed)) + c.interestingCount
-			fmt.Fprintf(c.opts.Log, "fuzz: elapsed: %s, execs: %d (%.0f/sec), new interesting: %d (total: %d)\n", c.elapsed(), c.count, rate, c.interestingCount, interestingTotalCount)
+			c.logf(LogInfo, "fuzz: elapsed: %s, execs: %d (%.0f/sec), new interesting: %d (total: %d)\n", c.elapsed(), c.count, rate, c.interestingCount, interestingTotalCount)
 		} else {
-			fmt.Fprintf(c.opts.Log, "fuzz: elapsed: %s, execs: %d (%.0f/sec)", c.elapsed(), c.count, rate)
+			c.logf(LogInfo, "fuzz: elapsed: %s, execs: %d (%.0f/sec)", c.elapsed(), c.count, rate)
 		}
 	}
 	c.countLastLog = c.count
@@ -720,7 +1911,8 @@ func (c *coordinator) logStats() {
 // If the input queue is empty and the coverage/testing-only run has completed,
 // queue refills it from the corpus.
 func (c *coordinator) peekInput() (fuzzInput, bool) {
-	if c.opts.Limit > 0 && c.count+c.countWaiting >= c.opts.Limit {
+	limit := c.execLimit()
+	if limit > 0 && c.count+c.countWaiting >= limit {
 		// Already making the maximum number of calls to the fuzz function.
 		// Don't send more inputs right now.
 		return fuzzInput{}, false
@@ -739,9 +1931,11 @@ func (c *coordinator) peekInput() (fuzzInput, bool) {
 		panic("input queue empty after refill")
 	}
 	input := fuzzInput{
-		entry:   entry.(CorpusEntry),
-		timeout: workerFuzzDuration,
-		warmup:  c.warmupRun(),
+		entry:           entry.(CorpusEntry),
+		timeout:         workerFuzzDuration,
+		warmup:          c.warmupRun(),
+		checkGoroutines: c.opts.DetectGoroutineLeaks,
+		bestFeedback:    c.bestFeedback,
 	}
 	if c.coverageMask != nil {
 		input.coverageData = make([]byte, len(c.coverageMask))
@@ -753,13 +1947,35 @@ func (c *coordinator) peekInput() (fuzzInput, bool) {
 		input.limit = 1
 		return input, true
 	}
+	if c.replay != nil {
+		return c.replayInput(input)
+	}
+
+	if c.generateTurn() {
+		if c.generated == nil {
+			// Generate the input once, however many times it's peeked at
+			// before it's sent.
+			c.generated = c.generateEntry()
+		}
+		input.entry = *c.generated
+		input.generated = true
+	}
 
-	if c.opts.Limit > 0 {
-		input.limit = c.opts.Limit / int64(c.opts.Parallel)
-		if c.opts.Limit%int64(c.opts.Parallel) > 0 {
+	if c.inputsSent%spliceInterval == spliceInterval-1 && len(c.corpus.entries) > 1 {
+		// Occasionally splice the input with another entry. Cycle through the
+		// corpus so that each entry gets a turn.
+		i := int(c.inputsSent/spliceInterval) % len(c.corpus.entries)
+		if other := c.corpus.entries[i]; other.Path != input.entry.Path {
+			input.splice = &other
+		}
+	}
+
+	if limit > 0 {
+		input.limit = limit / int64(c.opts.Parallel)
+		if limit%int64(c.opts.Parallel) > 0 {
 			input.limit++
 		}
-		remaining := c.opts.Limit - c.count - c.countWaiting
+		remaining := limit - c.count - c.countWaiting
 		if input.limit > remaining {
 			input.limit = remaining
 		}
@@ -767,18 +1983,264 @@ func (c *coordinator) peekInput() (fuzzInput, bool) {
 	return input, true
 }
 
+// defaultGenerateInterval is the default for
+// CoordinateFuzzingOpts.GenerateInterval.
+const defaultGenerateInterval = 4
+
+// generateTurn returns whether the next input sent to a worker should be
+// created with opts.Generator.
+func (c *coordinator) generateTurn() bool {
+	if c.opts.Generator == nil {
+		return false
+	}
+	n := int64(c.opts.GenerateInterval)
+	if n <= 0 {
+		n = defaultGenerateInterval
+	}
+	return c.inputsSent%n == n-1
+}
+
+// generateEntry creates an input with opts.Generator.
+func (c *coordinator) generateEntry() *CorpusEntry {
+	data := c.opts.Generator(c.generatorRand)
+	var v interface{} = data
+	if t := c.opts.Types[0]; t.Kind() == reflect.String {
+		v = reflect.ValueOf(string(data)).Convert(t).Interface()
+	}
+	return &CorpusEntry{Data: marshalCorpusFile(v), Values: []interface{}{v}}
+}
+
 // sentInput updates internal counters after an input is sent to c.inputC.
 func (c *coordinator) sentInput(input fuzzInput) {
-	c.inputQueue.dequeue()
+	c.noteActivity()
+	if input.replay != nil {
+		c.replay.advance()
+	} else if input.generated {
+		c.generated = nil
+		c.generatedCount++
+	} else {
+		c.inputQueue.dequeue()
+	}
 	c.countWaiting += input.limit
+	c.inputsSent++
+}
+
+// rareCounterFinds is the number of values that may expand coverage in a
+// counter before the counter is no longer rare. See allowInteresting.
+const rareCounterFinds = 2
+
+// allowInteresting returns whether a value that expanded the coordinator's
+// coverage by keepCoverage may be added to the corpus without exceeding
+// opts.MaxInterestingPerSec. Beyond the limit, only values with rare
+// coverage are allowed: values that reach a counter that was never reached
+// before, or that expand a counter fewer than rareCounterFinds values have
+// expanded. Values that keep expanding the same counters are dropped.
+func (c *coordinator) allowInteresting(keepCoverage []byte) bool {
+	rate := c.opts.MaxInterestingPerSec
+	if rate <= 0 {
+		return true
+	}
+	if c.counterFinds == nil {
+		c.counterFinds = make([]int32, len(keepCoverage))
+	}
+	rare := false
+	for i, b := range keepCoverage {
+		if b == 0 {
+			continue
+		}
+		if c.coverageMask[i] == 0 || c.counterFinds[i] < rareCounterFinds {
+			rare = true
+		}
+		c.counterFinds[i]++
+	}
+	burst := math.Max(rate, 1)
+	now := time.Now()
+	if c.interestingTokensTime.IsZero() {
+		c.interestingTokens = burst
+	} else {
+		c.interestingTokens += now.Sub(c.interestingTokensTime).Seconds() * rate
+		c.interestingTokens = math.Min(c.interestingTokens, burst)
+	}
+	c.interestingTokensTime = now
+	if c.interestingTokens >= 1 {
+		c.interestingTokens--
+		return true
+	}
+	return rare
+}
+
+// execRateGracePeriod is how long fuzzing runs after warmup before the rate
+// of calls to the fuzz function is checked against opts.MinExecPerSec.
+const execRateGracePeriod = 10 * time.Second
+
+// checkExecRate returns an error if the rate of calls to the fuzz function
+// since warmup finished is lower than opts.MinExecPerSec. It's called
+// periodically.
+func (c *coordinator) checkExecRate() error {
+	if c.opts.MinExecPerSec <= 0 || c.warmupRun() || c.crashMinimizing != nil {
+		return nil
+	}
+	if c.fuzzStartTime.IsZero() {
+		c.fuzzStartTime = time.Now()
+		c.fuzzStartCount = c.count
+		return nil
+	}
+	elapsed := time.Since(c.fuzzStartTime)
+	if elapsed < execRateGracePeriod {
+		return nil
+	}
+	rate := float64(c.count-c.fuzzStartCount) / elapsed.Seconds()
+	if rate < c.opts.MinExecPerSec {
+		return fmt.Errorf("fuzzing is too slow: %.1f execs/sec is below the minimum of %.1f; check whether the fuzz function does something slow for each input, like sleeping", rate, c.opts.MinExecPerSec)
+	}
+	return nil
+}
+
+const (
+	// oversizeWarnFraction is the fraction of mutated inputs skipped for
+	// exceeding opts.MaxInputSize above which the coordinator warns that the
+	// limit is likely too small.
+	oversizeWarnFraction = 0.25
+
+	// oversizeWarnMinCount is the number of inputs that must be generated
+	// before the coordinator checks how many were skipped.
+	oversizeWarnMinCount = 1000
+)
+
+// checkSkippedOversize prints a warning, once, if a large fraction of mutated
+// inputs were skipped because they exceeded opts.MaxInputSize. It's called
+// periodically.
+func (c *coordinator) checkSkippedOversize() {
+	if c.oversizeWarned || c.opts.MaxInputSize <= 0 || c.count < oversizeWarnMinCount {
+		return
+	}
+	if float64(c.skippedOversize) < oversizeWarnFraction*float64(c.count) {
+		return
+	}
+	c.oversizeWarned = true
+	c.logf(LogWarn, "warning: %d of %d mutated inputs were skipped for exceeding MaxInputSize (%d bytes); raising the limit may let fuzzing reach more code\n", c.skippedOversize, c.count, c.opts.MaxInputSize)
+}
+
+const (
+	// flakyDeflakeWarnFraction is the fraction of deflake runs that don't
+	// reproduce new coverage above which the coordinator warns that the fuzz
+	// function seems nondeterministic.
+	flakyDeflakeWarnFraction = 0.5
+
+	// flakyDeflakeWarnMinCount is the number of deflake runs that must be
+	// made before the coordinator checks how many didn't reproduce coverage.
+	flakyDeflakeWarnMinCount = 50
+)
+
+// checkFlakyDeflakes prints a warning, once, if many of the runs workers made
+// to confirm new coverage didn't reproduce it. Coverage that only shows up
+// some of the time is discarded, so a nondeterministic fuzz function may hide
+// code it actually reaches. It's called periodically.
+func (c *coordinator) checkFlakyDeflakes() {
+	if c.deflakeWarned || c.deflakes < flakyDeflakeWarnMinCount {
+		return
+	}
+	if float64(c.flakes) <= flakyDeflakeWarnFraction*float64(c.deflakes) {
+		return
+	}
+	c.deflakeWarned = true
+	c.logf(LogWarn, "warning: %d of %d runs to confirm new coverage didn't reproduce it; the fuzz function may be nondeterministic, for example, depending on time, randomness or goroutine scheduling, and coverage found that way is discarded\n", c.flakes, c.deflakes)
+}
+
+// removeCorpusEntry removes the entry with the given path from the corpus, so
+// it's not fuzzed again.
+func (c *coordinator) removeCorpusEntry(path string) {
+	entries := c.corpus.entries[:0]
+	for _, e := range c.corpus.entries {
+		if e.Path != path {
+			entries = append(entries, e)
+		}
+	}
+	c.corpus.entries = entries
+	delete(c.entryTimes, path)
 }
 
 // refillInputQueue refills the input queue from the corpus after it becomes
 // empty.
 func (c *coordinator) refillInputQueue() {
-	for _, e := range c.corpus.entries {
-		c.inputQueue.enqueue(e)
+	entries := c.corpus.entries
+	if c.opts.DeterministicOrder {
+		entries = append([]CorpusEntry(nil), entries...)
+		sortCorpus(entries)
 	}
+	for _, e := range entries {
+		n := 1
+		if c.focused[e.Path] {
+			n = focusWeight
+		}
+		for i := 0; i < n; i++ {
+			c.inputQueue.enqueue(e)
+		}
+	}
+}
+
+// handleCoverage handles a result, found after warmup, that the worker
+// reported coverage for. If the coverage is new, the value is queued for
+// minimization or added to the corpus. handleCoverage returns an error if
+// the value couldn't be written to the cache.
+func (c *coordinator) handleCoverage(result fuzzResult) error {
+	keepCoverage := diffCoverage(c.coverageMask, result.coverageData)
+	if keepCoverage == nil {
+		if c.logEnabled(LogDebug) {
+			c.logf(
+				LogDebug,
+				"DEBUG worker reported interesting input that doesn't expand coverage, elapsed: %s, id: %s, parent: %s, canMinimize: %t\n",
+				c.elapsed(),
+				result.entry.Path,
+				result.entry.Parent,
+				result.canMinimize,
+			)
+		}
+		return nil
+	}
+	if !result.minimized && !c.allowInteresting(keepCoverage) {
+		// Values are expanding coverage faster than
+		// opts.MaxInterestingPerSec. Drop this one. Its coverage
+		// isn't added, so an input reaching the same code may
+		// still be kept later.
+		c.droppedInteresting++
+		return nil
+	}
+	c.creditMutatorOps(result.mutatorOps)
+	// Found a value that expanded coverage.
+	// It's not a crasher, but we may want to add it to the on-disk
+	// corpus and prioritize it for future fuzzing.
+	// TODO(jayconrod, katiehockman): Prioritize fuzzing these
+	// values which expanded coverage, perhaps based on the
+	// number of new edges that this result expanded.
+	// TODO(jayconrod, katiehockman): Don't write a value that's already
+	// in the corpus.
+	if c.canMinimize() && result.canMinimize && c.crashMinimizing == nil {
+		// Send back to workers to find a smaller value that preserves
+		// at least one new coverage bit.
+		c.queueForMinimization(result, keepCoverage)
+		return nil
+	}
+	// Update the coordinator's coverage mask and save the value.
+	inputSize := len(result.entry.Data)
+	err := c.addInteresting(&result)
+	c.reportNewCoverage(keepCoverage, result.entry.Path)
+	c.updateCoverage(keepCoverage)
+	if c.logEnabled(LogDebug) {
+		c.logf(
+			LogDebug,
+			"DEBUG new interesting input, elapsed: %s, id: %s, parent: %s, gen: %d, new bits: %d, total bits: %d, size: %d, exec time: %s\n",
+			c.elapsed(),
+			result.entry.Path,
+			result.entry.Parent,
+			result.entry.Generation,
+			countBits(keepCoverage),
+			countBits(c.coverageMask),
+			inputSize,
+			result.entryDuration,
+		)
+	}
+	return err
 }
 
 // queueForMinimization creates a fuzzMinimizeInput from result and adds it
@@ -789,9 +2251,12 @@ func (c *coordinator) queueForMinimization(result fuzzResult, keepCoverage []byt
 	}
 
 	input := fuzzMinimizeInput{
-		entry:        result.entry,
-		crasherMsg:   result.crasherMsg,
-		keepCoverage: keepCoverage,
+		entry:         result.entry,
+		crasherMsg:    result.crasherMsg,
+		keepCoverage:  keepCoverage,
+		env:           result.env,
+		crashCoverage: result.crashCoverage,
+		crashSignal:   result.crashSignal,
 	}
 	c.minimizeQueue.enqueue(input)
 }
@@ -810,23 +2275,36 @@ func (c *coordinator) peekMinimizeInput() (fuzzMinimizeInput, bool) {
 	}
 	input := v.(fuzzMinimizeInput)
 
-	if c.opts.MinimizeTimeout > 0 {
+	// With opts.MaxMinimizeAttempts, only the number of calls bounds
+	// minimizing a crasher.
+	attempts := input.crasherMsg != "" && c.opts.MaxMinimizeAttempts > 0
+	if c.opts.MinimizeTimeout > 0 && !attempts {
 		input.timeout = c.opts.MinimizeTimeout
 	}
-	if c.opts.MinimizeLimit > 0 {
+	if input.crasherMsg != "" && c.opts.TotalMinimizeBudget > 0 {
+		// Don't spend more than the rest of the budget on this crasher.
+		remaining := c.opts.TotalMinimizeBudget - c.crashMinimizeDuration
+		if input.timeout == 0 || input.timeout > remaining {
+			input.timeout = remaining
+		}
+	}
+	limit := c.execLimit()
+	if attempts {
+		input.limit = int64(c.opts.MaxMinimizeAttempts)
+	} else if c.opts.MinimizeLimit > 0 {
 		input.limit = c.opts.MinimizeLimit
-	} else if c.opts.Limit > 0 {
+	} else if limit > 0 {
 		if input.crasherMsg != "" {
-			input.limit = c.opts.Limit
+			input.limit = limit
 		} else {
-			input.limit = c.opts.Limit / int64(c.opts.Parallel)
-			if c.opts.Limit%int64(c.opts.Parallel) > 0 {
+			input.limit = limit / int64(c.opts.Parallel)
+			if limit%int64(c.opts.Parallel) > 0 {
 				input.limit++
 			}
 		}
 	}
-	if c.opts.Limit > 0 {
-		remaining := c.opts.Limit - c.count - c.countWaiting
+	if limit > 0 {
+		remaining := limit - c.count - c.countWaiting
 		if input.limit > remaining {
 			input.limit = remaining
 		}
@@ -837,6 +2315,7 @@ func (c *coordinator) peekMinimizeInput() (fuzzMinimizeInput, bool) {
 // sentMinimizeInput removes an input from the minimization queue after it's
 // sent to minimizeC.
 func (c *coordinator) sentMinimizeInput(input fuzzMinimizeInput) {
+	c.noteActivity()
 	c.minimizeQueue.dequeue()
 	c.countWaiting += input.limit
 }
@@ -865,51 +2344,286 @@ func (c *coordinator) updateCoverage(newCoverage []byte) int {
 	if len(newCoverage) != len(c.coverageMask) {
 		panic(fmt.Sprintf("number of coverage counters changed at runtime: %d, expected %d", len(newCoverage), len(c.coverageMask)))
 	}
-	newBitCount := 0
-	for i := range newCoverage {
-		diff := newCoverage[i] &^ c.coverageMask[i]
-		newBitCount += bits.OnesCount8(diff)
-		c.coverageMask[i] |= newCoverage[i]
+	return activeFeedback.Merge(c.coverageMask, newCoverage)
+}
+
+// reportNewCoverage calls opts.OnNewCoverage for each bit set in newCoverage
+// that isn't set in c.coverageMask, for the entry at path. It's called
+// before newCoverage is added to the mask.
+func (c *coordinator) reportNewCoverage(newCoverage []byte, path string) {
+	if c.opts.OnNewCoverage == nil {
+		return
+	}
+	for i, b := range newCoverage {
+		b &^= c.coverageMask[i]
+		for j := 0; b != 0; j++ {
+			if b&1 != 0 {
+				c.opts.OnNewCoverage(i*8+j, path)
+			}
+			b >>= 1
+		}
 	}
-	return newBitCount
 }
 
 // canMinimize returns whether the coordinator should attempt to find smaller
 // inputs that reproduce a crash or new coverage. It shouldn't do this if it
 // is in the warmup phase.
 func (c *coordinator) canMinimize() bool {
+	limit := c.execLimit()
 	return c.minimizationAllowed &&
-		(c.opts.Limit == 0 || c.count+c.countWaiting < c.opts.Limit) &&
+		(limit == 0 || c.count+c.countWaiting < limit) &&
 		!c.warmupRun()
 }
 
+// execLimit returns the number of calls to the fuzz function after which
+// fuzzing stops, or 0 if there's no limit. It's the lower of opts.Limit and,
+// once the corpus has been tested, opts.TargetExecLimit calls after the
+// warmup calls.
+func (c *coordinator) execLimit() int64 {
+	limit := c.opts.Limit
+	if c.opts.TargetExecLimit > 0 && !c.warmupRun() {
+		if l := c.warmupCount + c.opts.TargetExecLimit; limit == 0 || l < limit {
+			limit = l
+		}
+	}
+	return limit
+}
+
+// minimizedCrasher returns whether result is from minimizing the crasher in
+// c.crashMinimizing. Results of minimizing other inputs may still arrive
+// after minimizing the crasher starts.
+func (c *coordinator) minimizedCrasher(result fuzzResult) bool {
+	return result.minimized && c.crashMinimizing != nil && result.inputPath == c.crashMinimizing.entry.Path
+}
+
+// minimizeBudgetSpent returns whether workers have spent all of the time
+// allowed by opts.TotalMinimizeBudget minimizing crashers.
+func (c *coordinator) minimizeBudgetSpent() bool {
+	return c.opts.TotalMinimizeBudget > 0 && c.crashMinimizeDuration >= c.opts.TotalMinimizeBudget
+}
+
 func (c *coordinator) elapsed() time.Duration {
 	return time.Since(c.startTime).Round(1 * time.Second)
 }
 
+// addInteresting adds an interesting value found by a worker to the corpus
+// and queues it for fuzzing. The value is also written to the cache directory,
+// unless opts.DeferCorpusWrite is set, in which case it's written later by
+// flushCorpus.
+func (c *coordinator) addInteresting(result *fuzzResult) error {
+	var err error
+	size := int64(len(result.entry.Data))
+	if c.opts.CacheDir != "" && c.opts.DeferCorpusWrite {
+		// Keep the data in memory. The entry will be written
+		// by flushCorpus when fuzzing stops.
+		result.entry.Path = c.entryPath(result.entry, c.cacheWriteDir(), false)
+		c.pendingCorpus = append(c.pendingCorpus, result.entry)
+		if result.derivation != nil {
+			if c.pendingDerivations == nil {
+				c.pendingDerivations = make(map[string]*entryDerivation)
+			}
+			c.pendingDerivations[result.entry.Path] = result.derivation
+		}
+	} else if c.opts.CacheDir != "" {
+		result.entry.Path = c.entryPath(result.entry, c.cacheWriteDir(), false)
+		err = c.writeCacheEntry(&result.entry)
+		if err == nil && result.derivation != nil {
+			err = writeEntryMeta(result.entry.Path, entryMeta{Derivation: result.derivation})
+		}
+		if !c.opts.RawCorpus {
+			// Workers read the entry from the file when they need it. They
+			// can't read raw files, so those entries stay in memory.
+			result.entry.Data = nil
+		}
+	}
+	c.recordEntryCoverage(result.entry.Path, result.coverageData)
+	c.corpus.entries = append(c.corpus.entries, result.entry)
+	c.inputQueue.enqueue(result.entry)
+	if c.reachesFocus(result.coverageData) {
+		c.focused[result.entry.Path] = true
+		for i := 1; i < focusWeight; i++ {
+			c.inputQueue.enqueue(result.entry)
+		}
+	}
+	c.interestingCount++
+	if (c.opts.MaxCorpusDiskBytes > 0 || c.opts.CompactInterval > 0) && c.opts.CacheDir != "" && err == nil {
+		err = c.addCorpusDiskUsage(result.entry, size)
+	}
+	return err
+}
+
+// focusWeight is how many times more often corpus entries that reach the code
+// in CoordinateFuzzingOpts.FocusCoverage are fuzzed than other entries.
+const focusWeight = 4
+
+// workerStartDelays returns how long to wait before starting each of n
+// workers: a random permutation of multiples of opts.WorkerStartStagger, so
+// workers start in a random order with WorkerStartStagger between each. The
+// order is derived from RunID if it's set.
+func (c *coordinator) workerStartDelays(n int) []time.Duration {
+	var order []int
+	if c.opts.RunID != "" {
+		order = rand.New(rand.NewSource(int64(c.runSeed("start order")))).Perm(n)
+	} else {
+		order = rand.Perm(n)
+	}
+	delays := make([]time.Duration, n)
+	for i, o := range order {
+		delays[i] = time.Duration(o) * c.opts.WorkerStartStagger
+	}
+	return delays
+}
+
+// runSeed returns a seed derived from CoordinateFuzzingOpts.RunID and parts,
+// which identify what the seed is used for. runSeed returns 0 if RunID is
+// not set.
+func (c *coordinator) runSeed(parts ...interface{}) uint64 {
+	if c.opts.RunID == "" {
+		return 0
+	}
+	h := sha256.Sum256([]byte(fmt.Sprintf("%q %v", c.opts.RunID, parts)))
+	if seed := binary.LittleEndian.Uint64(h[:8]); seed != 0 {
+		return seed
+	}
+	return 1
+}
+
+// reachesFocus returns whether the coverage snapshot cov includes any of the
+// counters in opts.FocusCoverage.
+func (c *coordinator) reachesFocus(cov []byte) bool {
+	return c.focusMask != nil && cov != nil && hasCoverageBit(c.focusMask, cov)
+}
+
+// flushCorpus writes interesting values held in memory to the cache directory.
+// Values that were written successfully are removed from c.pendingCorpus, so
+// flushCorpus may be called again after an error.
+func (c *coordinator) flushCorpus() error {
+	for len(c.pendingCorpus) > 0 {
+		e := c.pendingCorpus[0]
+		if err := c.writeCacheEntry(&e); err != nil {
+			return err
+		}
+		if d := c.pendingDerivations[e.Path]; d != nil {
+			if err := writeEntryMeta(e.Path, entryMeta{Derivation: d}); err != nil {
+				return err
+			}
+			delete(c.pendingDerivations, e.Path)
+		}
+		c.pendingCorpus = c.pendingCorpus[1:]
+	}
+	return nil
+}
+
+// runForeverFlushInterval is how often interesting values held in memory are
+// written to the cache with opts.RunUntilInterrupted.
+const runForeverFlushInterval = 5 * time.Minute
+
+// flushPeriodically writes interesting values held in memory to the cache if
+// opts.RunUntilInterrupted is set and runForeverFlushInterval has passed
+// since they were last written. It's called periodically. Errors are logged
+// once, and writing is retried the next time.
+func (c *coordinator) flushPeriodically() {
+	if !c.opts.RunUntilInterrupted || len(c.pendingCorpus) == 0 {
+		return
+	}
+	if c.lastFlush.IsZero() {
+		c.lastFlush = c.startTime
+	}
+	if time.Since(c.lastFlush) < runForeverFlushInterval {
+		return
+	}
+	c.lastFlush = time.Now()
+	if err := c.flushCorpus(); err != nil && !c.warned["flush"] {
+		c.logf(LogWarn, "warning: writing interesting values to the cache: %v\n", err)
+		c.warned["flush"] = true
+	}
+}
+
 // readCache creates a combined corpus from seed values and values in the cache
 // (in GOCACHE/fuzz).
 //
 // TODO(fuzzing): need a mechanism that can remove values that
 // aren't useful anymore, for example, because they have the wrong type.
-func readCache(seed []CorpusEntry, types []reflect.Type, cacheDir string) (corpus, error) {
+func readCache(seed []CorpusEntry, types []reflect.Type, cacheDirs []string, skipErrors, raw bool) (corpus, error) {
 	var c corpus
 	c.entries = append(c.entries, seed...)
-	entries, err := ReadCorpus(cacheDir, types)
-	if err != nil {
-		if _, ok := err.(*MalformedCorpusError); !ok {
-			// It's okay if some files in the cache directory are malformed and
-			// are not included in the corpus, but fail if it's an I/O error.
-			return corpus{}, err
+	for _, dir := range cacheDirs {
+		var entries []CorpusEntry
+		var err error
+		if raw {
+			entries, err = readRawCorpus(dir, skipErrors)
+		} else {
+			entries, err = readCorpus(dir, types, skipErrors)
+		}
+		if err != nil {
+			merr, ok := err.(*MalformedCorpusError)
+			if !ok {
+				// It's okay if some files in the cache directory are malformed and
+				// are not included in the corpus, but fail if it's an I/O error.
+				return corpus{}, err
+			}
+			// TODO(jayconrod,katiehockman): consider printing some kind of warning
+			// indicating the number of files which were skipped because they are
+			// malformed, without skipErrors.
+			if skipErrors {
+				for _, e := range merr.errs {
+					if ferr, ok := e.(*corpusFileError); ok {
+						c.skipped = append(c.skipped, ferr)
+					}
+				}
+			}
 		}
-		// TODO(jayconrod,katiehockman): consider printing some kind of warning
-		// indicating the number of files which were skipped because they are
-		// malformed.
+		c.entries = append(c.entries, entries...)
 	}
-	c.entries = append(c.entries, entries...)
+	// Values in the cache may also be in the seed corpus or in more than one
+	// cache directory. Since seed values come first, followed by directories
+	// in order, those are the ones that are kept.
+	c.entries = dedupCorpus(c.entries)
 	return c, nil
 }
 
+// sortCorpus sorts entries for opts.DeterministicOrder: seed values first, in
+// their original order, then the other entries by file name.
+func sortCorpus(entries []CorpusEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.IsSeed || b.IsSeed {
+			return a.IsSeed && !b.IsSeed
+		}
+		return filepath.Base(a.Path) < filepath.Base(b.Path)
+	})
+}
+
+// dedupCorpus returns entries with duplicate values removed, comparing
+// entries by a hash of their marshaled values. The first of a set of duplicate
+// entries is kept, and it's marked as part of the seed corpus if any of the
+// duplicates were. The order of entries is otherwise preserved, so the same
+// entries are kept each time.
+func dedupCorpus(entries []CorpusEntry) []CorpusEntry {
+	index := make(map[[sha256.Size]byte]int)
+	deduped := make([]CorpusEntry, 0, len(entries))
+	for _, e := range entries {
+		data := e.Data
+		if data == nil {
+			if len(e.Values) == 0 {
+				deduped = append(deduped, e)
+				continue
+			}
+			data = marshalCorpusFile(e.Values...)
+		}
+		h := sha256.Sum256(data)
+		if i, ok := index[h]; ok {
+			if e.IsSeed {
+				deduped[i].IsSeed = true
+			}
+			continue
+		}
+		index[h] = len(deduped)
+		deduped = append(deduped, e)
+	}
+	return deduped
+}
+
 // MalformedCorpusError is an error found while reading the corpus from the
 // filesystem. All of the errors are stored in the errs list. The testing
 // framework uses this to report malformed files in testdata.
@@ -917,6 +2631,16 @@ type MalformedCorpusError struct {
 	errs []error
 }
 
+// corpusFileError is an error reading or parsing one corpus file.
+type corpusFileError struct {
+	path string
+	err  error
+}
+
+func (e *corpusFileError) Error() string {
+	return fmt.Sprintf("%q: %v", e.path, e.err)
+}
+
 func (e *MalformedCorpusError) Error() string {
 	var msgs []string
 	for _, s := range e.errs {
@@ -930,6 +2654,12 @@ func (e *MalformedCorpusError) Error() string {
 // be saved in a MalformedCorpusError and returned, along with the most recent
 // error.
 func ReadCorpus(dir string, types []reflect.Type) ([]CorpusEntry, error) {
+	return readCorpus(dir, types, false)
+}
+
+// readCorpus is like ReadCorpus, but if skipUnreadable is true, files that
+// can't be read are treated like malformed files instead of stopping it.
+func readCorpus(dir string, types []reflect.Type, skipUnreadable bool) ([]CorpusEntry, error) {
 	files, err := ioutil.ReadDir(dir)
 	if os.IsNotExist(err) {
 		return nil, nil // No corpus to read
@@ -950,12 +2680,16 @@ func ReadCorpus(dir string, types []reflect.Type) ([]CorpusEntry, error) {
 		filename := filepath.Join(dir, file.Name())
 		data, err := ioutil.ReadFile(filename)
 		if err != nil {
+			if skipUnreadable {
+				errs = append(errs, &corpusFileError{path: filename, err: err})
+				continue
+			}
 			return nil, fmt.Errorf("failed to read corpus file: %v", err)
 		}
 		var vals []interface{}
 		vals, err = readCorpusData(data, types)
 		if err != nil {
-			errs = append(errs, fmt.Errorf("%q: %v", filename, err))
+			errs = append(errs, &corpusFileError{path: filename, err: err})
 			continue
 		}
 		corpus = append(corpus, CorpusEntry{Path: filename, Values: vals})
@@ -966,6 +2700,64 @@ func ReadCorpus(dir string, types []reflect.Type) ([]CorpusEntry, error) {
 	return corpus, nil
 }
 
+// readRawCorpus reads each file in dir as the raw bytes of an input to a fuzz
+// target that takes a single []byte argument. See
+// CoordinateFuzzingOpts.RawCorpus. Since workers can only read files in the
+// Go corpus file format, the returned entries hold the marshaled data.
+func readRawCorpus(dir string, skipUnreadable bool) ([]CorpusEntry, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading raw corpus: %v", err)
+	}
+	var corpus []CorpusEntry
+	var errs []error
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		filename := filepath.Join(dir, file.Name())
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			if skipUnreadable {
+				errs = append(errs, &corpusFileError{path: filename, err: err})
+				continue
+			}
+			return nil, fmt.Errorf("failed to read corpus file: %v", err)
+		}
+		corpus = append(corpus, CorpusEntry{Path: filename, Data: marshalCorpusFile(b), Values: []interface{}{b}})
+	}
+	if len(errs) > 0 {
+		return corpus, &MalformedCorpusError{errs: errs}
+	}
+	return corpus, nil
+}
+
+// cacheFileData returns the contents of the cache file for an entry with the
+// marshaled data. With opts.RawCorpus, that's the raw []byte value.
+func (c *coordinator) cacheFileData(data []byte) []byte {
+	if !c.opts.RawCorpus {
+		return data
+	}
+	vals, err := unmarshalCorpusFile(data)
+	if err != nil || len(vals) != 1 {
+		return data
+	}
+	if b, ok := vals[0].([]byte); ok {
+		return b
+	}
+	return data
+}
+
+// writeCacheEntry writes entry to entry.Path in the cache directory, as raw
+// bytes if opts.RawCorpus is set.
+func (c *coordinator) writeCacheEntry(entry *CorpusEntry) error {
+	e := *entry
+	e.Data = c.cacheFileData(entry.Data)
+	return writeEntryFile(&e)
+}
+
 func readCorpusData(data []byte, types []reflect.Type) ([]interface{}, error) {
 	vals, err := unmarshalCorpusFile(data)
 	if err != nil {
@@ -991,14 +2783,77 @@ func CheckCorpus(vals []interface{}, types []reflect.Type) error {
 	return nil
 }
 
+// writeCrasher writes a crashing input to the corpus directory, and metadata
+// describing the crash to the cache directory. result.entry.Path is set to the file that
+// was written.
+func (c *coordinator) writeCrasher(result *fuzzResult) error {
+	meta := c.prepareCrasher(result)
+	return writeCrasherFiles(result.entry, meta, c.opts.CacheDir)
+}
+
+// prepareCrasher sets the path a crashing input is written to and returns
+// the metadata written with it.
+func (c *coordinator) prepareCrasher(result *fuzzResult) entryMeta {
+	result.entry.Path = c.entryPath(result.entry, c.opts.CorpusDir, true)
+	if c.runTag != "" {
+		result.entry.Path += "-" + c.runTag
+	}
+	meta := entryMeta{
+		Err:           result.crasherMsg,
+		Env:           result.env,
+		Found:         result.found,
+		ReproduceRuns: result.reproduceRuns,
+		Reproduced:    result.reproduced,
+		Signature:     result.signature,
+	}
+	if result.crashCoverage != nil && c.coverageMask != nil {
+		meta.NewCoverage = coverageIndexes(diffCoverage(c.coverageMask, result.crashCoverage))
+	}
+	return meta
+}
+
+// writeCrasherFiles writes a crashing input prepared by prepareCrasher and,
+// if cacheDir is set, its metadata.
+func writeCrasherFiles(entry CorpusEntry, meta entryMeta, cacheDir string) error {
+	if err := writeEntryFile(&entry); err != nil {
+		return err
+	}
+	if cacheDir == "" {
+		return nil
+	}
+	return writeMetaFile(crasherMetaPath(cacheDir, entry.Path), meta)
+}
+
+// crasherWritten records a crasher written by writeCrasher and returns the
+// crashError fuzzing stops with.
+func (c *coordinator) crasherWritten(result *fuzzResult) error {
+	c.recordCrasher(result)
+	c.runCrashHook(result)
+	if result.reproduceRuns > 0 {
+		c.logf(LogInfo, "fuzz: crasher reproduced in %d of %d runs (%d%%)\n", result.reproduced, result.reproduceRuns, 100*result.reproduced/result.reproduceRuns)
+	}
+	if other := c.sameSignatureCrasher(result.entry.Path, result.signature); other != "" {
+		c.logf(LogInfo, "fuzz: crasher has the same stack signature as %s; it's probably caused by the same bug\n", other)
+	}
+	return &crashError{
+		path: result.entry.Path,
+		err:  errors.New(result.crasherMsg),
+	}
+}
+
 // writeToCorpus atomically writes the given bytes to a new file in testdata. If
 // the directory does not exist, it will create one. If the file already exists,
 // writeToCorpus will not rewrite it. writeToCorpus sets entry.Path to the new
 // file that was just written or an error if it failed.
 func writeToCorpus(entry *CorpusEntry, dir string) (err error) {
-	sum := fmt.Sprintf("%x", sha256.Sum256(entry.Data))
-	entry.Path = filepath.Join(dir, sum)
-	if err := os.MkdirAll(dir, 0777); err != nil {
+	entry.Path = corpusEntryPath(entry.Data, dir)
+	return writeEntryFile(entry)
+}
+
+// writeEntryFile writes entry.Data to entry.Path, creating the directory if
+// it doesn't exist.
+func writeEntryFile(entry *CorpusEntry) error {
+	if err := os.MkdirAll(filepath.Dir(entry.Path), 0777); err != nil {
 		return err
 	}
 	if err := ioutil.WriteFile(entry.Path, entry.Data, 0666); err != nil {
@@ -1008,6 +2863,12 @@ func writeToCorpus(entry *CorpusEntry, dir string) (err error) {
 	return nil
 }
 
+// corpusEntryPath returns the path in dir where writeToCorpus writes data.
+func corpusEntryPath(data []byte, dir string) string {
+	sum := fmt.Sprintf("%x", sha256.Sum256(data))
+	return filepath.Join(dir, sum)
+}
+
 func testName(path string) string {
 	return filepath.Base(path)
 }
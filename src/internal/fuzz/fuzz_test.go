@@ -0,0 +1,939 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDedupCorpus(t *testing.T) {
+	a := []interface{}{[]byte("a")}
+	b := []interface{}{[]byte("b")}
+	entries := []CorpusEntry{
+		{Path: "seed#0", Data: marshalCorpusFile(a...), IsSeed: true},
+		{Path: "seed#1", Data: marshalCorpusFile(b...), IsSeed: true},
+		{Path: "seed#2", Data: marshalCorpusFile(a...), IsSeed: true},
+		{Path: "cache/a", Values: a},
+		{Path: "cache/c", Values: []interface{}{[]byte("c")}},
+		{Path: "cache/c2", Values: []interface{}{[]byte("c")}, IsSeed: true},
+	}
+	got := dedupCorpus(entries)
+	want := []struct {
+		path   string
+		isSeed bool
+	}{
+		{"seed#0", true},
+		{"seed#1", true},
+		{"cache/c", true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d entries; want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].Path != w.path || got[i].IsSeed != w.isSeed {
+			t.Errorf("entry %d: got %s (seed %t); want %s (seed %t)", i, got[i].Path, got[i].IsSeed, w.path, w.isSeed)
+		}
+	}
+}
+
+func TestAllowInteresting(t *testing.T) {
+	c := &coordinator{
+		opts:         CoordinateFuzzingOpts{MaxInterestingPerSec: 0.001},
+		coverageMask: []byte{1, 0},
+	}
+	moreHits := []byte{2, 0}
+	newEdge := []byte{0, 1}
+	if !c.allowInteresting(moreHits) {
+		t.Fatal("first value was not allowed")
+	}
+	// The first counter is rare until rareCounterFinds values expanded it.
+	for i := 1; i < rareCounterFinds; i++ {
+		if !c.allowInteresting(moreHits) {
+			t.Errorf("value %d expanding a rare counter was not allowed beyond the limit", i+1)
+		}
+	}
+	if c.allowInteresting(moreHits) {
+		t.Error("value expanding a common counter was allowed beyond the limit")
+	}
+	if !c.allowInteresting(newEdge) {
+		t.Error("value reaching new code was not allowed beyond the limit")
+	}
+}
+
+func TestCheckExecRate(t *testing.T) {
+	c := &coordinator{
+		opts:            CoordinateFuzzingOpts{MinExecPerSec: 100},
+		warmupInputLeft: 1,
+	}
+	if err := c.checkExecRate(); err != nil || !c.fuzzStartTime.IsZero() {
+		t.Fatalf("checkExecRate() during warmup = %v, started at %v; want nil, not started", err, c.fuzzStartTime)
+	}
+	c.warmupInputLeft = 0
+	c.count = 5
+	if err := c.checkExecRate(); err != nil {
+		t.Fatalf("first checkExecRate() = %v; want nil", err)
+	}
+	if c.fuzzStartTime.IsZero() || c.fuzzStartCount != 5 {
+		t.Fatalf("after first check, start = (%v, %d); want (now, 5)", c.fuzzStartTime, c.fuzzStartCount)
+	}
+	c.count = 6
+	if err := c.checkExecRate(); err != nil {
+		t.Errorf("checkExecRate() within grace period = %v; want nil", err)
+	}
+
+	c.fuzzStartTime = time.Now().Add(-execRateGracePeriod)
+	if err := c.checkExecRate(); err == nil {
+		t.Error("checkExecRate() below the minimum rate = nil; want error")
+	}
+	c.crashMinimizing = &fuzzResult{}
+	if err := c.checkExecRate(); err != nil {
+		t.Errorf("checkExecRate() while minimizing a crasher = %v; want nil", err)
+	}
+	c.crashMinimizing = nil
+	c.count = c.fuzzStartCount + int64(200*execRateGracePeriod/time.Second)
+	if err := c.checkExecRate(); err != nil {
+		t.Errorf("checkExecRate() above the minimum rate = %v; want nil", err)
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	if shouldPrintDebugInfo() {
+		t.Skip("debug messages are always written with GODEBUG=fuzzdebug=1")
+	}
+	for _, test := range []struct {
+		level LogLevel
+		want  string
+	}{
+		{LogDebug, "debug\ninfo\nwarn\n"},
+		{LogInfo, "info\nwarn\n"},
+		{LogWarn, "warn\n"},
+	} {
+		var buf bytes.Buffer
+		c := &coordinator{opts: CoordinateFuzzingOpts{Log: &buf, LogLevel: test.level}}
+		c.logf(LogDebug, "debug\n")
+		c.logf(LogInfo, "info\n")
+		c.logf(LogWarn, "%s\n", "warn")
+		if got := buf.String(); got != test.want {
+			t.Errorf("with LogLevel %d, wrote %q; want %q", test.level, got, test.want)
+		}
+	}
+}
+
+func TestTargetExecLimit(t *testing.T) {
+	c := &coordinator{
+		opts:            CoordinateFuzzingOpts{TargetExecLimit: 10, Limit: 100},
+		warmupInputLeft: 1,
+	}
+	c.updateStats(fuzzResult{count: 50})
+	if got := c.execLimit(); got != 100 {
+		t.Errorf("execLimit() during warmup = %d; want 100", got)
+	}
+	c.warmupInputLeft = 0
+	c.updateStats(fuzzResult{count: 3})
+	if got := c.execLimit(); got != 60 {
+		t.Errorf("execLimit() after warmup = %d; want 60", got)
+	}
+	c.opts.Limit = 55
+	if got := c.execLimit(); got != 55 {
+		t.Errorf("execLimit() with a lower Limit = %d; want 55", got)
+	}
+}
+
+func TestMaxMinimizeAttempts(t *testing.T) {
+	c := &coordinator{
+		opts: CoordinateFuzzingOpts{
+			MinimizeTimeout:     time.Minute,
+			MinimizeLimit:       1000,
+			MaxMinimizeAttempts: 50,
+			Parallel:            1,
+		},
+		minimizationAllowed: true,
+	}
+	for _, tc := range []struct {
+		crasherMsg  string
+		wantLimit   int64
+		wantTimeout time.Duration
+	}{
+		{crasherMsg: "panic", wantLimit: 50},
+		{wantLimit: 1000, wantTimeout: time.Minute},
+	} {
+		c.minimizeQueue.enqueue(fuzzMinimizeInput{crasherMsg: tc.crasherMsg})
+		input, ok := c.peekMinimizeInput()
+		if !ok {
+			t.Fatal("no input to minimize")
+		}
+		if input.limit != tc.wantLimit || input.timeout != tc.wantTimeout {
+			t.Errorf("crasher message %q: got limit %d and timeout %v; want %d and %v", tc.crasherMsg, input.limit, input.timeout, tc.wantLimit, tc.wantTimeout)
+		}
+		c.minimizeQueue.dequeue()
+	}
+}
+
+func TestFlushPeriodically(t *testing.T) {
+	dir := t.TempDir()
+	e := CorpusEntry{Path: filepath.Join(dir, "a"), Data: marshalCorpusFile([]byte("a"))}
+	c := &coordinator{
+		opts:          CoordinateFuzzingOpts{CacheDir: dir, DeferCorpusWrite: true, RunUntilInterrupted: true},
+		startTime:     time.Now(),
+		pendingCorpus: []CorpusEntry{e},
+	}
+	c.flushPeriodically()
+	if len(c.pendingCorpus) != 1 {
+		t.Fatal("values were written before the flush interval passed")
+	}
+	c.lastFlush = time.Now().Add(-runForeverFlushInterval)
+	c.flushPeriodically()
+	if len(c.pendingCorpus) != 0 {
+		t.Fatal("values were not written after the flush interval passed")
+	}
+	if _, err := os.Stat(e.Path); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCheckFlakyDeflakes(t *testing.T) {
+	var log bytes.Buffer
+	c := &coordinator{opts: CoordinateFuzzingOpts{Log: &log}}
+	c.updateStats(fuzzResult{deflakes: flakyDeflakeWarnMinCount, flakes: flakyDeflakeWarnMinCount / 2})
+	c.checkFlakyDeflakes()
+	if log.Len() != 0 {
+		t.Fatalf("warned with half of the deflake runs flaky: %s", log.String())
+	}
+	c.updateStats(fuzzResult{deflakes: 10, flakes: 10})
+	c.checkFlakyDeflakes()
+	c.checkFlakyDeflakes()
+	if n := strings.Count(log.String(), "warning:"); n != 1 {
+		t.Errorf("got %d warnings; want 1:\n%s", n, log.String())
+	}
+}
+
+func TestGeneratedInputs(t *testing.T) {
+	calls := 0
+	c := &coordinator{
+		opts: CoordinateFuzzingOpts{
+			Types:            []reflect.Type{reflect.TypeOf("")},
+			Parallel:         1,
+			GenerateInterval: 2,
+			Generator: func(r *rand.Rand) []byte {
+				calls++
+				return []byte("generated")
+			},
+		},
+	}
+	c.corpus.entries = []CorpusEntry{{Path: "a", Data: marshalCorpusFile("a")}}
+	want := marshalCorpusFile("generated")
+	var got []bool
+	for i := 0; i < 4; i++ {
+		input, ok := c.peekInput()
+		if !ok {
+			t.Fatal("no input to send")
+		}
+		if input.generated {
+			// Peeking again doesn't generate another input.
+			again, _ := c.peekInput()
+			if !bytes.Equal(again.entry.Data, input.entry.Data) || !bytes.Equal(input.entry.Data, want) {
+				t.Fatalf("peeked generated inputs %q and %q; want %q", input.entry.Data, again.entry.Data, want)
+			}
+		}
+		got = append(got, input.generated)
+		c.sentInput(input)
+	}
+	if want := []bool{false, true, false, true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("generated inputs %v; want %v", got, want)
+	}
+	if calls != 2 || c.generatedCount != 2 {
+		t.Errorf("Generator called %d times for %d inputs; want 2", calls, c.generatedCount)
+	}
+}
+
+func TestRecordDerivation(t *testing.T) {
+	for _, deferWrite := range []bool{false, true} {
+		c := &coordinator{opts: CoordinateFuzzingOpts{
+			CacheDir:         t.TempDir(),
+			DeferCorpusWrite: deferWrite,
+			RecordDerivation: true,
+		}}
+		d := &entryDerivation{Parent: "parent", RandState: 1, RandInc: 3, Mutations: 7}
+		result := fuzzResult{entry: CorpusEntry{Data: marshalCorpusFile([]byte("x"))}, derivation: d}
+		if err := c.addInteresting(&result); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.flushCorpus(); err != nil {
+			t.Fatal(err)
+		}
+		meta, ok, err := readEntryMeta(result.entry.Path)
+		if err != nil || !ok {
+			t.Fatalf("DeferCorpusWrite=%v: reading metadata: %v, %v", deferWrite, ok, err)
+		}
+		if !reflect.DeepEqual(meta.Derivation, d) {
+			t.Errorf("DeferCorpusWrite=%v: recorded derivation %+v; want %+v", deferWrite, meta.Derivation, d)
+		}
+	}
+}
+
+func TestDeterministicOrder(t *testing.T) {
+	cacheDir, extraDir := t.TempDir(), t.TempDir()
+	for dir, names := range map[string][]string{cacheDir: {"c", "e"}, extraDir: {"a", "d"}} {
+		for _, name := range names {
+			if err := os.WriteFile(filepath.Join(dir, name), marshalCorpusFile([]byte(name)), 0666); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	c, err := newCoordinator(CoordinateFuzzingOpts{
+		Types: []reflect.Type{reflect.TypeOf([]byte(nil))},
+		Log:   io.Discard,
+		Seed: []CorpusEntry{
+			{Path: "seed#1", Values: []interface{}{[]byte("z")}, IsSeed: true},
+			{Path: "seed#0", Values: []interface{}{[]byte("y")}, IsSeed: true},
+		},
+		CacheDir:           cacheDir,
+		ExtraCacheDirs:     []string{extraDir},
+		DeterministicOrder: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.corpus.entries = append(c.corpus.entries, CorpusEntry{Path: filepath.Join(cacheDir, "b")})
+	c.inputQueue = queue{}
+	c.refillInputQueue()
+	var got []string
+	for c.inputQueue.len > 0 {
+		e, _ := c.inputQueue.dequeue()
+		got = append(got, filepath.Base(e.(CorpusEntry).Path))
+	}
+	if want := []string{"seed#1", "seed#0", "a", "b", "c", "d", "e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("entries queued in order %v; want %v", got, want)
+	}
+}
+
+func TestWorkerStartDelays(t *testing.T) {
+	const n, stagger = 5, 10 * time.Millisecond
+	c := &coordinator{opts: CoordinateFuzzingOpts{WorkerStartStagger: stagger, RunID: "run"}}
+	delays := c.workerStartDelays(n)
+	sorted := append([]time.Duration(nil), delays...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for i, d := range sorted {
+		if d != time.Duration(i)*stagger {
+			t.Fatalf("got delays %v; want a permutation of multiples of %v", delays, stagger)
+		}
+	}
+	if again := c.workerStartDelays(n); !reflect.DeepEqual(again, delays) {
+		t.Errorf("got delays %v, then %v for the same RunID", delays, again)
+	}
+
+	c.opts.WorkerStartStagger = 0
+	for _, d := range c.workerStartDelays(n) {
+		if d != 0 {
+			t.Fatalf("got delay %v with no WorkerStartStagger", d)
+		}
+	}
+}
+
+func TestReportNewCoverage(t *testing.T) {
+	type bit struct {
+		index int
+		entry string
+	}
+	var got []bit
+	c := &coordinator{
+		opts: CoordinateFuzzingOpts{OnNewCoverage: func(bitIndex int, byEntry string) {
+			got = append(got, bit{bitIndex, byEntry})
+		}},
+		coverageMask: []byte{0x1, 0},
+	}
+	for _, r := range []struct {
+		cov   []byte
+		entry string
+	}{
+		{[]byte{0x3, 0}, "a"},
+		{[]byte{0x3, 0x80}, "b"},
+		{[]byte{0x2, 0x80}, "c"},
+	} {
+		c.reportNewCoverage(r.cov, r.entry)
+		c.updateCoverage(r.cov)
+	}
+	if want := []bit{{1, "a"}, {15, "b"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("OnNewCoverage called with %v; want %v", got, want)
+	}
+}
+
+func TestReportNewCoverageWarmup(t *testing.T) {
+	// Coverage found while testing the seed corpus in a worker is reported
+	// with the path of the seed that found it.
+	var paths []string
+	err := coordinateForTest(t, "cover", CoordinateFuzzingOpts{
+		Limit: 1,
+		Seed: []CorpusEntry{
+			{Path: "seed#0", Data: marshalCorpusFile([]byte("a")), Values: []interface{}{[]byte("a")}, IsSeed: true},
+		},
+		OnNewCoverage: func(_ int, byEntry string) {
+			paths = append(paths, byEntry)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("OnNewCoverage wasn't called for the seed")
+	}
+	for _, p := range paths {
+		if p != "seed#0" {
+			t.Errorf("OnNewCoverage called with path %q; want seed#0", p)
+		}
+	}
+}
+
+func TestRefillInputQueueFocus(t *testing.T) {
+	c := &coordinator{
+		focusMask: []byte{0, 0xff},
+		focused:   make(map[string]bool),
+	}
+	c.corpus.entries = []CorpusEntry{{Path: "a"}, {Path: "b"}}
+	if c.reachesFocus([]byte{1, 0}) {
+		t.Error("coverage outside the focus was reported as reaching it")
+	}
+	if !c.reachesFocus([]byte{0, 2}) {
+		t.Error("coverage inside the focus was not reported as reaching it")
+	}
+	c.focused["b"] = true
+	c.refillInputQueue()
+	if got, want := c.inputQueue.len, 1+focusWeight; got != want {
+		t.Errorf("queued %d inputs; want %d", got, want)
+	}
+}
+
+func TestFocusCoverageWarmup(t *testing.T) {
+	// With the "cover" fuzz function, 'z' reaches the fourth counter and 'a'
+	// the first. Only the first seed reaches the focused counter during
+	// warmup, so it's fuzzed more often.
+	logPath := filepath.Join(t.TempDir(), "session")
+	err := coordinateForTest(t, "cover", CoordinateFuzzingOpts{
+		Limit: 5000,
+		Seed: []CorpusEntry{
+			{Path: "seed#0", Data: marshalCorpusFile([]byte("zzz")), Values: []interface{}{[]byte("zzz")}, IsSeed: true},
+			{Path: "seed#1", Data: marshalCorpusFile([]byte("aaa")), Values: []interface{}{[]byte("aaa")}, IsSeed: true},
+		},
+		FocusCoverage:     []int{3},
+		RecordSessionPath: logPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := openSession(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.close()
+	calls := map[string]int{}
+	for {
+		rec, err := r.peek()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		calls[rec.entry.Path]++
+		r.advance()
+	}
+	if focused, other := calls["seed#0"], calls["seed#1"]; focused < 2*other {
+		t.Errorf("focused seed fuzzed %d times, other seed %d times; want the focused seed fuzzed more", focused, other)
+	}
+}
+
+func TestReadCacheDirs(t *testing.T) {
+	dirs := []string{t.TempDir(), t.TempDir()}
+	write := func(dir, s string) string {
+		e := CorpusEntry{Data: marshalCorpusFile([]byte(s))}
+		if err := writeToCorpus(&e, dir); err != nil {
+			t.Fatal(err)
+		}
+		return e.Path
+	}
+	a := write(dirs[0], "a")
+	write(dirs[1], "a") // duplicate, ignored
+	b := write(dirs[1], "b")
+
+	types := []reflect.Type{reflect.TypeOf([]byte(nil))}
+	c, err := readCache(nil, types, dirs, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range c.entries {
+		got = append(got, e.Path)
+	}
+	if want := []string{a, b}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got entries %v; want %v", got, want)
+	}
+}
+
+func TestReadCacheSkipErrors(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symbolic links may not be allowed on Windows")
+	}
+	dir := t.TempDir()
+	good := CorpusEntry{Data: marshalCorpusFile([]byte("a"))}
+	if err := writeToCorpus(&good, dir); err != nil {
+		t.Fatal(err)
+	}
+	// A link to a missing file is listed in the directory but can't be read.
+	unreadable := filepath.Join(dir, "unreadable")
+	if err := os.Symlink(filepath.Join(dir, "missing"), unreadable); err != nil {
+		t.Fatal(err)
+	}
+	malformed := filepath.Join(dir, "malformed")
+	if err := ioutil.WriteFile(malformed, []byte("not a corpus file"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	types := []reflect.Type{reflect.TypeOf([]byte(nil))}
+	if _, err := readCache(nil, types, []string{dir}, false, false); err == nil {
+		t.Fatal("readCache succeeded with an unreadable file")
+	}
+	c, err := readCache(nil, types, []string{dir}, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.entries) != 1 || c.entries[0].Path != good.Path {
+		t.Errorf("got %d entries; want only %s", len(c.entries), good.Path)
+	}
+	var skipped []string
+	for _, ferr := range c.skipped {
+		skipped = append(skipped, ferr.path)
+	}
+	sort.Strings(skipped)
+	if want := []string{malformed, unreadable}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("skipped %v; want %v", skipped, want)
+	}
+}
+
+func TestRawCorpus(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "libfuzzer"), []byte("raw"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newCoordinator(CoordinateFuzzingOpts{
+		Types:     []reflect.Type{reflect.TypeOf("")},
+		CacheDir:  dir,
+		RawCorpus: true,
+	}); err == nil {
+		t.Fatal("RawCorpus allowed for a target without a []byte argument")
+	}
+	c, err := newCoordinator(CoordinateFuzzingOpts{
+		Types:     []reflect.Type{reflect.TypeOf([]byte(nil))},
+		Log:       io.Discard,
+		CacheDir:  dir,
+		RawCorpus: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.corpus.entries) != 1 || !bytes.Equal(c.corpus.entries[0].Data, marshalCorpusFile([]byte("raw"))) {
+		t.Fatalf("got corpus %v; want the raw file as a marshaled []byte", c.corpus.entries)
+	}
+
+	result := fuzzResult{entry: CorpusEntry{Data: marshalCorpusFile([]byte("new"))}}
+	if err := c.addInteresting(&result); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(result.entry.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "new" {
+		t.Errorf("interesting value written as %q; want raw bytes", got)
+	}
+	if result.entry.Data == nil {
+		t.Error("marshaled data for a raw cache entry was dropped")
+	}
+}
+
+// coordinateForTest runs CoordinateFuzzing with worker processes that run the
+// fuzz function named fn in testFuzzFns. Options left unset default to a
+// single worker fuzzing a []byte with a temporary corpus directory.
+func coordinateForTest(t *testing.T, fn string, opts CoordinateFuzzingOpts) error {
+	return coordinateForTestContext(context.Background(), t, fn, opts)
+}
+
+// coordinateForTestContext is like coordinateForTest, but fuzzing stops when
+// ctx is done.
+func coordinateForTestContext(ctx context.Context, t *testing.T, fn string, opts CoordinateFuzzingOpts) error {
+	t.Helper()
+	if opts.Log == nil {
+		opts.Log = io.Discard
+	}
+	if opts.Types == nil {
+		opts.Types = []reflect.Type{reflect.TypeOf([]byte(nil))}
+	}
+	if opts.Parallel == 0 {
+		opts.Parallel = 1
+	}
+	if opts.CorpusDir == "" {
+		opts.CorpusDir = t.TempDir()
+	}
+	registerTestFeedback.Do(func() {
+		RegisterFeedbackSource("test", theTestFeedback)
+	})
+	defer setFeedbackSource("")
+	opts.FeedbackSource = "test"
+	opts.WorkerEnv = append(opts.WorkerEnv, testFuzzFnEnv+"="+fn)
+	return CoordinateFuzzing(ctx, opts)
+}
+
+// countFiles returns the number of regular files under dir.
+func countFiles(t *testing.T, dir string) int {
+	t.Helper()
+	n := 0
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			n++
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestSeedCrashers(t *testing.T) {
+	seed := func(name, v string) CorpusEntry {
+		return CorpusEntry{Path: name, Data: marshalCorpusFile([]byte(v)), Values: []interface{}{[]byte(v)}, IsSeed: true}
+	}
+	for _, test := range []struct {
+		name            string
+		seed            []CorpusEntry
+		failOnSeedCrash bool
+		wantErr         bool
+		wantFuzzed      bool
+	}{
+		{
+			name:            "FailOnSeedCrash",
+			seed:            []CorpusEntry{seed("seed#0", "a!"), seed("seed#1", "b"), seed("seed#2", "c!")},
+			failOnSeedCrash: true,
+			wantErr:         true,
+		},
+		{
+			// The crashing seeds are removed, and the rest is fuzzed.
+			name:       "FuzzRest",
+			seed:       []CorpusEntry{seed("seed#0", "a!"), seed("seed#1", "b"), seed("seed#2", "c!")},
+			wantFuzzed: true,
+		},
+		{
+			name:    "AllCrash",
+			seed:    []CorpusEntry{seed("seed#0", "a!"), seed("seed#1", "c!")},
+			wantErr: true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var log bytes.Buffer
+			var sum Summary
+			err := coordinateForTest(t, "crash", CoordinateFuzzingOpts{
+				Log:             &log,
+				Limit:           20,
+				FailOnSeedCrash: test.failOnSeedCrash,
+				Seed:            test.seed,
+				OnFinish:        func(s Summary) { sum = s },
+			})
+			var crashErr *crashError
+			if test.wantErr && (err == nil || errors.As(err, &crashErr)) {
+				t.Errorf("got error %v; want the error from a seed", err)
+			} else if !test.wantErr && err != nil && !errors.As(err, &crashErr) {
+				// Fuzzing may find a new crasher.
+				t.Errorf("got error %v; want none, or a crasher found by fuzzing", err)
+			}
+			// All the seeds are tested and reported together, in order.
+			var got []string
+			for _, sc := range sum.SeedCrashers {
+				got = append(got, sc.Path)
+				if !strings.Contains(sc.Err, "input contains '!'") {
+					t.Errorf("seed crasher %s has error %q", sc.Path, sc.Err)
+				}
+			}
+			if want := []string{"seed#0", test.seed[len(test.seed)-1].Path}; !reflect.DeepEqual(got, want) {
+				t.Errorf("got seed crashers %v; want %v", got, want)
+			}
+			if n := strings.Count(log.String(), "found a crash while testing seed corpus entry"); n != 2 {
+				t.Errorf("logged %d seed crashes; want 2. Log:\n%s", n, log.String())
+			}
+			if !strings.Contains(log.String(), "2 seed corpus entries crashed") {
+				t.Errorf("crashing seeds weren't counted in the log:\n%s", log.String())
+			}
+			if fuzzed := sum.Execs > int64(len(test.seed)); fuzzed != test.wantFuzzed {
+				t.Errorf("got %d execs for %d seeds; want fuzzing %v", sum.Execs, len(test.seed), test.wantFuzzed)
+			}
+			if len(sum.Crashers) > 0 && !test.wantFuzzed {
+				t.Errorf("wrote crashers %v; want none", sum.Crashers)
+			}
+		})
+	}
+}
+
+func TestCorpusOnly(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	cacheDir := t.TempDir()
+	var sum Summary
+	err := coordinateForTestContext(ctx, t, "cover", CoordinateFuzzingOpts{
+		CacheDir:   cacheDir,
+		CorpusOnly: true,
+		Seed: []CorpusEntry{
+			{Path: "seed#0", Data: marshalCorpusFile([]byte("a")), Values: []interface{}{[]byte("a")}, IsSeed: true},
+			{Path: "seed#1", Data: marshalCorpusFile([]byte("b")), Values: []interface{}{[]byte("b")}, IsSeed: true},
+		},
+		OnFinish: func(s Summary) { sum = s },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("fuzzing didn't stop after testing the corpus")
+	}
+	if sum.Execs != 2 {
+		t.Errorf("got %d execs; want 2, one for each corpus entry", sum.Execs)
+	}
+	if sum.CorpusCoverageBits != 2 {
+		t.Errorf("got CorpusCoverageBits %d; want 2", sum.CorpusCoverageBits)
+	}
+	if n := countFiles(t, cacheDir); n != 0 {
+		t.Errorf("%d values written to the cache; want none", n)
+	}
+}
+
+func TestDeferCorpusWrite(t *testing.T) {
+	cacheDir := t.TempDir()
+	var written int
+	var sum Summary
+	err := coordinateForTest(t, "cover", CoordinateFuzzingOpts{
+		Limit:            2000,
+		CacheDir:         cacheDir,
+		DeferCorpusWrite: true,
+		Seed: []CorpusEntry{
+			{Path: "seed#0", Data: marshalCorpusFile([]byte("seed")), Values: []interface{}{[]byte("seed")}, IsSeed: true},
+		},
+		OnNewCoverage: func(int, string) {
+			written += countFiles(t, cacheDir)
+		},
+		OnFinish: func(s Summary) { sum = s },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != 0 {
+		t.Error("values were written to the cache while fuzzing")
+	}
+	if sum.CorpusGrowth == 0 {
+		t.Fatal("no interesting values were found")
+	}
+	// Interesting values found more than once share a file.
+	if n := countFiles(t, cacheDir); n == 0 || int64(n) > sum.CorpusGrowth {
+		t.Errorf("%d values written to the cache when fuzzing stopped; want 1 to %d", n, sum.CorpusGrowth)
+	}
+}
+
+func TestCorpusFlusher(t *testing.T) {
+	cacheDir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	flusher := NewCorpusFlusher()
+	found := make(chan struct{}, 1)
+	flushed := make(chan int, 1)
+	go func() {
+		<-found
+		if err := flusher.Flush(); err != nil {
+			t.Error(err)
+		}
+		flushed <- countFiles(t, cacheDir)
+		cancel()
+	}()
+	err := coordinateForTestContext(ctx, t, "cover", CoordinateFuzzingOpts{
+		CacheDir:         cacheDir,
+		DeferCorpusWrite: true,
+		Flusher:          flusher,
+		Seed: []CorpusEntry{
+			{Path: "seed#0", Data: marshalCorpusFile([]byte("seed")), Values: []interface{}{[]byte("seed")}, IsSeed: true},
+		},
+		OnNewCoverage: func(_ int, byEntry string) {
+			if !strings.HasPrefix(byEntry, cacheDir) {
+				return // coverage of the seed corpus
+			}
+			select {
+			case found <- struct{}{}:
+			default:
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := <-flushed; n == 0 {
+		t.Error("Flush returned before interesting values were written to the cache")
+	}
+	// Flush doesn't block once fuzzing has stopped.
+	if err := flusher.Flush(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTotalMinimizeBudget(t *testing.T) {
+	c, err := newCoordinator(CoordinateFuzzingOpts{
+		Types:               []reflect.Type{reflect.TypeOf([]byte(nil))},
+		Log:                 io.Discard,
+		MinimizeTimeout:     10 * time.Second,
+		TotalMinimizeBudget: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	crash := fuzzResult{entry: CorpusEntry{Data: marshalCorpusFile([]byte("crash"))}, crasherMsg: "boom"}
+	c.queueForMinimization(crash, nil)
+	for _, test := range []struct {
+		spent, want time.Duration
+	}{
+		{0, time.Second},
+		{700 * time.Millisecond, 300 * time.Millisecond},
+	} {
+		c.crashMinimizeDuration = test.spent
+		input, ok := c.peekMinimizeInput()
+		if !ok || input.timeout != test.want {
+			t.Errorf("with %v of the budget spent, crasher minimized with timeout %v; want %v", test.spent, input.timeout, test.want)
+		}
+		if c.minimizeBudgetSpent() {
+			t.Errorf("budget spent after %v", test.spent)
+		}
+	}
+	c.crashMinimizeDuration = time.Second
+	if !c.minimizeBudgetSpent() {
+		t.Error("budget not spent after 1s")
+	}
+
+	// A minimization that runs out of time is still charged to the budget.
+	var sum Summary
+	err = coordinateForTest(t, "crash", CoordinateFuzzingOpts{
+		Limit:               100000,
+		MinimizeTimeout:     10 * time.Second,
+		TotalMinimizeBudget: time.Nanosecond,
+		Seed: []CorpusEntry{
+			{Path: "seed#0", Data: marshalCorpusFile([]byte("seed")), Values: []interface{}{[]byte("seed")}, IsSeed: true},
+		},
+		OnFinish: func(s Summary) { sum = s },
+	})
+	if err == nil {
+		t.Fatal("fuzzing stopped without finding a crash")
+	}
+	if len(sum.Crashers) != 1 {
+		t.Fatalf("summary has %d crashers; want 1", len(sum.Crashers))
+	}
+	if sum.MinimizeDuration <= 0 {
+		t.Errorf("minimization that timed out charged %v to the budget; want more than 0", sum.MinimizeDuration)
+	}
+}
+
+func TestCrasherFilter(t *testing.T) {
+	var filtered int
+	var sum Summary
+	err := coordinateForTest(t, "crash", CoordinateFuzzingOpts{
+		Limit: 1000000,
+		Seed: []CorpusEntry{
+			{Path: "seed#0", Data: marshalCorpusFile([]byte("seed")), Values: []interface{}{[]byte("seed")}, IsSeed: true},
+		},
+		CrasherFilter: func(e CorpusEntry, errMsg string) bool {
+			if !strings.Contains(errMsg, "input contains '!'") {
+				t.Errorf("CrasherFilter called with error %q", errMsg)
+			}
+			if vals, err := unmarshalCorpusFile(e.Data); err != nil || !bytes.Contains(vals[0].([]byte), []byte("!")) {
+				t.Errorf("CrasherFilter called with an input that doesn't crash: %q", e.Data)
+			}
+			// Ignore the first two crashes, like known bugs.
+			filtered++
+			return filtered > 2
+		},
+		OnFinish: func(s Summary) { sum = s },
+	})
+	if err == nil {
+		t.Fatal("fuzzing stopped without reporting a crash")
+	}
+	if filtered != 3 {
+		t.Errorf("CrasherFilter called %d times; want 3", filtered)
+	}
+	if sum.FilteredCrashers != 2 {
+		t.Errorf("got FilteredCrashers %d; want 2", sum.FilteredCrashers)
+	}
+	if len(sum.Crashers) != 1 {
+		t.Errorf("summary has %d crashers; want 1", len(sum.Crashers))
+	}
+}
+
+func TestNoMinimize(t *testing.T) {
+	for _, noMinimize := range []bool{false, true} {
+		var sum Summary
+		var log bytes.Buffer
+		err := coordinateForTest(t, "crash", CoordinateFuzzingOpts{
+			Log:           &log,
+			Limit:         1000000,
+			MinimizeLimit: 10000,
+			NoMinimize:    noMinimize,
+			Seed: []CorpusEntry{
+				{Path: "seed#0", Data: marshalCorpusFile([]byte("seedseedseed")), Values: []interface{}{[]byte("seedseedseed")}, IsSeed: true},
+			},
+			OnFinish: func(s Summary) { sum = s },
+		})
+		if err == nil {
+			t.Fatalf("NoMinimize=%v: fuzzing stopped without finding a crash", noMinimize)
+		}
+		if len(sum.Crashers) != 1 {
+			t.Fatalf("NoMinimize=%v: summary has %d crashers; want 1", noMinimize, len(sum.Crashers))
+		}
+		data, err := os.ReadFile(sum.Crashers[0].Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vals, err := unmarshalCorpusFile(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := vals[0].([]byte); !noMinimize && string(got) != "!" {
+			t.Errorf("wrote crasher %q; want it minimized to %q", got, "!")
+		}
+		if minimized := strings.Contains(log.String(), "minimizing"); minimized == noMinimize {
+			t.Errorf("NoMinimize=%v: log:\n%s", noMinimize, log.String())
+		}
+		if spent := sum.MinimizeDuration > 0; spent == noMinimize {
+			t.Errorf("NoMinimize=%v: spent %v minimizing", noMinimize, sum.MinimizeDuration)
+		}
+	}
+}
+
+func TestMinimizedCrasher(t *testing.T) {
+	crash := fuzzResult{entry: CorpusEntry{Path: "crash"}, crasherMsg: "boom"}
+	c := &coordinator{crashMinimizing: &crash}
+	for _, test := range []struct {
+		result fuzzResult
+		want   bool
+	}{
+		{fuzzResult{inputPath: "crash", minimized: true, crasherMsg: "boom"}, true},
+		// A crash found while minimizing an interesting input.
+		{fuzzResult{inputPath: "interesting", minimized: true, crasherMsg: "boom"}, false},
+		{fuzzResult{inputPath: "crash", crasherMsg: "boom"}, false},
+	} {
+		if got := c.minimizedCrasher(test.result); got != test.want {
+			t.Errorf("minimizedCrasher(%+v) = %v; want %v", test.result, got, test.want)
+		}
+	}
+}
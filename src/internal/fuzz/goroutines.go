@@ -0,0 +1,73 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import "fmt"
+
+const (
+	// goroutineSampleInterval is the number of calls to the fuzz function
+	// between samples of the number of goroutines.
+	goroutineSampleInterval = 64
+
+	// goroutineWindowSize is the number of samples in each window. Only the
+	// smallest sample in a window is considered, so goroutines that are started
+	// by the fuzz function and exit soon after don't look like a leak.
+	goroutineWindowSize = 16
+
+	// goroutineLeakWindows is the number of consecutive windows in which the
+	// smallest sample must grow before a leak is reported.
+	goroutineLeakWindows = 4
+
+	// goroutineLeakMin is the number of goroutines above the number running
+	// before fuzzing that must be exceeded before a leak is reported.
+	goroutineLeakMin = 100
+)
+
+// goroutineProbe detects fuzz functions that start goroutines without
+// stopping them. The worker samples the number of running goroutines
+// periodically and reports a leak if the number keeps growing.
+type goroutineProbe struct {
+	calls     int // calls to the fuzz function, counted by the worker
+	baseline  int // goroutines running before the first sample; -1 if unset
+	windowMin int // smallest sample in the current window
+	samples   int // number of samples in the current window
+	lastMin   int // smallest sample in the previous window
+	grows     int // number of consecutive windows where the smallest sample grew
+	reported  bool
+}
+
+func newGoroutineProbe() *goroutineProbe {
+	return &goroutineProbe{baseline: -1}
+}
+
+// sample records the number of running goroutines, n. It returns a non-empty
+// warning the first time the samples indicate that goroutines are leaking.
+func (p *goroutineProbe) sample(n int) string {
+	if p.baseline < 0 {
+		p.baseline = n
+		p.lastMin = n
+	}
+	if p.samples == 0 || n < p.windowMin {
+		p.windowMin = n
+	}
+	p.samples++
+	if p.samples < goroutineWindowSize {
+		return ""
+	}
+
+	// End of window.
+	if p.windowMin > p.lastMin {
+		p.grows++
+	} else {
+		p.grows = 0
+	}
+	p.lastMin = p.windowMin
+	p.samples = 0
+	if p.reported || p.grows < goroutineLeakWindows || p.lastMin-p.baseline < goroutineLeakMin {
+		return ""
+	}
+	p.reported = true
+	return fmt.Sprintf("the number of goroutines in a fuzzing process grew from %d to %d; the fuzz function may be leaking goroutines", p.baseline, p.lastMin)
+}
@@ -0,0 +1,37 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import "testing"
+
+func TestGoroutineProbe(t *testing.T) {
+	t.Run("leak", func(t *testing.T) {
+		p := newGoroutineProbe()
+		var warnings int
+		for n := 10; n < 10000; n++ {
+			if p.sample(n) != "" {
+				warnings++
+			}
+		}
+		if warnings != 1 {
+			t.Errorf("got %d warnings; want 1", warnings)
+		}
+	})
+
+	t.Run("spikes", func(t *testing.T) {
+		p := newGoroutineProbe()
+		for i := 0; i < 10000; i++ {
+			n := 10
+			if i%3 != 0 {
+				// Goroutines started by the fuzz function that are still
+				// exiting, growing over time but never all at once.
+				n += i
+			}
+			if w := p.sample(n); w != "" {
+				t.Fatalf("unexpected warning after %d samples: %s", i, w)
+			}
+		}
+	})
+}
@@ -0,0 +1,249 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// grammarMaxDepth is the depth of nested rules, and grammarMaxLen the length
+// of the input generated so far, beyond which Grammar.Generate only chooses
+// the alternatives that finish expanding soonest, so generated inputs stay
+// reasonably small.
+const (
+	grammarMaxDepth = 24
+	grammarMaxLen   = 4096
+)
+
+// Grammar is a context-free grammar used to generate syntactically valid
+// inputs for fuzz targets that parse structured formats, like SQL or JSON,
+// which randomly mutated bytes rarely are. See ParseGrammar and
+// CoordinateFuzzingOpts.Generator.
+type Grammar struct {
+	start string
+	rules map[string][]grammarAlt
+	// height is the fewest levels of nested rules each rule can be expanded
+	// with. See setHeights.
+	height map[string]int
+}
+
+// grammarAlt is one alternative of a rule: a sequence of symbols.
+type grammarAlt struct {
+	syms []grammarSymbol
+	// height is the fewest levels of nested rules the alternative can be
+	// expanded with.
+	height int
+}
+
+// grammarSymbol is a literal or a reference to a rule.
+type grammarSymbol struct {
+	lit  string
+	rule string // if empty, the symbol is lit
+}
+
+// ParseGrammar parses a grammar written in a simple form of BNF. Each rule
+// is written on a line as a name, "=", and alternatives separated by "|".
+// An alternative is a sequence of literals, which are Go quoted strings,
+// and names of rules, and may be empty. A line starting with "|" adds
+// alternatives to the rule before it. Lines starting with "#" are comments.
+// The first rule is the one inputs are generated from. For example:
+//
+//	expr   = term | term "+" expr
+//	term   = number | "(" expr ")"
+//	number = digit | digit number
+//	digit  = "0" | "1" | "2" | "3" | "4" | "5" | "6" | "7" | "8" | "9"
+//
+// Every rule must be defined, and must be possible to expand without
+// referring to itself forever.
+func ParseGrammar(text string) (*Grammar, error) {
+	g := &Grammar{rules: make(map[string][]grammarAlt)}
+	var cur string
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var body string
+		if strings.HasPrefix(line, "|") {
+			if cur == "" {
+				return nil, fmt.Errorf("line %d: alternatives without a rule", i+1)
+			}
+			body = line[1:]
+		} else {
+			eq := strings.Index(line, "=")
+			if eq < 0 {
+				return nil, fmt.Errorf("line %d: expected name = alternatives", i+1)
+			}
+			cur = strings.TrimSpace(line[:eq])
+			if !isGrammarName(cur) {
+				return nil, fmt.Errorf("line %d: invalid rule name %q", i+1, cur)
+			}
+			if _, ok := g.rules[cur]; ok {
+				return nil, fmt.Errorf("line %d: rule %s is defined more than once", i+1, cur)
+			}
+			if g.start == "" {
+				g.start = cur
+			}
+			g.rules[cur] = nil
+			body = line[eq+1:]
+		}
+		alts, err := parseGrammarAlts(body)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		g.rules[cur] = append(g.rules[cur], alts...)
+	}
+	if g.start == "" {
+		return nil, fmt.Errorf("grammar has no rules")
+	}
+	for name, alts := range g.rules {
+		for _, alt := range alts {
+			for _, sym := range alt.syms {
+				if _, ok := g.rules[sym.rule]; sym.rule != "" && !ok {
+					return nil, fmt.Errorf("rule %s refers to undefined rule %s", name, sym.rule)
+				}
+			}
+		}
+	}
+	if err := g.setHeights(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// parseGrammarAlts parses alternatives separated by "|".
+func parseGrammarAlts(s string) ([]grammarAlt, error) {
+	alts := []grammarAlt{{}}
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return alts, nil
+		}
+		switch c := s[0]; {
+		case c == '|':
+			alts = append(alts, grammarAlt{})
+			s = s[1:]
+		case c == '"' || c == '`':
+			q, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid literal at %q", s)
+			}
+			lit, _ := strconv.Unquote(q)
+			alt := &alts[len(alts)-1]
+			alt.syms = append(alt.syms, grammarSymbol{lit: lit})
+			s = s[len(q):]
+		default:
+			n := 0
+			for n < len(s) && isGrammarNameByte(s[n]) {
+				n++
+			}
+			if n == 0 {
+				return nil, fmt.Errorf("unexpected %q", s)
+			}
+			alt := &alts[len(alts)-1]
+			alt.syms = append(alt.syms, grammarSymbol{rule: s[:n]})
+			s = s[n:]
+		}
+	}
+}
+
+func isGrammarName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isGrammarNameByte(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isGrammarNameByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// setHeights computes the height of each rule and alternative, and returns
+// an error if a rule can't be expanded without referring to itself forever.
+func (g *Grammar) setHeights() error {
+	g.height = make(map[string]int)
+	for changed := true; changed; {
+		changed = false
+		for name, alts := range g.rules {
+			for i := range alts {
+				h, ok := g.altHeight(alts[i])
+				if !ok {
+					continue
+				}
+				if old, ok := g.height[name]; !ok || h < old {
+					g.height[name] = h
+					changed = true
+				}
+			}
+		}
+	}
+	for name, alts := range g.rules {
+		if _, ok := g.height[name]; !ok {
+			return fmt.Errorf("rule %s can never be fully expanded", name)
+		}
+		for i := range alts {
+			alts[i].height, _ = g.altHeight(alts[i])
+		}
+	}
+	return nil
+}
+
+// altHeight returns the height of alt from the heights of rules known so far.
+func (g *Grammar) altHeight(alt grammarAlt) (int, bool) {
+	h := 0
+	for _, sym := range alt.syms {
+		if sym.rule == "" {
+			continue
+		}
+		sh, ok := g.height[sym.rule]
+		if !ok {
+			return 0, false
+		}
+		if sh+1 > h {
+			h = sh + 1
+		}
+	}
+	return h, true
+}
+
+// Generate returns a random input derived from the grammar's first rule.
+// It can be used as CoordinateFuzzingOpts.Generator.
+func (g *Grammar) Generate(r *rand.Rand) []byte {
+	return g.expand(nil, g.start, 0, r)
+}
+
+func (g *Grammar) expand(b []byte, rule string, depth int, r *rand.Rand) []byte {
+	alts := g.rules[rule]
+	var alt grammarAlt
+	if depth < grammarMaxDepth && len(b) < grammarMaxLen {
+		alt = alts[r.Intn(len(alts))]
+	} else {
+		// Choose among the alternatives that finish soonest, so expansion
+		// ends: each level down, the height decreases.
+		var shortest []grammarAlt
+		for _, a := range alts {
+			if a.height == g.height[rule] {
+				shortest = append(shortest, a)
+			}
+		}
+		alt = shortest[r.Intn(len(shortest))]
+	}
+	for _, sym := range alt.syms {
+		if sym.rule == "" {
+			b = append(b, sym.lit...)
+		} else {
+			b = g.expand(b, sym.rule, depth+1, r)
+		}
+	}
+	return b
+}
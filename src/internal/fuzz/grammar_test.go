@@ -0,0 +1,75 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestGrammarGenerate(t *testing.T) {
+	g, err := ParseGrammar(`
+# Arithmetic on single digits.
+expr  = term | expr "+" expr
+      | expr "*" expr
+term  = digit | "(" expr ")"
+digit = "0" | "1" | ` + "`2`" + `
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rand.New(rand.NewSource(1))
+	sawOp := false
+	for i := 0; i < 1000; i++ {
+		s := string(g.Generate(r))
+		depth := 0
+		for j, c := range s {
+			switch {
+			case c == '(':
+				depth++
+			case c == ')':
+				depth--
+			case strings.ContainsRune("+*", c):
+				sawOp = true
+				if j == 0 || j == len(s)-1 {
+					t.Fatalf("generated %q with a misplaced operator", s)
+				}
+			case !strings.ContainsRune("012", c):
+				t.Fatalf("generated %q with unexpected %q", s, c)
+			}
+			if depth < 0 {
+				t.Fatalf("generated %q with unbalanced parentheses", s)
+			}
+		}
+		if depth != 0 || s == "" {
+			t.Fatalf("generated invalid expression %q", s)
+		}
+		if len(s) > 2*grammarMaxLen {
+			t.Fatalf("generated a %d-byte expression", len(s))
+		}
+	}
+	if !sawOp {
+		t.Error("never generated an expression with an operator")
+	}
+}
+
+func TestParseGrammarErrors(t *testing.T) {
+	for _, text := range []string{
+		"",
+		"a = b",
+		`a = "x"
+a = "y"`,
+		"a = a",
+		"a = b\nb = a",
+		`a = "x`,
+		"| \"x\"",
+		"a b",
+	} {
+		if _, err := ParseGrammar(text); err == nil {
+			t.Errorf("ParseGrammar(%q) succeeded; want an error", text)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// noteActivity records that the coordinator sent an input to a worker or
+// received a result, so workers aren't idle.
+func (c *coordinator) noteActivity() {
+	c.lastActivity = time.Now()
+	c.idleWarned = false
+}
+
+// checkIdleWorkers warns, or panics with opts.PanicOnIdleWorkers, if no input
+// has been sent to a worker and no result received for longer than
+// opts.IdleWorkersTimeout. It's called periodically, and warns once each time
+// workers become idle.
+func (c *coordinator) checkIdleWorkers() {
+	if c.opts.IdleWorkersTimeout <= 0 || c.idleWarned || c.lastActivity.IsZero() {
+		return
+	}
+	idle := time.Since(c.lastActivity)
+	if idle < c.opts.IdleWorkersTimeout {
+		return
+	}
+	msg := fmt.Sprintf("all workers have been idle for %v: no input was sent to a worker and no result received; input queue: %d entries, minimization queue: %d entries, corpus: %d entries", idle.Round(time.Second), c.inputQueue.len, c.minimizeQueue.len, len(c.corpus.entries))
+	if c.opts.PanicOnIdleWorkers {
+		buf := make([]byte, 1<<20)
+		buf = buf[:runtime.Stack(buf, true)]
+		panic(fmt.Sprintf("fuzz: %s\n\n%s", msg, buf))
+	}
+	c.logf(LogWarn, "warning: %s\n", msg)
+	c.idleWarned = true
+}
@@ -0,0 +1,40 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckIdleWorkers(t *testing.T) {
+	var log bytes.Buffer
+	c := &coordinator{opts: CoordinateFuzzingOpts{Log: &log, IdleWorkersTimeout: time.Minute}}
+	c.noteActivity()
+	c.checkIdleWorkers()
+	if log.Len() != 0 {
+		t.Fatalf("warned about workers that were just active: %s", log.String())
+	}
+
+	c.lastActivity = time.Now().Add(-2 * time.Minute)
+	c.checkIdleWorkers()
+	c.checkIdleWorkers()
+	if n := strings.Count(log.String(), "all workers have been idle"); n != 1 {
+		t.Errorf("got %d warnings about idle workers; want 1:\n%s", n, log.String())
+	}
+
+	c.opts.PanicOnIdleWorkers = true
+	c.noteActivity()
+	c.lastActivity = time.Now().Add(-2 * time.Minute)
+	defer func() {
+		msg, _ := recover().(string)
+		if !strings.Contains(msg, "all workers have been idle") || !strings.Contains(msg, "goroutine ") {
+			t.Errorf("got panic %q; want a message with goroutine stacks", msg)
+		}
+	}()
+	c.checkIdleWorkers()
+}
@@ -0,0 +1,91 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// newRunTag returns the name that identifies this run's files when
+// opts.IsolateRun is set. It's derived from opts.RunID if that's set, and
+// otherwise unique to the process and time.
+func newRunTag(opts CoordinateFuzzingOpts) string {
+	if opts.RunID == "" {
+		return fmt.Sprintf("%d-%d", os.Getpid(), time.Now().UnixNano())
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, opts.RunID)
+}
+
+// cacheWriteDir returns the directory interesting values are written to:
+// opts.CacheDir, or a subdirectory of it for this run if opts.IsolateRun is
+// set.
+func (c *coordinator) cacheWriteDir() string {
+	if c.runTag == "" {
+		return c.opts.CacheDir
+	}
+	return filepath.Join(c.opts.CacheDir, "run-"+c.runTag)
+}
+
+// mergeRunDir moves the interesting values written to this run's
+// subdirectory of opts.CacheDir into opts.CacheDir, then removes the
+// subdirectory. Each file is moved with a rename, so other runs reading the
+// cache never see a partially written file. A file whose name is already
+// used for different data in opts.CacheDir is renamed with the run's tag.
+// Metadata written for a file is moved along with it.
+func (c *coordinator) mergeRunDir() error {
+	dir := c.cacheWriteDir()
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		src := filepath.Join(dir, file.Name())
+		data, err := ioutil.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(c.opts.CacheDir, file.Name())
+		if _, err := os.Stat(dst); err == nil {
+			if sameFileData(dst, data) {
+				// Another run found the same value.
+				if err := os.Remove(src); err != nil {
+					return err
+				}
+				continue
+			}
+			dst = filepath.Join(c.opts.CacheDir, file.Name()+"-"+c.runTag)
+		}
+		if err := os.Rename(src, dst); err != nil {
+			return err
+		}
+		if _, err := os.Stat(entryMetaPath(src)); err == nil {
+			if err := os.MkdirAll(filepath.Dir(entryMetaPath(dst)), 0777); err != nil {
+				return err
+			}
+			if err := os.Rename(entryMetaPath(src), entryMetaPath(dst)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := os.RemoveAll(filepath.Join(dir, metaDir)); err != nil {
+		return err
+	}
+	return os.Remove(dir)
+}
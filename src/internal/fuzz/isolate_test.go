@@ -0,0 +1,63 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeRunDir(t *testing.T) {
+	cacheDir := t.TempDir()
+	c := &coordinator{
+		opts:   CoordinateFuzzingOpts{CacheDir: cacheDir, RunID: "a/b"},
+		runTag: newRunTag(CoordinateFuzzingOpts{RunID: "a/b"}),
+	}
+	runDir := c.cacheWriteDir()
+	if filepath.Dir(runDir) != cacheDir {
+		t.Fatalf("run directory %s is not in the cache directory", runDir)
+	}
+	write := func(path, data string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(cacheDir, "same"), "1")
+	write(filepath.Join(cacheDir, "clash"), "2")
+	write(filepath.Join(runDir, "same"), "1")
+	write(filepath.Join(runDir, "clash"), "3")
+	write(filepath.Join(runDir, "new"), "4")
+
+	if err := c.mergeRunDir(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"same":              "1",
+		"clash":             "2",
+		"clash-" + c.runTag: "3",
+		"new":               "4",
+	}
+	files, err := ioutil.ReadDir(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != len(want) {
+		t.Errorf("cache has %d files; want %d", len(files), len(want))
+	}
+	for name, data := range want {
+		got, err := ioutil.ReadFile(filepath.Join(cacheDir, name))
+		if err != nil {
+			t.Error(err)
+		} else if string(got) != data {
+			t.Errorf("%s contains %q; want %q", name, got, data)
+		}
+	}
+}
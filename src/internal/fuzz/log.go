@@ -0,0 +1,41 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import "fmt"
+
+// LogLevel is the severity of a message written to CoordinateFuzzingOpts.Log.
+// Messages less severe than CoordinateFuzzingOpts.LogLevel are discarded.
+type LogLevel int
+
+const (
+	// LogDebug messages trace the coordinator's decisions, the lifecycle of
+	// worker processes, and calls to workers. They're mainly useful for
+	// diagnosing problems with fuzzing itself.
+	LogDebug LogLevel = iota - 1
+
+	// LogInfo messages report progress. This is the default level.
+	LogInfo
+
+	// LogWarn messages report likely problems with the fuzz function or the
+	// options, and crashers.
+	LogWarn
+)
+
+// logEnabled returns whether messages of the given level are written to
+// opts.Log. Debug messages are also enabled by GODEBUG=fuzzdebug=1.
+func (c *coordinator) logEnabled(level LogLevel) bool {
+	if level == LogDebug && shouldPrintDebugInfo() {
+		return true
+	}
+	return level >= c.opts.LogLevel
+}
+
+// logf writes a message to opts.Log if messages of the given level are enabled.
+func (c *coordinator) logf(level LogLevel, format string, args ...interface{}) {
+	if c.logEnabled(level) {
+		fmt.Fprintf(c.opts.Log, format, args...)
+	}
+}
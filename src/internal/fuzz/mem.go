@@ -46,6 +46,24 @@ type sharedMemHeader struct {
 
 	// randState and randInc hold the state of a pseudo-random number generator.
 	randState, randInc uint64
+
+	// crasherValue is true if the worker replaced the value with the exact
+	// input that caused an error, so the coordinator doesn't need to
+	// reconstruct it by replaying the mutator, or, while minimizing, fall back
+	// to a value that doesn't cause the error. Reset by coordinator.
+	crasherValue bool
+
+	// inFuzzFn is true while the worker is calling the fuzz function. If the
+	// worker process exits while it's set, the fuzz function caused the exit.
+	// Reset by coordinator.
+	inFuzzFn bool
+
+	// deflakes is the number of calls counted in count that ran a value
+	// again to deflake it, rather than a new mutation. The coordinator
+	// replays count-deflakes mutations to reconstruct the last value, even
+	// if the worker process terminated before responding. Reset by
+	// coordinator.
+	deflakes int64
 }
 
 // sharedMemSize returns the size needed for a shared memory buffer that can
@@ -55,11 +73,12 @@ func sharedMemSize(valueSize int) int {
 	return int(unsafe.Sizeof(sharedMemHeader{})) + valueSize
 }
 
-// sharedMemTempFile creates a new temporary file of the given size, then maps
+// sharedMemTempFile creates a new temporary file of the given size in dir, or
+// the default directory for temporary files if dir is empty, then maps
 // it into memory. The file will be removed when the Close method is called.
-func sharedMemTempFile(size int) (m *sharedMem, err error) {
+func sharedMemTempFile(dir string, size int) (m *sharedMem, err error) {
 	// Create a temporary file.
-	f, err := ioutil.TempFile("", "fuzz-*")
+	f, err := ioutil.TempFile(dir, "fuzz-*")
 	if err != nil {
 		return nil, err
 	}
@@ -81,6 +100,17 @@ func sharedMemTempFile(size int) (m *sharedMem, err error) {
 	return sharedMemMapFile(f, totalSize, removeOnClose)
 }
 
+// checkWritableDir returns an error if files can't be created in dir.
+func checkWritableDir(dir string) error {
+	f, err := ioutil.TempFile(dir, "fuzz-check-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
+
 // header returns a pointer to metadata within the shared memory region.
 func (m *sharedMem) header() *sharedMemHeader {
 	return (*sharedMemHeader)(unsafe.Pointer(&m.region[0]))
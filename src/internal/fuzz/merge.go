@@ -0,0 +1,119 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// MergeCorpora copies a minimal set of corpus entries from the directories in
+// srcs into dst, keeping enough entries to preserve the total coverage of all
+// of them.
+//
+// runFn is called with the contents of each corpus file and should run the
+// fuzz target with it, returning a snapshot of the coverage counters
+// (see SnapshotCoverage). Entries already in dst are kept, and only entries
+// that add coverage beyond them are copied. Entries are selected greedily:
+// the entry that adds the most new coverage is copied first, preferring
+// smaller entries when there's a tie, until no remaining entry adds coverage.
+func MergeCorpora(dst string, srcs []string, runFn func([]byte) []byte) error {
+	type candidate struct {
+		data []byte
+		cov  []byte
+	}
+	var covered []byte
+	addCoverage := func(cov []byte) error {
+		if covered == nil {
+			covered = make([]byte, len(cov))
+		}
+		if len(cov) != len(covered) {
+			return fmt.Errorf("the number of coverage bits changed: before=%d, after=%d", len(covered), len(cov))
+		}
+		for i := range cov {
+			covered[i] |= cov[i]
+		}
+		return nil
+	}
+
+	existing, err := readCorpusDir(dst)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, data := range existing {
+		seen[string(data)] = true
+		if err := addCoverage(runFn(data)); err != nil {
+			return err
+		}
+	}
+
+	var candidates []candidate
+	for _, src := range srcs {
+		files, err := readCorpusDir(src)
+		if err != nil {
+			return err
+		}
+		for _, data := range files {
+			if seen[string(data)] {
+				continue
+			}
+			seen[string(data)] = true
+			cov := append([]byte(nil), runFn(data)...)
+			if covered == nil {
+				covered = make([]byte, len(cov))
+			}
+			if len(cov) != len(covered) {
+				return fmt.Errorf("the number of coverage bits changed: before=%d, after=%d", len(covered), len(cov))
+			}
+			candidates = append(candidates, candidate{data: data, cov: cov})
+		}
+	}
+
+	for {
+		best, bestBits := -1, 0
+		for i, c := range candidates {
+			n := countNewCoverageBits(covered, c.cov)
+			if n > bestBits || (n == bestBits && n > 0 && len(c.data) < len(candidates[best].data)) {
+				best, bestBits = i, n
+			}
+		}
+		if best < 0 {
+			return nil
+		}
+		c := candidates[best]
+		if err := writeToCorpus(&CorpusEntry{Data: c.data}, dst); err != nil {
+			return err
+		}
+		addCoverage(c.cov)
+		candidates[best] = candidates[len(candidates)-1]
+		candidates = candidates[:len(candidates)-1]
+	}
+}
+
+// readCorpusDir returns the contents of each file in dir. It returns no error
+// if dir does not exist.
+func readCorpusDir(dir string) ([][]byte, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var contents [][]byte
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read corpus file: %v", err)
+		}
+		contents = append(contents, data)
+	}
+	return contents, nil
+}
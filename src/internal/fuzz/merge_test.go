@@ -0,0 +1,61 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestMergeCorpora(t *testing.T) {
+	dir := t.TempDir()
+	write := func(sub, name, data string) {
+		d := filepath.Join(dir, sub)
+		if err := os.MkdirAll(d, 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(d, name), []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Each byte of an entry covers the counter at its index in "abcd".
+	runFn := func(data []byte) []byte {
+		cov := make([]byte, 4)
+		for _, b := range data {
+			if b >= 'a' && b <= 'd' {
+				cov[b-'a'] = 1
+			}
+		}
+		return cov
+	}
+	write("dst", "x", "a")
+	write("src1", "1", "ab")
+	write("src1", "2", "b")
+	write("src2", "3", "bcd")
+	write("src2", "4", "a")
+	write("src2", "5", "ab")
+
+	dst := filepath.Join(dir, "dst")
+	srcs := []string{filepath.Join(dir, "src1"), filepath.Join(dir, "src2"), filepath.Join(dir, "missing")}
+	if err := MergeCorpora(dst, srcs, runFn); err != nil {
+		t.Fatal(err)
+	}
+	contents, err := readCorpusDir(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, data := range contents {
+		got = append(got, string(data))
+	}
+	sort.Strings(got)
+	want := []string{"a", "bcd"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
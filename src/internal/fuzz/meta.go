@@ -0,0 +1,206 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// metaDir is the name of the subdirectory of a cache directory where
+// metadata about entries is stored. ReadCorpus skips subdirectories, so
+// metadata files are never loaded as corpus entries.
+const metaDir = ".meta"
+
+// crasherMetaDir is the name of the subdirectory of metaDir where metadata
+// about crashers is stored. Crashers are written to the seed corpus in
+// testdata, which is usually checked in, so their metadata is kept in the
+// cache directory instead; see crasherMetaPath.
+const crasherMetaDir = "crashers"
+
+// entryMeta holds information about a corpus entry that is not part of its
+// encoded values, like the error a crasher caused. It's stored as JSON next to
+// the entry; see entryMetaPath. Metadata for a crasher is stored in the cache
+// directory; see crasherMetaPath.
+type entryMeta struct {
+	// Err is the error message reported for a crasher.
+	Err string `json:",omitempty"`
+
+	// Env is a list of environment variables in the form "key=value" that were
+	// set in the worker process when a crasher was found. The same variables
+	// should be set when reproducing or minimizing the crasher.
+	Env []string `json:",omitempty"`
+
+	// NewCoverage lists the indexes of the coverage counters a crasher
+	// reached that no entry in the corpus had reached when the crasher was
+	// written. The indexes match the counters in coverage profiles, so they
+	// can be mapped back to source locations. It shows which code paths are
+	// likely involved in the crash.
+	NewCoverage []int `json:",omitempty"`
+
+	// Found is when a crasher was found, before it was minimized. It's zero
+	// for crashers written before it was recorded.
+	Found time.Time
+
+	// ReproduceRuns is the number of times a crasher was run again before it
+	// was written, and Reproduced is the number of those runs that failed.
+	// See CoordinateFuzzingOpts.CrasherReproduceRuns.
+	ReproduceRuns int `json:",omitempty"`
+	Reproduced    int `json:",omitempty"`
+
+	// Signature is the stack signature of a crasher. See Crasher.Signature.
+	Signature string `json:",omitempty"`
+
+	// Derivation records how an interesting value was derived. See
+	// CoordinateFuzzingOpts.RecordDerivation.
+	Derivation *entryDerivation `json:",omitempty"`
+}
+
+// entryDerivation records how a worker derived an interesting value from the
+// corpus entry it was given. To derive it again, the values in Parent are
+// spliced with those in Splice, if it's set, then mutated Mutations times by
+// a mutator with the same settings whose random number generator was
+// restored to RandState and RandInc. See workerClient.fuzz, which does the
+// same to learn the value.
+type entryDerivation struct {
+	Parent    string
+	Splice    string `json:",omitempty"`
+	RandState uint64
+	RandInc   uint64
+	Mutations int64
+}
+
+// entryMetaPath returns the path of the metadata file for the corpus entry
+// at entryPath.
+func entryMetaPath(entryPath string) string {
+	return filepath.Join(filepath.Dir(entryPath), metaDir, filepath.Base(entryPath)+".json")
+}
+
+// crasherMetaPath returns the path of the metadata file in the cache
+// directory cacheDir for the crasher at crasherPath.
+func crasherMetaPath(cacheDir, crasherPath string) string {
+	return filepath.Join(cacheDir, metaDir, crasherMetaDir, filepath.Base(crasherPath)+".json")
+}
+
+// writeEntryMeta writes metadata for the corpus entry at entryPath, replacing
+// any metadata written earlier.
+func writeEntryMeta(entryPath string, meta entryMeta) error {
+	return writeMetaFile(entryMetaPath(entryPath), meta)
+}
+
+// readEntryMeta reads metadata for the corpus entry at entryPath. If no
+// metadata was written for the entry, readEntryMeta returns false and
+// no error.
+func readEntryMeta(entryPath string) (meta entryMeta, ok bool, err error) {
+	return readMetaFile(entryMetaPath(entryPath))
+}
+
+// writeMetaFile writes meta as JSON to the file at path.
+func writeMetaFile(path string, meta entryMeta) error {
+	data, err := json.MarshalIndent(meta, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, append(data, '\n'), 0666); err != nil {
+		os.Remove(path) // remove partially written file
+		return err
+	}
+	return nil
+}
+
+// readMetaFile reads metadata written by writeMetaFile. If there's no file
+// at path, readMetaFile returns false and no error.
+func readMetaFile(path string) (meta entryMeta, ok bool, err error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return entryMeta{}, false, nil
+	} else if err != nil {
+		return entryMeta{}, false, err
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return entryMeta{}, false, err
+	}
+	return meta, true, nil
+}
+
+// ListCrashers returns the crashers that were written to the seed corpus of
+// the fuzz target funcName in the package in pkgDir, that is, to
+// testdata/fuzz/<funcName>, with their values decoded. That's where crashers
+// are written, so every entry there is returned, including those written by
+// earlier versions of go test. The metadata recorded with crashers, like
+// their error messages, is kept in the fuzzing cache, not with the entries.
+// Any crasher that can't be read or decoded is reported in a
+// MalformedCorpusError, which is returned along with the other crashers.
+func ListCrashers(pkgDir, funcName string) ([]CorpusEntry, error) {
+	crashers, err := readCrashers(filepath.Join(pkgDir, "testdata", "fuzz", funcName), "")
+	var entries []CorpusEntry
+	for _, c := range crashers {
+		entries = append(entries, CorpusEntry{Path: c.Path, Values: c.Values})
+	}
+	return entries, err
+}
+
+// readCrashers is like ListCrashers, but it reads the crashers in the corpus
+// directory dir, along with any metadata recorded for them in the cache
+// directory cacheDir, if it's set.
+func readCrashers(dir, cacheDir string) ([]Crasher, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var crashers []Crasher
+	var errs []error
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		filename := filepath.Join(dir, file.Name())
+		var meta entryMeta
+		if cacheDir != "" {
+			meta, _, err = readMetaFile(crasherMetaPath(cacheDir, filename))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%q: reading metadata: %v", filename, err))
+				continue
+			}
+		}
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%q: %v", filename, err))
+			continue
+		}
+		vals, err := unmarshalCorpusFile(data)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%q: unmarshal: %v", filename, err))
+			continue
+		}
+		sig := meta.Signature
+		if sig == "" && meta.Err != "" {
+			// Written before signatures were recorded.
+			sig = crashSignature(meta.Err)
+		}
+		crashers = append(crashers, Crasher{
+			Path:          filename,
+			Err:           meta.Err,
+			Values:        vals,
+			Found:         meta.Found,
+			ReproduceRuns: meta.ReproduceRuns,
+			Reproduced:    meta.Reproduced,
+			Signature:     sig,
+		})
+	}
+	if len(errs) > 0 {
+		return crashers, &MalformedCorpusError{errs: errs}
+	}
+	return crashers, nil
+}
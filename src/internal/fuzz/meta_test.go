@@ -0,0 +1,101 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEntryMeta(t *testing.T) {
+	dir := t.TempDir()
+	entry := CorpusEntry{Data: marshalCorpusFile([]byte("crash"))}
+	if err := writeToCorpus(&entry, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, err := readEntryMeta(entry.Path); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatal("found metadata before it was written")
+	}
+
+	want := entryMeta{
+		Err:         "boom",
+		Env:         []string{"GOMAXPROCS=2"},
+		NewCoverage: []int{3, 7},
+		Found:       time.Date(2021, time.June, 1, 12, 30, 0, 0, time.UTC),
+	}
+	if err := writeEntryMeta(entry.Path, want); err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := readEntryMeta(entry.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("got metadata %+v, %t; want %+v, true", got, ok, want)
+	}
+
+	// Metadata must not be read back as part of the corpus.
+	types := []reflect.Type{reflect.TypeOf([]byte(nil))}
+	entries, err := ReadCorpus(dir, types)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Path != entry.Path {
+		t.Errorf("ReadCorpus returned %v; want only %s", entries, entry.Path)
+	}
+}
+
+func TestListCrashers(t *testing.T) {
+	pkgDir, cacheDir := t.TempDir(), t.TempDir()
+	dir := filepath.Join(pkgDir, "testdata", "fuzz", "FuzzX")
+	crash := CorpusEntry{Data: marshalCorpusFile([]byte("crash"))}
+	if err := writeToCorpus(&crash, dir); err != nil {
+		t.Fatal(err)
+	}
+	found := time.Date(2021, time.June, 1, 12, 30, 0, 0, time.UTC)
+	meta := entryMeta{Err: "boom", Found: found, Signature: "sig"}
+	if err := writeCrasherFiles(crash, meta, cacheDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, metaDir)); !os.IsNotExist(err) {
+		t.Errorf("metadata written to the seed corpus: %v", err)
+	}
+
+	entries, err := ListCrashers(pkgDir, "FuzzX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantEntries := []CorpusEntry{{Path: crash.Path, Values: []interface{}{[]byte("crash")}}}
+	if !reflect.DeepEqual(entries, wantEntries) {
+		t.Errorf("ListCrashers returned %+v; want %+v", entries, wantEntries)
+	}
+
+	got, err := readCrashers(dir, cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Crasher{{Path: crash.Path, Values: []interface{}{[]byte("crash")}, Err: "boom", Found: found, Signature: "sig"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readCrashers returned %+v; want %+v", got, want)
+	}
+
+	// A crasher that can't be read is reported without dropping the others.
+	if err := os.Symlink(filepath.Join(dir, "missing"), filepath.Join(dir, "dangling")); err != nil {
+		t.Skipf("can't create symlink: %v", err)
+	}
+	entries, err = ListCrashers(pkgDir, "FuzzX")
+	if _, ok := err.(*MalformedCorpusError); !ok {
+		t.Errorf("ListCrashers returned error %v; want a MalformedCorpusError", err)
+	}
+	if !reflect.DeepEqual(entries, wantEntries) {
+		t.Errorf("ListCrashers returned %+v; want %+v", entries, wantEntries)
+	}
+}
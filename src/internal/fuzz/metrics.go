@@ -0,0 +1,64 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// writeMetrics writes the coordinator's counters to opts.MetricsFile in the
+// Prometheus text exposition format. The file is replaced atomically, so a
+// collector reading it never sees a partial write.
+func (c *coordinator) writeMetrics() error {
+	now := time.Now()
+	var rate float64
+	if secs := now.Sub(c.timeLastMetrics).Seconds(); !c.timeLastMetrics.IsZero() && secs > 0 {
+		rate = float64(c.count-c.countLastMetrics) / secs
+	}
+	c.countLastMetrics = c.count
+	c.timeLastMetrics = now
+
+	coverageBits := 0
+	if c.coverageMask != nil {
+		coverageBits = countBits(c.coverageMask)
+	}
+	var buf bytes.Buffer
+	for _, m := range []struct {
+		name, typ, help string
+		value           interface{}
+	}{
+		{"execs_total", "counter", "Calls to the fuzz function.", c.count},
+		{"execs_per_second", "gauge", "Calls to the fuzz function per second since the metrics were last written.", rate},
+		{"coverage_bits", "gauge", "Coverage bits set by the corpus.", coverageBits},
+		{"corpus_size", "gauge", "Entries in the corpus, including the seed corpus.", len(c.corpus.entries)},
+		{"crashers_total", "counter", "Crashers recorded.", len(c.crashers)},
+		{"worker_restarts_total", "counter", "Worker process restarts.", atomic.LoadInt64(&c.workerRestarts)},
+	} {
+		name := "go_fuzz_" + m.name
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, m.help, name, m.typ, name, m.value)
+	}
+
+	path := c.opts.MetricsFile
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
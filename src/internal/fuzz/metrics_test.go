@@ -0,0 +1,40 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fuzz.prom")
+	c := &coordinator{
+		opts:           CoordinateFuzzingOpts{MetricsFile: path},
+		count:          42,
+		corpus:         corpus{entries: make([]CorpusEntry, 3)},
+		crashers:       []Crasher{{Path: "crasher"}},
+		workerRestarts: 2,
+	}
+	if err := c.writeMetrics(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE go_fuzz_execs_total counter\ngo_fuzz_execs_total 42\n",
+		"\ngo_fuzz_corpus_size 3\n",
+		"\ngo_fuzz_crashers_total 1\n",
+		"\ngo_fuzz_worker_restarts_total 2\n",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, data)
+		}
+	}
+}
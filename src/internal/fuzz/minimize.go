@@ -18,7 +18,164 @@ func isMinimizable(t reflect.Type) bool {
 	return false
 }
 
-func minimizeBytes(v []byte, try func(interface{}) bool, shouldStop func() bool) {
+// minimizeValues applies a series of minimizing transformations to each value
+// in vals in turn. For each candidate, it replaces the value in vals and calls
+// try, which returns whether vals is still interesting for the same reason as
+// the original values. If not, the previous value is restored. minimizeValues
+// returns early once shouldStop returns true.
+//
+// Before minimizing any value, minimizeValues tries replacing each value with
+// the zero value of its type, so values that don't matter for the input to be
+// interesting are cleared in one step, rather than shrunk gradually.
+//
+// []byte and string values aren't shortened below minLen bytes.
+func minimizeValues(vals []interface{}, minLen int, try func() bool, shouldStop func() bool) {
+	var valI int
+	// tryMinimized calls try with candidate replacing the value at index valI.
+	tryMinimized := func(candidate interface{}) bool {
+		prev := vals[valI]
+		// Set vals[valI] to the candidate after it has been
+		// properly cast. We know that candidate must be of
+		// the same type as prev, so use that as a reference.
+		switch c := candidate.(type) {
+		case float64:
+			switch prev.(type) {
+			case float32:
+				vals[valI] = float32(c)
+			case float64:
+				vals[valI] = c
+			default:
+				panic("impossible")
+			}
+		case uint:
+			switch prev.(type) {
+			case uint:
+				vals[valI] = c
+			case uint8:
+				vals[valI] = uint8(c)
+			case uint16:
+				vals[valI] = uint16(c)
+			case uint32:
+				vals[valI] = uint32(c)
+			case uint64:
+				vals[valI] = uint64(c)
+			case int:
+				vals[valI] = int(c)
+			case int8:
+				vals[valI] = int8(c)
+			case int16:
+				vals[valI] = int16(c)
+			case int32:
+				vals[valI] = int32(c)
+			case int64:
+				vals[valI] = int64(c)
+			default:
+				panic("impossible")
+			}
+		case []byte:
+			switch prev.(type) {
+			case []byte:
+				vals[valI] = c
+			case string:
+				vals[valI] = string(c)
+			default:
+				panic("impossible")
+			}
+		default:
+			// A candidate that already has the type of prev, like its
+			// zero value, is used as is.
+			if reflect.TypeOf(c) != reflect.TypeOf(prev) {
+				panic("impossible")
+			}
+			vals[valI] = c
+		}
+		if try() {
+			return true
+		}
+		vals[valI] = prev
+		return false
+	}
+
+	for valI = range vals {
+		if shouldStop() {
+			return
+		}
+		switch vals[valI].(type) {
+		case []byte, string:
+			if minLen > 0 {
+				continue
+			}
+		}
+		if !isZeroValue(vals[valI]) {
+			tryMinimized(zeroValue(reflect.TypeOf(vals[valI])))
+		}
+	}
+
+	for valI = range vals {
+		if shouldStop() {
+			return
+		}
+		switch v := vals[valI].(type) {
+		case bool:
+			continue // can't minimize
+		case float32:
+			minimizeFloat(float64(v), tryMinimized, shouldStop)
+		case float64:
+			minimizeFloat(v, tryMinimized, shouldStop)
+		case uint:
+			minimizeInteger(v, tryMinimized, shouldStop)
+		case uint8:
+			minimizeInteger(uint(v), tryMinimized, shouldStop)
+		case uint16:
+			minimizeInteger(uint(v), tryMinimized, shouldStop)
+		case uint32:
+			minimizeInteger(uint(v), tryMinimized, shouldStop)
+		case uint64:
+			if uint64(uint(v)) != v {
+				// Skip minimizing a uint64 on 32 bit platforms, since we'll truncate the
+				// value when casting
+				continue
+			}
+			minimizeInteger(uint(v), tryMinimized, shouldStop)
+		case int:
+			minimizeInteger(uint(v), tryMinimized, shouldStop)
+		case int8:
+			minimizeInteger(uint(v), tryMinimized, shouldStop)
+		case int16:
+			minimizeInteger(uint(v), tryMinimized, shouldStop)
+		case int32:
+			minimizeInteger(uint(v), tryMinimized, shouldStop)
+		case int64:
+			if int64(int(v)) != v {
+				// Skip minimizing a int64 on 32 bit platforms, since we'll truncate the
+				// value when casting
+				continue
+			}
+			minimizeInteger(uint(v), tryMinimized, shouldStop)
+		case string:
+			minimizeBytes([]byte(v), minLen, tryMinimized, shouldStop)
+			if !shouldStop() {
+				normalizeString([]byte(vals[valI].(string)), tryMinimized, shouldStop)
+			}
+		case []byte:
+			minimizeBytes(v, minLen, tryMinimized, shouldStop)
+		default:
+			panic("unreachable")
+		}
+	}
+}
+
+// isZeroValue reports whether v is the zero value of its type. An empty
+// []byte counts as zero whether or not it's nil.
+func isZeroValue(v interface{}) bool {
+	if b, ok := v.([]byte); ok {
+		return len(b) == 0
+	}
+	return v == zeroValue(reflect.TypeOf(v))
+}
+
+// minimizeBytes tries to shorten v, without making it shorter than minLen.
+func minimizeBytes(v []byte, minLen int, try func(interface{}) bool, shouldStop func() bool) {
 	tmp := make([]byte, len(v))
 	// If minimization was successful at any point during minimizeBytes,
 	// then the vals slice in (*workerServer).minimizeInput will point to
@@ -29,7 +186,7 @@ func minimizeBytes(v []byte, try func(interface{}) bool, shouldStop func() bool)
 
 	// First, try to cut the tail.
 	for n := 1024; n != 0; n /= 2 {
-		for len(v) > n {
+		for len(v) > n && len(v)-n >= minLen {
 			if shouldStop() {
 				return
 			}
@@ -43,7 +200,7 @@ func minimizeBytes(v []byte, try func(interface{}) bool, shouldStop func() bool)
 	}
 
 	// Then, try to remove each individual byte.
-	for i := 0; i < len(v)-1; i++ {
+	for i := 0; i < len(v)-1 && len(v) > minLen; i++ {
 		if shouldStop() {
 			return
 		}
@@ -64,7 +221,7 @@ func minimizeBytes(v []byte, try func(interface{}) bool, shouldStop func() bool)
 	// Then, try to remove each possible subset of bytes.
 	for i := 0; i < len(v)-1; i++ {
 		copy(tmp, v[:i])
-		for j := len(v); j > i+1; j-- {
+		for j := min(len(v), len(v)+i-minLen); j > i+1; j-- {
 			if shouldStop() {
 				return
 			}
@@ -76,7 +233,32 @@ func minimizeBytes(v []byte, try func(interface{}) bool, shouldStop func() bool)
 			// Update v and reset the loop with the new length.
 			copy(v[i:], v[j:])
 			v = v[:len(candidate)]
-			j = len(v)
+			j = min(len(v), len(v)+i-minLen)
+		}
+	}
+}
+
+// normalizeString tries to make a string easier to read by lowercasing ASCII
+// letters and replacing ASCII control characters other than whitespace with
+// spaces, one byte at a time, keeping each change that try accepts. It must
+// only be used for strings: every byte of a []byte may be significant.
+func normalizeString(v []byte, try func(interface{}) bool, shouldStop func() bool) {
+	for i, b := range v {
+		var n byte
+		switch {
+		case 'A' <= b && b <= 'Z':
+			n = b + 'a' - 'A'
+		case b < ' ' && b != '\t' && b != '\n' && b != '\r', b == 0x7f:
+			n = ' '
+		default:
+			continue
+		}
+		if shouldStop() {
+			return
+		}
+		v[i] = n
+		if !try(v) {
+			v[i] = b
 		}
 	}
 }
@@ -12,7 +12,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"hash/maphash"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -90,6 +92,39 @@ func TestMinimizeInput(t *testing.T) {
 			input:    []interface{}{"001010001000000000000000000"},
 			expected: []interface{}{"111"},
 		},
+		{
+			name: "string_case",
+			fn: func(e CorpusEntry) error {
+				if s := e.Values[0].(string); strings.Contains(strings.ToLower(s), "crash") {
+					return fmt.Errorf("bad %v", e.Values[0])
+				}
+				return nil
+			},
+			input:    []interface{}{"CRASH\x01\x02"},
+			expected: []interface{}{"crash"},
+		},
+		{
+			name: "string_control",
+			fn: func(e CorpusEntry) error {
+				if s := e.Values[0].(string); len(s) == 2 && s[0] == 'x' {
+					return fmt.Errorf("bad %v", e.Values[0])
+				}
+				return nil
+			},
+			input:    []interface{}{"x\x00"},
+			expected: []interface{}{"x "},
+		},
+		{
+			name: "bytes_case",
+			fn: func(e CorpusEntry) error {
+				if b := e.Values[0].([]byte); bytes.EqualFold(b, []byte("crash")) {
+					return fmt.Errorf("bad %v", e.Values[0])
+				}
+				return nil
+			},
+			input:    []interface{}{[]byte("CRASH")},
+			expected: []interface{}{[]byte("CRASH")},
+		},
 		{
 			name: "int",
 			fn: func(e CorpusEntry) error {
@@ -194,6 +229,18 @@ func TestMinimizeInput(t *testing.T) {
 				return fmt.Errorf("bad %v", e.Values[0])
 			},
 			input:    []interface{}{float32(1.23456789)},
+			expected: []interface{}{float32(0)},
+		},
+		{
+			// Zero isn't interesting, so digits are dropped instead.
+			name: "float32_nonzero",
+			fn: func(e CorpusEntry) error {
+				if i := e.Values[0].(float32); i == 1.23 || i == 0 {
+					return nil
+				}
+				return fmt.Errorf("bad %v", e.Values[0])
+			},
+			input:    []interface{}{float32(1.23456789)},
 			expected: []interface{}{float32(1.2)},
 		},
 		{
@@ -205,8 +252,31 @@ func TestMinimizeInput(t *testing.T) {
 				return fmt.Errorf("bad %v", e.Values[0])
 			},
 			input:    []interface{}{float64(1.23456789)},
+			expected: []interface{}{float64(0)},
+		},
+		{
+			// Zero isn't interesting, so digits are dropped instead.
+			name: "float64_nonzero",
+			fn: func(e CorpusEntry) error {
+				if i := e.Values[0].(float64); i == 1.23 || i == 0 {
+					return nil
+				}
+				return fmt.Errorf("bad %v", e.Values[0])
+			},
+			input:    []interface{}{float64(1.23456789)},
 			expected: []interface{}{float64(1.2)},
 		},
+		{
+			name: "zero_unused_values",
+			fn: func(e CorpusEntry) error {
+				if bytes.IndexByte(e.Values[0].([]byte), 'b') >= 0 {
+					return fmt.Errorf("bad %v", e.Values[0])
+				}
+				return nil
+			},
+			input:    []interface{}{[]byte("abc"), int(12345), "unused", true, float64(1.5)},
+			expected: []interface{}{[]byte("b"), int(0), "", false, float64(0)},
+		},
 	}
 
 	// If we are on a 64 bit platform add int64 and uint64 tests
@@ -245,7 +315,7 @@ func TestMinimizeInput(t *testing.T) {
 			}
 			count := int64(0)
 			vals := tc.input
-			success, err := ws.minimizeInput(context.Background(), vals, &count, 0, nil)
+			success, _, err := ws.minimizeInput(context.Background(), vals, &count, 0, nil, nil)
 			if !success {
 				t.Errorf("minimizeInput did not succeed")
 			}
@@ -273,7 +343,7 @@ func TestMinimizeInputCoverageError(t *testing.T) {
 	keepCoverage := make([]byte, len(coverageSnapshot))
 	count := int64(0)
 	vals := []interface{}{[]byte(nil)}
-	success, err := ws.minimizeInput(context.Background(), vals, &count, 0, keepCoverage)
+	success, _, err := ws.minimizeInput(context.Background(), vals, &count, 0, keepCoverage, nil)
 	if success {
 		t.Error("unexpected success")
 	}
@@ -284,3 +354,200 @@ func TestMinimizeInputCoverageError(t *testing.T) {
 		t.Errorf("count: got %d, want 1", count)
 	}
 }
+
+// TestMinimizeInputCheckpoint checks that when minimization stops early, the
+// last smaller value that was verified to cause an error is in shared memory.
+func TestMinimizeInputCheckpoint(t *testing.T) {
+	fn := func(e CorpusEntry) error {
+		if bytes.Count(e.Values[0].([]byte), []byte{1}) == 3 {
+			return fmt.Errorf("bad %v", e.Values[0])
+		}
+		return nil
+	}
+	ws := &workerServer{fuzzFn: fn}
+	mem, err := sharedMemTempFile("", 1<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mem.Close()
+	orig := []byte{0, 0, 1, 0, 1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	writeToMem([]interface{}{orig}, mem)
+
+	vals := []interface{}{append([]byte(nil), orig...)}
+	count := int64(0)
+	limit := int64(3)
+	if _, _, err := ws.minimizeInput(context.Background(), vals, &count, limit, nil, mem); err == nil {
+		t.Fatal("minimizeInput didn't provide an error")
+	}
+	checkpoint, err := unmarshalCorpusFile(mem.valueCopy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := checkpoint[0].([]byte); len(got) >= len(orig) {
+		t.Errorf("checkpoint was not minimized: %v", got)
+	}
+	if err := fn(CorpusEntry{Values: checkpoint}); err == nil {
+		t.Errorf("checkpoint %v does not cause an error", checkpoint[0])
+	}
+}
+
+// TestMinimizeInputConverges checks that minimization stops once a pass over
+// the values makes no progress, and that no candidate is tested twice.
+func TestMinimizeInputConverges(t *testing.T) {
+	calls := make(map[string]int)
+	ws := &workerServer{fuzzFn: func(e CorpusEntry) error {
+		b := e.Values[0].([]byte)
+		calls[fmt.Sprintf("%v %v", b, e.Values[1])]++
+		if bytes.Count(b, []byte{1}) >= 2 && e.Values[1].(int) > 10 {
+			return fmt.Errorf("bad")
+		}
+		return nil
+	}}
+	vals := []interface{}{[]byte{0, 1, 0, 1, 0, 1, 0}, 12345}
+	count := int64(0)
+	success, stopReason, err := ws.minimizeInput(context.Background(), vals, &count, 0, nil, nil)
+	if !success || err == nil {
+		t.Fatalf("minimizeInput did not succeed: %v", err)
+	}
+	if !strings.HasPrefix(stopReason, "converged after ") {
+		t.Errorf("got stop reason %q; want convergence", stopReason)
+	}
+	for input, n := range calls {
+		if n > 1 {
+			t.Errorf("input %s tested %d times", input, n)
+		}
+	}
+	if want := []interface{}{[]byte{1, 1}, 12}; !reflect.DeepEqual(vals, want) {
+		t.Errorf("got %v; want %v", vals, want)
+	}
+}
+
+func TestMinimizeInputMinLen(t *testing.T) {
+	ws := &workerServer{
+		fuzzFn: func(e CorpusEntry) error {
+			if bytes.IndexByte(e.Values[0].([]byte), 1) >= 0 {
+				return fmt.Errorf("bad")
+			}
+			return nil
+		},
+		m: &mutator{minLen: 4},
+	}
+	vals := []interface{}{[]byte{0, 0, 0, 1, 0, 0, 0, 0, 0}}
+	count := int64(0)
+	if success, _, err := ws.minimizeInput(context.Background(), vals, &count, 0, nil, nil); !success || err == nil {
+		t.Fatalf("minimizeInput did not succeed: %v", err)
+	}
+	if got := vals[0].([]byte); len(got) != 4 || bytes.IndexByte(got, 1) < 0 {
+		t.Errorf("got %v; want 4 bytes including a 1", got)
+	}
+}
+
+func TestMinimizeInputCoverageSubset(t *testing.T) {
+	registerTestFeedback.Do(func() {
+		RegisterFeedbackSource("test", theTestFeedback)
+	})
+	if err := setFeedbackSource("test"); err != nil {
+		t.Fatal(err)
+	}
+	defer setFeedbackSource("")
+
+	// The first counter is the new coverage to keep, reached by any value
+	// with an 'a'. Values of three bytes or more reach the second, and a
+	// lone 'a' reaches the third, which the original value doesn't.
+	fn := func(e CorpusEntry) error {
+		v := e.Values[0].([]byte)
+		for i := range coverageSnapshot {
+			coverageSnapshot[i] = 0
+		}
+		if bytes.IndexByte(v, 'a') >= 0 {
+			coverageSnapshot[0] = 1
+		}
+		if len(v) >= 3 {
+			coverageSnapshot[1] = 1
+		}
+		if string(v) == "a" {
+			coverageSnapshot[2] = 1
+		}
+		return nil
+	}
+	keepCoverage := []byte{1, 0, 0, 0}
+	for _, tc := range []struct {
+		subset  bool
+		wantLen int
+	}{
+		{subset: false, wantLen: 1},
+		{subset: true, wantLen: 2},
+	} {
+		ws := &workerServer{fuzzFn: fn, coverageSubset: tc.subset}
+		vals := []interface{}{[]byte("bbabb")}
+		count := int64(0)
+		if success, _, err := ws.minimizeInput(context.Background(), vals, &count, 0, keepCoverage, nil); !success || err != nil {
+			t.Fatalf("coverageSubset %v: minimizeInput did not succeed: %v", tc.subset, err)
+		}
+		if got := vals[0].([]byte); len(got) != tc.wantLen || bytes.IndexByte(got, 'a') < 0 {
+			t.Errorf("coverageSubset %v: got %q; want %d bytes including an 'a'", tc.subset, got, tc.wantLen)
+		}
+	}
+}
+
+func TestMinimizeInputFewestCoverageBits(t *testing.T) {
+	registerTestFeedback.Do(func() {
+		RegisterFeedbackSource("test", theTestFeedback)
+	})
+	if err := setFeedbackSource("test"); err != nil {
+		t.Fatal(err)
+	}
+	defer setFeedbackSource("")
+
+	// The first counter is the new coverage to keep, reached by any value
+	// with an 'a'. Values shorter than three bytes also reach the second,
+	// so they hit more bits in total than the original value.
+	fn := func(e CorpusEntry) error {
+		v := e.Values[0].([]byte)
+		for i := range coverageSnapshot {
+			coverageSnapshot[i] = 0
+		}
+		if bytes.IndexByte(v, 'a') >= 0 {
+			coverageSnapshot[0] = 1
+		}
+		if len(v) < 3 {
+			coverageSnapshot[1] = 1
+		}
+		return nil
+	}
+	keepCoverage := []byte{1, 0, 0, 0}
+	for _, tc := range []struct {
+		fewest  bool
+		wantLen int
+	}{
+		{fewest: false, wantLen: 1},
+		{fewest: true, wantLen: 3},
+	} {
+		ws := &workerServer{fuzzFn: fn, fewestCoverageBits: tc.fewest}
+		vals := []interface{}{[]byte("bbabb")}
+		count := int64(0)
+		if success, _, err := ws.minimizeInput(context.Background(), vals, &count, 0, keepCoverage, nil); !success || err != nil {
+			t.Fatalf("fewestCoverageBits %v: minimizeInput did not succeed: %v", tc.fewest, err)
+		}
+		if got := vals[0].([]byte); len(got) != tc.wantLen || bytes.IndexByte(got, 'a') < 0 {
+			t.Errorf("fewestCoverageBits %v: got %q; want %d bytes including an 'a'", tc.fewest, got, tc.wantLen)
+		}
+	}
+}
+
+func TestValuesHash(t *testing.T) {
+	seed := maphash.MakeSeed()
+	h := valuesHash(seed, []interface{}{[]byte("a"), "bc", 1, 2.5})
+	if got := valuesHash(seed, []interface{}{[]byte("a"), "bc", 1, 2.5}); got != h {
+		t.Error("equal values have different hashes")
+	}
+	for _, vals := range [][]interface{}{
+		{[]byte("ab"), "c", 1, 2.5},
+		{[]byte("a"), "bc", 2, 2.5},
+		{[]byte("a"), "bc", 1, float32(2.5)},
+	} {
+		if valuesHash(seed, vals) == h {
+			t.Errorf("%v has the same hash as different values", vals)
+		}
+	}
+}
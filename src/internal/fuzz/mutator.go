@@ -9,12 +9,51 @@ import (
 	"fmt"
 	"math"
 	"reflect"
+	"sort"
 	"unsafe"
 )
 
 type mutator struct {
 	r       mutatorRand
 	scratch []byte // scratch slice to avoid additional allocations
+
+	// byteMutators and cumWeights are set by setWeights. If byteMutators is
+	// not nil, mutateBytes chooses byteMutators[i] with probability
+	// proportional to its weight, cumWeights[i] - cumWeights[i-1].
+	// byteMutatorOps[i] is the operator number of byteMutators[i].
+	byteMutators   []byteSliceMutator
+	byteMutatorOps []int
+	cumWeights     []int
+
+	// opCounts, if not nil, is the number of times each mutation operator
+	// was applied, indexed by operator. See countOp.
+	opCounts []int64
+
+	// maxLen, if positive, is the largest length a []byte or string value may
+	// grow to when mutated. Values that are already longer may only shrink.
+	// See CoordinateFuzzingOpts.MaxMutatedLen.
+	maxLen int
+
+	// minLen, if positive, is the shortest length a []byte or string value
+	// may shrink to when mutated. Values that are already shorter may only
+	// grow. See CoordinateFuzzingOpts.MinMutatedLen.
+	minLen int
+
+	// spliceVals are the []byte and string values of the corpus entry being
+	// spliced with, if any. They're set by spliceWith.
+	spliceVals [][]byte
+
+	// dictionary holds tokens that dictionary mutations copy into values.
+	// See CoordinateFuzzingOpts.Dictionary.
+	dictionary [][]byte
+
+	// schemas holds the layout of []byte values, by index. See
+	// CoordinateFuzzingOpts.InputSchemas.
+	schemas map[int]Schema
+
+	// saved holds a copy of the values passed to mutateWithin, from before
+	// they were mutated, so an oversized mutation can be undone.
+	saved []interface{}
 }
 
 func newMutator() *mutator {
@@ -88,6 +127,7 @@ func (m *mutator) mutate(vals []interface{}, maxBytes int) {
 	case float64:
 		vals[i] = m.mutateFloat(v, math.MaxFloat64)
 	case bool:
+		m.countOp(opBool)
 		if m.rand(2) == 1 {
 			vals[i] = !v // 50% chance of flipping the bool
 		}
@@ -99,10 +139,11 @@ func (m *mutator) mutate(vals []interface{}, maxBytes int) {
 		if len(v) > maxPerVal {
 			panic(fmt.Sprintf("cannot mutate bytes of length %d", len(v)))
 		}
-		if cap(m.scratch) < maxPerVal {
-			m.scratch = append(make([]byte, 0, maxPerVal), v...)
+		limit := m.valueCap(len(v), maxPerVal)
+		if cap(m.scratch) < limit {
+			m.scratch = append(make([]byte, 0, limit), v...)
 		} else {
-			m.scratch = m.scratch[:len(v)]
+			m.scratch = m.scratch[:len(v):limit]
 			copy(m.scratch, v)
 		}
 		m.mutateBytes(&m.scratch)
@@ -111,20 +152,127 @@ func (m *mutator) mutate(vals []interface{}, maxBytes int) {
 		if len(v) > maxPerVal {
 			panic(fmt.Sprintf("cannot mutate bytes of length %d", len(v)))
 		}
-		if cap(m.scratch) < maxPerVal {
-			m.scratch = append(make([]byte, 0, maxPerVal), v...)
+		limit := m.valueCap(len(v), maxPerVal)
+		if cap(m.scratch) < limit {
+			m.scratch = append(make([]byte, 0, limit), v...)
 		} else {
-			m.scratch = m.scratch[:len(v)]
+			m.scratch = m.scratch[:len(v):limit]
 			copy(m.scratch, v)
 		}
-		m.mutateBytes(&m.scratch)
+		if s, ok := m.schemas[i]; ok && m.mutateSchema(&m.scratch, s) {
+			m.countOp(opSchema)
+		} else {
+			m.mutateBytes(&m.scratch)
+		}
 		vals[i] = m.scratch
 	default:
 		panic(fmt.Sprintf("type not supported for mutating: %T", vals[i]))
 	}
 }
 
+// mutateWithin mutates vals like mutate. If maxSize is positive and the
+// []byte and string values in vals are longer than maxSize in total
+// afterward, it restores the values from before the mutation and returns
+// false, so the next mutation starts from values that fit. The worker and
+// the coordinator both call mutateWithin when fuzzing with a maximum input
+// size, so they produce the same values from the same PRNG state.
+func (m *mutator) mutateWithin(vals []interface{}, maxBytes, maxSize int) bool {
+	if maxSize <= 0 {
+		m.mutate(vals, maxBytes)
+		return true
+	}
+	if len(m.saved) != len(vals) {
+		m.saved = make([]interface{}, len(vals))
+	}
+	for i, v := range vals {
+		if b, ok := v.([]byte); ok {
+			// mutate may overwrite b in place, so keep a copy, reusing
+			// the buffer from the last call.
+			buf, _ := m.saved[i].([]byte)
+			if buf == nil {
+				buf = make([]byte, 0, len(b))
+			}
+			v = append(buf[:0], b...)
+		}
+		m.saved[i] = v
+	}
+	m.mutate(vals, maxBytes)
+	if valuesSize(vals) <= maxSize {
+		return true
+	}
+	copy(vals, m.saved)
+	return false
+}
+
+// valueCap returns the capacity of the scratch slice used to mutate a []byte
+// or string value of length n, which limits how much the value may grow.
+// It's maxPerVal, or m.maxLen if that's smaller, but never less than n.
+func (m *mutator) valueCap(n, maxPerVal int) int {
+	if m.maxLen <= 0 || m.maxLen >= maxPerVal {
+		return maxPerVal
+	}
+	if n > m.maxLen {
+		return n
+	}
+	return m.maxLen
+}
+
+// splice replaces one []byte or string value in vals with the beginning of
+// that value followed by the end of the value at the same position in other.
+// The value and both cut points are chosen with the PRNG, so the result only
+// depends on its state. splice does nothing if vals and other have no []byte
+// or string values of the same type in the same position.
+func (m *mutator) splice(vals, other []interface{}, maxBytes int) {
+	if len(vals) != len(other) {
+		return
+	}
+	maxPerVal := maxBytes/len(vals) - 100
+	if m.maxLen > 0 && m.maxLen < maxPerVal {
+		maxPerVal = m.maxLen
+	}
+	var candidates []int
+	for i := range vals {
+		switch vals[i].(type) {
+		case []byte, string:
+			if reflect.TypeOf(vals[i]) == reflect.TypeOf(other[i]) {
+				candidates = append(candidates, i)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return
+	}
+	i := candidates[m.rand(len(candidates))]
+	var a, b []byte
+	switch v := vals[i].(type) {
+	case []byte:
+		a, b = v, other[i].([]byte)
+	case string:
+		a, b = []byte(v), []byte(other[i].(string))
+	}
+	head := a[:m.rand(len(a)+1)]
+	tail := b[m.rand(len(b)+1):]
+	if len(head) > maxPerVal {
+		head = head[:maxPerVal]
+	}
+	if len(head)+len(tail) > maxPerVal {
+		tail = tail[:maxPerVal-len(head)]
+	}
+	if len(head)+len(tail) < m.minLen && len(head)+len(tail) < len(a) {
+		return
+	}
+	out := make([]byte, 0, len(head)+len(tail))
+	out = append(append(out, head...), tail...)
+	m.countOp(opSplice)
+	if _, ok := vals[i].(string); ok {
+		vals[i] = string(out)
+	} else {
+		vals[i] = out
+	}
+}
+
 func (m *mutator) mutateInt(v, maxValue int64) int64 {
+	m.countOp(opInt)
 	numIters := 1 + m.r.exp2()
 	var max int64
 	for iter := 0; iter < numIters; iter++ {
@@ -158,6 +306,7 @@ func (m *mutator) mutateInt(v, maxValue int64) int64 {
 }
 
 func (m *mutator) mutateUInt(v, maxValue uint64) uint64 {
+	m.countOp(opUint)
 	numIters := 1 + m.r.exp2()
 	var max uint64
 	for iter := 0; iter < numIters; iter++ {
@@ -192,6 +341,7 @@ func (m *mutator) mutateUInt(v, maxValue uint64) uint64 {
 }
 
 func (m *mutator) mutateFloat(v, maxValue float64) float64 {
+	m.countOp(opFloat)
 	numIters := 1 + m.r.exp2()
 	var max float64
 	for iter := 0; iter < numIters; iter++ {
@@ -266,6 +416,145 @@ var byteSliceMutators = []byteSliceMutator{
 	byteSliceOverwriteConstantBytes,
 	byteSliceShuffleBytes,
 	byteSliceSwapBytes,
+	byteSliceSpliceBytes,
+	byteSliceInsertToken,
+	byteSliceOverwriteToken,
+}
+
+// byteSliceMutatorClasses groups byteSliceMutators by the kind of change they
+// make. The class names are the keys of CoordinateFuzzingOpts.MutatorWeights.
+var byteSliceMutatorClasses = map[string][]byteSliceMutator{
+	"remove": {byteSliceRemoveBytes},
+	"insert": {
+		byteSliceInsertRandomBytes,
+		byteSliceDuplicateBytes,
+		byteSliceInsertConstantBytes,
+	},
+	"overwrite": {
+		byteSliceOverwriteBytes,
+		byteSliceOverwriteConstantBytes,
+		byteSliceSwapByte,
+		byteSliceShuffleBytes,
+		byteSliceSwapBytes,
+	},
+	"bitflip": {byteSliceBitFlip, byteSliceXORByte},
+	"arithmetic": {
+		byteSliceArithmeticUint8,
+		byteSliceArithmeticUint16,
+		byteSliceArithmeticUint32,
+		byteSliceArithmeticUint64,
+	},
+	"interesting": {
+		byteSliceOverwriteInterestingUint8,
+		byteSliceOverwriteInterestingUint16,
+		byteSliceOverwriteInterestingUint32,
+	},
+	"splice":     {byteSliceSpliceBytes},
+	"dictionary": {byteSliceInsertToken, byteSliceOverwriteToken},
+}
+
+// checkMutatorWeights reports an error if weights can't be passed to
+// setWeights.
+func checkMutatorWeights(weights map[string]int) error {
+	total := 0
+	for class, w := range byteSliceMutatorClasses {
+		if v, ok := weights[class]; ok {
+			total += v
+		} else {
+			total += len(w)
+		}
+	}
+	for class, w := range weights {
+		if _, ok := byteSliceMutatorClasses[class]; !ok {
+			return fmt.Errorf("unknown mutator class %q", class)
+		}
+		if w < 0 {
+			return fmt.Errorf("negative weight %d for mutator class %q", w, class)
+		}
+	}
+	if total == 0 {
+		return fmt.Errorf("all mutator classes have weight 0")
+	}
+	return nil
+}
+
+// setWeights makes mutateBytes choose mutations from each class in
+// byteSliceMutatorClasses with the relative weight given in weights. The
+// weight of a class is shared among its mutators. Classes not in weights
+// keep their default weight, which is the number of mutators in the class;
+// with default weights, each mutator is equally likely to be chosen. If
+// weights is empty, setWeights does nothing.
+//
+// Classes are added in a fixed order so that mutators with the same weights
+// and PRNG state make the same choices.
+func (m *mutator) setWeights(weights map[string]int) error {
+	if len(weights) == 0 {
+		return nil
+	}
+	if err := checkMutatorWeights(weights); err != nil {
+		return err
+	}
+	classes := make([]string, 0, len(byteSliceMutatorClasses))
+	for class := range byteSliceMutatorClasses {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	m.byteMutators, m.byteMutatorOps, m.cumWeights = nil, nil, nil
+	total := 0
+	for _, class := range classes {
+		muts := byteSliceMutatorClasses[class]
+		w, ok := weights[class]
+		if !ok {
+			w = len(muts)
+		}
+		for i, mut := range muts {
+			// Split w among the mutators, giving the remainder to the first.
+			mw := w / len(muts)
+			if i == 0 {
+				mw += w % len(muts)
+			}
+			if mw == 0 {
+				continue
+			}
+			total += mw
+			m.byteMutators = append(m.byteMutators, mut)
+			m.byteMutatorOps = append(m.byteMutatorOps, byteSliceMutatorOp(mut))
+			m.cumWeights = append(m.cumWeights, total)
+		}
+	}
+	return nil
+}
+
+// maxWeightedMutatorFailures is the number of times in a row mutateBytes
+// tries mutators chosen by weight that can't be applied before choosing one
+// without weights. With some weights, no mutator that could be chosen may
+// apply to a value, for example, when only "remove" is enabled and the value
+// is empty.
+const maxWeightedMutatorFailures = 100
+
+// chooseByteSliceMutator chooses a mutator, using the weights set by
+// setWeights unless weighted is false. It also returns the mutator's
+// operator number, for countOp.
+func (m *mutator) chooseByteSliceMutator(weighted bool) (byteSliceMutator, int) {
+	if !weighted || m.byteMutators == nil {
+		i := m.rand(len(byteSliceMutators))
+		return byteSliceMutators[i], numValueOps + i
+	}
+	x := m.rand(m.cumWeights[len(m.cumWeights)-1])
+	i := sort.SearchInts(m.cumWeights, x+1)
+	return m.byteMutators[i], m.byteMutatorOps[i]
+}
+
+// byteSliceMutatorOp returns the operator number of mut, one of
+// byteSliceMutators.
+func byteSliceMutatorOp(mut byteSliceMutator) int {
+	p := reflect.ValueOf(mut).Pointer()
+	for i, other := range byteSliceMutators {
+		if reflect.ValueOf(other).Pointer() == p {
+			return numValueOps + i
+		}
+	}
+	panic("unknown byte slice mutator")
 }
 
 func (m *mutator) mutateBytes(ptrB *[]byte) {
@@ -280,13 +569,17 @@ func (m *mutator) mutateBytes(ptrB *[]byte) {
 	}()
 
 	numIters := 1 + m.r.exp2()
+	failures := 0
 	for iter := 0; iter < numIters; iter++ {
-		mut := byteSliceMutators[m.rand(len(byteSliceMutators))]
+		mut, op := m.chooseByteSliceMutator(failures < maxWeightedMutatorFailures)
 		mutated := mut(m, b)
 		if mutated == nil {
+			failures++
 			iter--
 			continue
 		}
+		failures = 0
+		m.countOp(op)
 		b = mutated
 	}
 }
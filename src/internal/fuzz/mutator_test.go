@@ -115,3 +115,204 @@ func TestStringImmutability(t *testing.T) {
 		t.Fatalf("string was mutated: got %x, want %x", []byte(original), originalCopy)
 	}
 }
+
+func TestSplice(t *testing.T) {
+	a := []byte("aaaaaaaaaaaaaaaa")
+	b := []byte("bbbbbbbbbbbbbbbb")
+	splice := func() []byte {
+		m := &mutator{r: &pcgRand{state: 1, inc: 3}}
+		vals := []interface{}{a, 1}
+		m.splice(vals, []interface{}{b, 2}, 1024)
+		if vals[1] != 1 {
+			t.Fatalf("splice modified a value that isn't []byte: %v", vals[1])
+		}
+		return vals[0].([]byte)
+	}
+	got := splice()
+	head := bytes.TrimRight(got, "b")
+	tail := got[len(head):]
+	if !bytes.HasPrefix(a, head) || !bytes.HasSuffix(b, tail) {
+		t.Errorf("got %q; want a prefix of %q followed by a suffix of %q", got, a, b)
+	}
+	if again := splice(); !bytes.Equal(again, got) {
+		t.Errorf("splice with the same PRNG state returned %q, then %q", got, again)
+	}
+	if string(a) != "aaaaaaaaaaaaaaaa" || string(b) != "bbbbbbbbbbbbbbbb" {
+		t.Errorf("splice modified its inputs: %q, %q", a, b)
+	}
+}
+
+func TestMutatorMaxLen(t *testing.T) {
+	m := newMutator()
+	m.maxLen = 16
+	long := bytes.Repeat([]byte("x"), 32)
+	vals := []interface{}{[]byte("abc"), "abc", long}
+	other := []interface{}{long, string(long), long}
+	for i := 0; i < 10000; i++ {
+		if i%10 == 0 {
+			m.splice(vals, other, 1<<20)
+		} else {
+			m.mutate(vals, 1<<20)
+		}
+		if n := len(vals[0].([]byte)); n > m.maxLen {
+			t.Fatalf("[]byte value grew to %d bytes; limit is %d", n, m.maxLen)
+		}
+		if n := len(vals[1].(string)); n > m.maxLen {
+			t.Fatalf("string value grew to %d bytes; limit is %d", n, m.maxLen)
+		}
+		if n := len(vals[2].([]byte)); n > len(long) {
+			t.Fatalf("value longer than the limit grew to %d bytes", n)
+		}
+		vals[0] = append([]byte(nil), vals[0].([]byte)...)
+		vals[2] = append([]byte(nil), vals[2].([]byte)...)
+	}
+}
+
+func TestMutatorMinLen(t *testing.T) {
+	m := newMutator()
+	m.minLen = 8
+	long := bytes.Repeat([]byte("x"), 32)
+	vals := []interface{}{long, string(long), []byte("abc")}
+	other := []interface{}{[]byte("a"), "a", []byte("a")}
+	for i := 0; i < 10000; i++ {
+		if i%10 == 0 {
+			m.splice(vals, other, 1<<20)
+		} else {
+			m.mutate(vals, 1<<20)
+		}
+		if n := len(vals[0].([]byte)); n < m.minLen {
+			t.Fatalf("[]byte value shrank to %d bytes; floor is %d", n, m.minLen)
+		}
+		if n := len(vals[1].(string)); n < m.minLen {
+			t.Fatalf("string value shrank to %d bytes; floor is %d", n, m.minLen)
+		}
+		if n := len(vals[2].([]byte)); n < 3 {
+			t.Fatalf("value shorter than the floor shrank to %d bytes", n)
+		}
+		vals[0] = append([]byte(nil), vals[0].([]byte)...)
+		vals[2] = append([]byte(nil), vals[2].([]byte)...)
+	}
+}
+
+func TestMutatorWeights(t *testing.T) {
+	for _, weights := range []map[string]int{
+		{"unknown": 1},
+		{"remove": -1},
+		{"remove": 0, "insert": 0, "overwrite": 0, "bitflip": 0, "arithmetic": 0, "interesting": 0, "splice": 0, "dictionary": 0},
+	} {
+		if err := newMutator().setWeights(weights); err == nil {
+			t.Errorf("setWeights(%v) succeeded; want error", weights)
+		}
+	}
+
+	// With only "remove" enabled, values shrink until removing bytes is no
+	// longer possible. Then mutateBytes must fall back to other mutators
+	// instead of looping forever.
+	m := &mutator{r: &pcgRand{state: 1, inc: 3}}
+	weights := map[string]int{"remove": 1, "insert": 0, "overwrite": 0, "bitflip": 0, "arithmetic": 0, "interesting": 0}
+	if err := m.setWeights(weights); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 64, 1024)
+	for len(b) > 1 {
+		n := len(b)
+		m.mutateBytes(&b)
+		if len(b) >= n {
+			t.Fatalf("value grew from %d to %d bytes with only removals enabled", n, len(b))
+		}
+	}
+	for i := 0; i < 10; i++ {
+		m.mutateBytes(&b)
+	}
+}
+
+func TestPcgRandSeed(t *testing.T) {
+	r1, r2 := newPcgRandSeed(42, 1), newPcgRandSeed(42, 1)
+	for i := 0; i < 100; i++ {
+		if a, b := r1.uint32(), r2.uint32(); a != b {
+			t.Fatalf("call %d: got %d and %d from the same seed", i, a, b)
+		}
+	}
+}
+
+func TestMutatorOpCounts(t *testing.T) {
+	if len(byteSliceMutatorNames) != len(byteSliceMutators) {
+		t.Fatalf("%d byte slice mutator names for %d mutators", len(byteSliceMutatorNames), len(byteSliceMutators))
+	}
+
+	// With only "remove" enabled, every byte slice mutation that applies is
+	// counted as removeBytes.
+	m := &mutator{r: &pcgRand{state: 1, inc: 3}, opCounts: make([]int64, numMutatorOps())}
+	weights := map[string]int{"remove": 1, "insert": 0, "overwrite": 0, "bitflip": 0, "arithmetic": 0, "interesting": 0}
+	if err := m.setWeights(weights); err != nil {
+		t.Fatal(err)
+	}
+	vals := []interface{}{make([]byte, 1000), 1}
+	for i := 0; i < 10; i++ {
+		m.mutate(vals, 1<<20)
+	}
+	var total int64
+	for op, n := range m.opCounts {
+		switch name := mutatorOpName(op); name {
+		case "removeBytes", "int":
+		default:
+			if n != 0 {
+				t.Errorf("operator %s applied %d times", name, n)
+			}
+		}
+		total += n
+	}
+	if total < 10 {
+		t.Errorf("%d operators applied for 10 mutations", total)
+	}
+}
+
+func TestMutatorSpliceAndDictionaryWeights(t *testing.T) {
+	// With only "dictionary" enabled, values are changed only by copying
+	// tokens into them.
+	m := &mutator{r: &pcgRand{state: 1, inc: 3}, opCounts: make([]int64, numMutatorOps()), dictionary: [][]byte{[]byte("TOKEN")}}
+	only := func(class string) map[string]int {
+		w := map[string]int{}
+		for c := range byteSliceMutatorClasses {
+			w[c] = 0
+		}
+		w[class] = len(byteSliceMutatorClasses[class])
+		return w
+	}
+	if err := m.setWeights(only("dictionary")); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 16, 1<<12)
+	m.mutateBytes(&b)
+	if !bytes.Contains(b, []byte("TOKEN")) {
+		t.Errorf("got %q; want a dictionary token", b)
+	}
+	for op, n := range m.opCounts {
+		switch name := mutatorOpName(op); name {
+		case "insertToken", "overwriteToken":
+		default:
+			if n != 0 {
+				t.Errorf("operator %s applied %d times", name, n)
+			}
+		}
+	}
+
+	// With only "splice" enabled, bytes are copied from the entry being
+	// spliced with.
+	m = &mutator{r: &pcgRand{state: 1, inc: 3}, opCounts: make([]int64, numMutatorOps())}
+	if err := m.setWeights(only("splice")); err != nil {
+		t.Fatal(err)
+	}
+	vals := []interface{}{make([]byte, 16, 64)}
+	spliceWith(m, vals, marshalCorpusFile(bytes.Repeat([]byte("x"), 16)), 1<<20)
+	b = vals[0].([]byte)
+	m.mutateBytes(&b)
+	if !bytes.Contains(b, []byte("x")) {
+		t.Errorf("got %q; want bytes from the spliced entry", b)
+	}
+	for op, n := range m.opCounts {
+		if mutatorOpName(op) == "spliceBytes" && n == 0 {
+			t.Error("spliceBytes was not counted")
+		}
+	}
+}
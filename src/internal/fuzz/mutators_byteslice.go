@@ -6,11 +6,12 @@ package fuzz
 
 // byteSliceRemoveBytes removes a random chunk of bytes from b.
 func byteSliceRemoveBytes(m *mutator, b []byte) []byte {
-	if len(b) <= 1 {
+	if len(b) <= 1 || len(b) <= m.minLen {
 		return nil
 	}
 	pos0 := m.rand(len(b))
-	pos1 := pos0 + m.chooseLen(len(b)-pos0)
+	// Don't remove more than m.minLen allows.
+	pos1 := pos0 + m.chooseLen(min(len(b)-pos0, len(b)-m.minLen))
 	copy(b[pos0:], b[pos1:])
 	b = b[:len(b)-(pos1-pos0)]
 	return b
@@ -285,6 +286,12 @@ func byteSliceSwapBytes(m *mutator, b []byte) []byte {
 		dst = m.rand(len(b))
 	}
 	n := m.chooseLen(len(b) - src - 1)
+	if dst+n > len(b) {
+		// Don't swap in bytes past the end of b: whatever is left there
+		// from earlier mutations would make the result depend on more than
+		// the PRNG state, and inputs couldn't be reconstructed.
+		n = len(b) - dst
+	}
 	// Use the end of the slice as scratch space to avoid doing an
 	// allocation. If the slice is too small abort and try something
 	// else.
@@ -299,3 +306,52 @@ func byteSliceSwapBytes(m *mutator, b []byte) []byte {
 	b = b[:end]
 	return b
 }
+
+// byteSliceSpliceBytes overwrites a chunk of b with a chunk of one of the
+// values of the corpus entry being spliced with.
+func byteSliceSpliceBytes(m *mutator, b []byte) []byte {
+	if len(b) == 0 || len(m.spliceVals) == 0 {
+		return nil
+	}
+	src := m.spliceVals[m.rand(len(m.spliceVals))]
+	if len(src) == 0 {
+		return nil
+	}
+	n := m.chooseLen(min(len(b), len(src)))
+	from := m.rand(len(src) - n + 1)
+	dst := m.rand(len(b) - n + 1)
+	copy(b[dst:], src[from:from+n])
+	return b
+}
+
+// byteSliceInsertToken inserts a token from the dictionary into b at a random
+// position.
+func byteSliceInsertToken(m *mutator, b []byte) []byte {
+	if len(m.dictionary) == 0 {
+		return nil
+	}
+	tok := m.dictionary[m.rand(len(m.dictionary))]
+	if len(tok) == 0 || len(b)+len(tok) >= cap(b) {
+		return nil
+	}
+	pos := m.rand(len(b) + 1)
+	b = b[:len(b)+len(tok)]
+	copy(b[pos+len(tok):], b[pos:])
+	copy(b[pos:], tok)
+	return b
+}
+
+// byteSliceOverwriteToken overwrites a chunk of b with a token from the
+// dictionary.
+func byteSliceOverwriteToken(m *mutator, b []byte) []byte {
+	if len(m.dictionary) == 0 {
+		return nil
+	}
+	tok := m.dictionary[m.rand(len(m.dictionary))]
+	if len(tok) == 0 || len(tok) > len(b) {
+		return nil
+	}
+	pos := m.rand(len(b) - len(tok) + 1)
+	copy(b[pos:], tok)
+	return b
+}
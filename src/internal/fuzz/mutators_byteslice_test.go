@@ -54,10 +54,12 @@ func (mr *mockRand) restore(uint64, uint64) {
 
 func TestByteSliceMutators(t *testing.T) {
 	for _, tc := range []struct {
-		name     string
-		mutator  func(*mutator, []byte) []byte
-		input    []byte
-		expected []byte
+		name       string
+		mutator    func(*mutator, []byte) []byte
+		input      []byte
+		expected   []byte
+		spliceVals [][]byte
+		dictionary [][]byte
 	}{
 		{
 			name:     "byteSliceRemoveBytes",
@@ -167,9 +169,30 @@ func TestByteSliceMutators(t *testing.T) {
 			input:    append(make([]byte, 0, 9), []byte{1, 2, 3, 4}...),
 			expected: []byte{2, 1, 3, 4},
 		},
+		{
+			name:       "byteSliceSpliceBytes",
+			mutator:    byteSliceSpliceBytes,
+			input:      []byte{1, 2, 3, 4},
+			expected:   []byte{9, 8, 7, 4},
+			spliceVals: [][]byte{{9, 8, 7}},
+		},
+		{
+			name:       "byteSliceInsertToken",
+			mutator:    byteSliceInsertToken,
+			input:      append(make([]byte, 0, 8), []byte{1, 2, 3, 4}...),
+			expected:   []byte{1, 9, 8, 2, 3, 4},
+			dictionary: [][]byte{{9, 8}, {7, 6}},
+		},
+		{
+			name:       "byteSliceOverwriteToken",
+			mutator:    byteSliceOverwriteToken,
+			input:      []byte{1, 2, 3, 4},
+			expected:   []byte{1, 9, 8, 4},
+			dictionary: [][]byte{{9, 8}, {7, 6}},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			m := &mutator{r: &mockRand{}}
+			m := &mutator{r: &mockRand{}, spliceVals: tc.spliceVals, dictionary: tc.dictionary}
 			b := tc.mutator(m, tc.input)
 			if !bytes.Equal(b, tc.expected) {
 				t.Errorf("got %x, want %x", b, tc.expected)
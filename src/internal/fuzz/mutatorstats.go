@@ -0,0 +1,123 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+// Mutation operators counted in MutatorStats. The operators that mutate
+// []byte and string values are numbered after these, in the order of
+// byteSliceMutators.
+const (
+	opInt = iota
+	opUint
+	opFloat
+	opBool
+	opSchema
+	opSplice
+	numValueOps
+)
+
+var valueOpNames = [numValueOps]string{"int", "uint", "float", "bool", "schema", "splice"}
+
+// byteSliceMutatorNames are the names of byteSliceMutators, in the same order.
+var byteSliceMutatorNames = []string{
+	"removeBytes",
+	"insertRandomBytes",
+	"duplicateBytes",
+	"overwriteBytes",
+	"bitFlip",
+	"xorByte",
+	"swapByte",
+	"arithmeticUint8",
+	"arithmeticUint16",
+	"arithmeticUint32",
+	"arithmeticUint64",
+	"overwriteInterestingUint8",
+	"overwriteInterestingUint16",
+	"overwriteInterestingUint32",
+	"insertConstantBytes",
+	"overwriteConstantBytes",
+	"shuffleBytes",
+	"swapBytes",
+	"spliceBytes",
+	"insertToken",
+	"overwriteToken",
+}
+
+// numMutatorOps returns the number of mutation operators that are counted.
+func numMutatorOps() int {
+	return numValueOps + len(byteSliceMutators)
+}
+
+// mutatorOpName returns the name of the mutation operator op.
+func mutatorOpName(op int) string {
+	if op < numValueOps {
+		return valueOpNames[op]
+	}
+	return byteSliceMutatorNames[op-numValueOps]
+}
+
+// MutatorStat counts the uses of one mutation operator during a call to
+// CoordinateFuzzing.
+type MutatorStat struct {
+	// Name identifies the operator, for example, "bitFlip".
+	Name string
+
+	// Applied is the number of times the operator was applied to a value.
+	Applied int64
+
+	// Finds is the number of inputs that expanded coverage which had the
+	// operator applied since they were mutated from a corpus entry. An input
+	// is usually mutated several times, so each operator applied to it is
+	// credited with the find.
+	Finds int64
+}
+
+// countOp records that the operator op was applied, if m counts operators.
+func (m *mutator) countOp(op int) {
+	if m.opCounts != nil {
+		m.opCounts[op]++
+	}
+}
+
+// addMutatorOps adds the number of times each operator was applied, as
+// reported by a worker, to the coordinator's totals.
+func (c *coordinator) addMutatorOps(ops []int64) {
+	if len(ops) != numMutatorOps() {
+		return
+	}
+	if c.mutatorApplied == nil {
+		c.mutatorApplied = make([]int64, numMutatorOps())
+		c.mutatorFinds = make([]int64, numMutatorOps())
+	}
+	for op, n := range ops {
+		c.mutatorApplied[op] += n
+	}
+}
+
+// creditMutatorOps credits a find to each operator in ops that was applied
+// at least once. ops are the counts reported with an input that expanded
+// coverage.
+func (c *coordinator) creditMutatorOps(ops []int64) {
+	if len(ops) != numMutatorOps() || c.mutatorFinds == nil {
+		return
+	}
+	for op, n := range ops {
+		if n > 0 {
+			c.mutatorFinds[op]++
+		}
+	}
+}
+
+// mutatorStats returns the counts recorded by addMutatorOps for each
+// operator that was applied at least once.
+func (c *coordinator) mutatorStats() []MutatorStat {
+	var stats []MutatorStat
+	for op, n := range c.mutatorApplied {
+		if n == 0 {
+			continue
+		}
+		stats = append(stats, MutatorStat{Name: mutatorOpName(op), Applied: n, Finds: c.mutatorFinds[op]})
+	}
+	return stats
+}
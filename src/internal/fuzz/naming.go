@@ -0,0 +1,73 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+)
+
+// EntryNameInfo describes a new corpus entry or crasher being named by
+// CoordinateFuzzingOpts.NamingScheme.
+type EntryNameInfo struct {
+	// Crasher is true if the entry caused an error and will be written to
+	// CorpusDir. Otherwise, it expanded coverage and will be written to
+	// CacheDir.
+	Crasher bool
+
+	// Seq is the number of entries named earlier in the run.
+	Seq int
+
+	// Generation is the number of times the entry's ancestors were mutated
+	// starting from the corpus loaded when fuzzing started.
+	Generation int
+
+	// Time is when the entry is being named.
+	Time time.Time
+}
+
+// entryPath returns the path in dir where the new corpus entry with the given
+// data should be written. By default, the name is derived from a hash of the
+// data (see corpusEntryPath). If opts.NamingScheme is set, it chooses the name,
+// and entryPath adds a numeric suffix if the name is already used by another
+// entry in dir or earlier in the run.
+func (c *coordinator) entryPath(entry CorpusEntry, dir string, crasher bool) string {
+	if c.opts.NamingScheme == nil {
+		return corpusEntryPath(entry.Data, dir)
+	}
+	info := EntryNameInfo{
+		Crasher:    crasher,
+		Seq:        c.namedEntries,
+		Generation: entry.Generation,
+		Time:       time.Now(),
+	}
+	c.namedEntries++
+	name := filepath.Base(c.opts.NamingScheme(entry.Data, info))
+	if name == "." || name == string(filepath.Separator) {
+		return corpusEntryPath(entry.Data, dir)
+	}
+	if c.entryPaths == nil {
+		c.entryPaths = make(map[string]bool)
+	}
+	path := filepath.Join(dir, name)
+	fileData := entry.Data
+	if !crasher {
+		fileData = c.cacheFileData(entry.Data)
+	}
+	for i := 1; c.entryPaths[path] || !sameFileData(path, fileData); i++ {
+		path = filepath.Join(dir, fmt.Sprintf("%s-%d", name, i))
+	}
+	c.entryPaths[path] = true
+	return path
+}
+
+// sameFileData returns true if there's no file at path or if it contains data.
+func sameFileData(path string, data []byte) bool {
+	existing, err := ioutil.ReadFile(path)
+	return err != nil || bytes.Equal(existing, data)
+}
@@ -0,0 +1,47 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestEntryPath(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "crash"), []byte("other"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	var seqs []int
+	c := &coordinator{opts: CoordinateFuzzingOpts{
+		NamingScheme: func(data []byte, info EntryNameInfo) string {
+			seqs = append(seqs, info.Seq)
+			if info.Crasher {
+				return "crash"
+			}
+			return "../entry"
+		},
+	}}
+	for i, tc := range []struct {
+		data    string
+		crasher bool
+		want    string
+	}{
+		{"a", true, "crash-1"},
+		{"b", true, "crash-2"},
+		{"c", false, "entry"},
+		{"d", false, "entry-1"},
+	} {
+		got := c.entryPath(CorpusEntry{Data: []byte(tc.data)}, dir, tc.crasher)
+		if want := filepath.Join(dir, tc.want); got != want {
+			t.Errorf("entry %d: got path %s; want %s", i, got, want)
+		}
+	}
+	if got, want := fmt.Sprint(seqs), "[0 1 2 3]"; got != want {
+		t.Errorf("got sequence numbers %s; want %s", got, want)
+	}
+}
@@ -58,12 +58,18 @@ func godebugSeed() *int {
 
 // newPcgRand generates a new, seeded Rand, ready for use.
 func newPcgRand() *pcgRand {
-	r := new(pcgRand)
 	now := uint64(time.Now().UnixNano())
 	if seed := godebugSeed(); seed != nil {
 		now = uint64(*seed)
 	}
-	inc := atomic.AddUint64(&globalInc, 1)
+	return newPcgRandSeed(now, atomic.AddUint64(&globalInc, 1))
+}
+
+// newPcgRandSeed returns a Rand that always generates the same sequence
+// for the same seed and stream.
+func newPcgRandSeed(seed, inc uint64) *pcgRand {
+	r := new(pcgRand)
+	now := seed
 	r.state = now
 	r.inc = (inc << 1) | 1
 	r.step()
@@ -0,0 +1,314 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// A Schema describes the layout of a []byte argument to the fuzz function as
+// a sequence of fields. When an argument has a schema, the mutator changes
+// the contents of its fields and the number of repeated groups, and keeps
+// length prefixes and counts consistent with them, instead of mutating bytes
+// blindly. Values that don't match the schema are mutated as usual.
+type Schema []Field
+
+// FieldKind is the kind of a Field.
+type FieldKind int
+
+const (
+	// FixedField is a field of exactly Size bytes.
+	FixedField FieldKind = iota
+
+	// PrefixedField is a field preceded by its length in bytes, an unsigned
+	// integer encoded in Size bytes.
+	PrefixedField
+
+	// RepeatedField is a group of fields, Fields, repeated the number of times
+	// given by an unsigned integer encoded in the Size bytes before the first
+	// group.
+	RepeatedField
+)
+
+// Field describes one field of a Schema.
+type Field struct {
+	Kind FieldKind
+
+	// Size is the length of a FixedField, or the length of the prefix of a
+	// PrefixedField or RepeatedField, which must be 1, 2, 4, or 8.
+	Size int
+
+	// BigEndian is true if the prefix of a PrefixedField or RepeatedField is
+	// encoded in big-endian order. Otherwise, it's little-endian.
+	BigEndian bool
+
+	// Fields is the layout of each group of a RepeatedField.
+	Fields Schema
+}
+
+// checkInputSchemas checks that each schema in schemas is valid and
+// describes a []byte argument in types. See
+// CoordinateFuzzingOpts.InputSchemas.
+func checkInputSchemas(schemas map[int]Schema, types []reflect.Type) error {
+	for arg, s := range schemas {
+		if arg < 0 || arg >= len(types) {
+			return fmt.Errorf("InputSchemas: invalid argument index %d", arg)
+		}
+		if types[arg] != reflect.TypeOf([]byte(nil)) {
+			return fmt.Errorf("InputSchemas: argument %d is %v, not []byte", arg, types[arg])
+		}
+		if err := s.check(); err != nil {
+			return fmt.Errorf("InputSchemas: argument %d: %v", arg, err)
+		}
+	}
+	return nil
+}
+
+func (s Schema) check() error {
+	for _, f := range s {
+		switch f.Kind {
+		case FixedField:
+			if f.Size < 0 {
+				return fmt.Errorf("invalid size %d for fixed field", f.Size)
+			}
+		case PrefixedField, RepeatedField:
+			if f.Size != 1 && f.Size != 2 && f.Size != 4 && f.Size != 8 {
+				return fmt.Errorf("invalid prefix size %d; must be 1, 2, 4, or 8", f.Size)
+			}
+			if f.Kind == RepeatedField {
+				if len(f.Fields) == 0 {
+					return errors.New("repeated field has no fields")
+				}
+				if err := f.Fields.check(); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("unknown field kind %d", f.Kind)
+		}
+	}
+	return nil
+}
+
+// fieldValue is the value of a field parsed according to a Schema.
+type fieldValue struct {
+	field *Field
+	data  []byte         // contents of a FixedField or PrefixedField
+	elems [][]fieldValue // groups of a RepeatedField
+}
+
+func (f *Field) byteOrder() binary.ByteOrder {
+	if f.BigEndian {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// maxPrefix returns the largest number the prefix of f can hold.
+func (f *Field) maxPrefix() uint64 {
+	if f.Size == 8 {
+		return 1<<64 - 1
+	}
+	return 1<<(8*uint(f.Size)) - 1
+}
+
+func (f *Field) readPrefix(b []byte) uint64 {
+	switch f.Size {
+	case 1:
+		return uint64(b[0])
+	case 2:
+		return uint64(f.byteOrder().Uint16(b))
+	case 4:
+		return uint64(f.byteOrder().Uint32(b))
+	default:
+		return f.byteOrder().Uint64(b)
+	}
+}
+
+func (f *Field) appendPrefix(b []byte, n uint64) []byte {
+	var buf [8]byte
+	switch f.Size {
+	case 1:
+		buf[0] = byte(n)
+	case 2:
+		f.byteOrder().PutUint16(buf[:], uint16(n))
+	case 4:
+		f.byteOrder().PutUint32(buf[:], uint32(n))
+	default:
+		f.byteOrder().PutUint64(buf[:], n)
+	}
+	return append(b, buf[:f.Size]...)
+}
+
+// parseSchema parses b according to s. It returns the values of the fields
+// and the bytes left over, or false if b doesn't match s.
+func parseSchema(s Schema, b []byte) ([]fieldValue, []byte, bool) {
+	vals := make([]fieldValue, len(s))
+	for i := range s {
+		f := &s[i]
+		vals[i].field = f
+		switch f.Kind {
+		case FixedField:
+			if len(b) < f.Size {
+				return nil, nil, false
+			}
+			vals[i].data, b = b[:f.Size], b[f.Size:]
+		case PrefixedField:
+			if len(b) < f.Size {
+				return nil, nil, false
+			}
+			n := f.readPrefix(b)
+			b = b[f.Size:]
+			if n > uint64(len(b)) {
+				return nil, nil, false
+			}
+			vals[i].data, b = b[:n], b[n:]
+		case RepeatedField:
+			if len(b) < f.Size {
+				return nil, nil, false
+			}
+			n := f.readPrefix(b)
+			b = b[f.Size:]
+			if n > uint64(len(b)) {
+				// Only groups of empty fixed fields could be this numerous.
+				// Don't spend time parsing a count that's most likely wrong.
+				return nil, nil, false
+			}
+			for j := uint64(0); j < n; j++ {
+				var elem []fieldValue
+				var ok bool
+				if elem, b, ok = parseSchema(f.Fields, b); !ok {
+					return nil, nil, false
+				}
+				vals[i].elems = append(vals[i].elems, elem)
+			}
+		}
+	}
+	return vals, b, true
+}
+
+// appendValues appends the encoding of vals to b, with length prefixes and
+// counts computed from the values.
+func appendValues(b []byte, vals []fieldValue) []byte {
+	for _, v := range vals {
+		switch v.field.Kind {
+		case FixedField:
+			b = append(b, v.data...)
+		case PrefixedField:
+			b = v.field.appendPrefix(b, uint64(len(v.data)))
+			b = append(b, v.data...)
+		case RepeatedField:
+			b = v.field.appendPrefix(b, uint64(len(v.elems)))
+			for _, elem := range v.elems {
+				b = appendValues(b, elem)
+			}
+		}
+	}
+	return b
+}
+
+// zeroValues returns the shortest values that match s.
+func zeroValues(s Schema) []fieldValue {
+	vals := make([]fieldValue, len(s))
+	for i := range s {
+		vals[i].field = &s[i]
+		if s[i].Kind == FixedField {
+			vals[i].data = make([]byte, s[i].Size)
+		}
+	}
+	return vals
+}
+
+// collectFields appends pointers to the values in vals that can be mutated to
+// leaves (fixed and prefixed fields) and groups (repeated fields).
+func collectFields(vals []fieldValue, leaves, groups []*fieldValue) ([]*fieldValue, []*fieldValue) {
+	for i := range vals {
+		v := &vals[i]
+		switch v.field.Kind {
+		case FixedField:
+			if v.field.Size > 0 {
+				leaves = append(leaves, v)
+			}
+		case PrefixedField:
+			leaves = append(leaves, v)
+		case RepeatedField:
+			groups = append(groups, v)
+			for _, elem := range v.elems {
+				leaves, groups = collectFields(elem, leaves, groups)
+			}
+		}
+	}
+	return leaves, groups
+}
+
+// mutateSchema mutates *b, which is expected to have the layout s, by changing
+// the contents of one field or the number of groups of one repeated field.
+// The result has at most cap(*b) bytes. mutateSchema returns false without
+// changing *b if *b doesn't match s.
+func (m *mutator) mutateSchema(b *[]byte, s Schema) bool {
+	vals, rest, ok := parseSchema(s, *b)
+	if !ok || len(rest) != 0 {
+		return false
+	}
+	leaves, groups := collectFields(vals, nil, nil)
+	if len(leaves) == 0 && len(groups) == 0 {
+		return true // nothing to mutate
+	}
+	if len(groups) > 0 && (len(leaves) == 0 || m.rand(4) == 0) {
+		g := groups[m.rand(len(groups))]
+		switch {
+		case len(g.elems) > 0 && m.r.bool():
+			// Remove a group.
+			j := m.rand(len(g.elems))
+			g.elems = append(g.elems[:j:j], g.elems[j+1:]...)
+		case uint64(len(g.elems)) < g.field.maxPrefix():
+			// Add a copy of a group, or an empty one.
+			elem := zeroValues(g.field.Fields)
+			if len(g.elems) > 0 {
+				elem = g.elems[m.rand(len(g.elems))]
+			}
+			j := m.rand(len(g.elems) + 1)
+			g.elems = append(g.elems[:j:j], append([][]fieldValue{elem}, g.elems[j:]...)...)
+		}
+	} else {
+		v := leaves[m.rand(len(leaves))]
+		size := len(appendValues(nil, vals))
+		limit := cap(*b) - size + len(v.data)
+		if v.field.Kind == FixedField {
+			limit = v.field.Size
+		} else if uint64(limit) > v.field.maxPrefix() {
+			limit = int(v.field.maxPrefix())
+		}
+		if len(v.data) == 0 && limit < 2 {
+			// mutateBytes can only change an empty slice by inserting bytes,
+			// and it needs room for that.
+			return true
+		}
+		data := append(make([]byte, 0, limit), v.data...)
+		// m.minLen applies to the whole value, which is checked below, not
+		// to each field.
+		minLen := m.minLen
+		m.minLen = 0
+		m.mutateBytes(&data)
+		m.minLen = minLen
+		if v.field.Kind == FixedField {
+			// Keep the field's size.
+			data = data[:v.field.Size]
+		}
+		v.data = data
+	}
+	out := appendValues(nil, vals)
+	if len(out) > cap(*b) {
+		return true // too large; leave the value alone
+	}
+	if len(out) < m.minLen && len(out) < len(*b) {
+		return true // too small; leave the value alone
+	}
+	*b = append((*b)[:0], out...)
+	return true
+}
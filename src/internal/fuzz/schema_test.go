@@ -0,0 +1,127 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMutateSchema(t *testing.T) {
+	s := Schema{
+		{Kind: FixedField, Size: 2},
+		{Kind: PrefixedField, Size: 1},
+		{Kind: RepeatedField, Size: 2, BigEndian: true, Fields: Schema{
+			{Kind: PrefixedField, Size: 4},
+		}},
+	}
+	if err := s.check(); err != nil {
+		t.Fatal(err)
+	}
+	in := []byte{
+		'h', 'i',
+		3, 'a', 'b', 'c',
+		0, 2,
+		1, 0, 0, 0, 'x',
+		0, 0, 0, 0,
+	}
+	if _, rest, ok := parseSchema(s, in); !ok || len(rest) != 0 {
+		t.Fatalf("input does not match schema")
+	}
+
+	m := &mutator{r: newPcgRandSeed(1, 1)}
+	b := append(make([]byte, 0, 100), in...)
+	changed := false
+	for i := 0; i < 1000; i++ {
+		if !m.mutateSchema(&b, s) {
+			t.Fatalf("iteration %d: mutateSchema did not mutate a value matching the schema", i)
+		}
+		if _, rest, ok := parseSchema(s, b); !ok || len(rest) != 0 {
+			t.Fatalf("iteration %d: mutated value %q does not match schema", i, b)
+		}
+		if cap(b) != 100 {
+			t.Fatalf("iteration %d: capacity changed to %d", i, cap(b))
+		}
+		changed = changed || !bytes.Equal(b, in)
+	}
+	if !changed {
+		t.Error("value was never changed")
+	}
+
+	if m.mutateSchema(&b, Schema{{Kind: FixedField, Size: 1000}}) {
+		t.Error("mutateSchema mutated a value that does not match the schema")
+	}
+}
+
+func TestSchemaCheck(t *testing.T) {
+	for _, s := range []Schema{
+		{{Kind: FixedField, Size: -1}},
+		{{Kind: PrefixedField, Size: 3}},
+		{{Kind: RepeatedField, Size: 1}},
+		{{Kind: RepeatedField, Size: 1, Fields: Schema{{Kind: PrefixedField}}}},
+		{{Kind: FieldKind(42)}},
+	} {
+		if err := s.check(); err == nil {
+			t.Errorf("%+v: got no error", s)
+		}
+	}
+}
+
+func TestMutatorInputSchemas(t *testing.T) {
+	s := Schema{{Kind: PrefixedField, Size: 1}, {Kind: FixedField, Size: 2}}
+	in := []byte{3, 'a', 'b', 'c', 'x', 'y'}
+	m := &mutator{r: newPcgRandSeed(1, 1), schemas: map[int]Schema{1: s}}
+	vals := []interface{}{42, append([]byte(nil), in...)}
+	changed := false
+	for i := 0; i < 1000; i++ {
+		m.mutate(vals, 1<<10)
+		b := vals[1].([]byte)
+		if _, rest, ok := parseSchema(s, b); !ok || len(rest) != 0 {
+			t.Fatalf("iteration %d: mutated value %q does not match the schema of its argument", i, b)
+		}
+		changed = changed || !bytes.Equal(b, in)
+	}
+	if !changed {
+		t.Error("value with a schema was never changed")
+	}
+}
+
+func TestCheckInputSchemas(t *testing.T) {
+	types := []reflect.Type{reflect.TypeOf(""), reflect.TypeOf([]byte(nil))}
+	s := Schema{{Kind: FixedField, Size: 1}}
+	if err := checkInputSchemas(map[int]Schema{1: s}, types); err != nil {
+		t.Errorf("valid schema: %v", err)
+	}
+	for _, schemas := range []map[int]Schema{
+		{-1: s},
+		{2: s},
+		{0: s}, // not []byte
+		{1: {{Kind: PrefixedField, Size: 3}}},
+	} {
+		if err := checkInputSchemas(schemas, types); err == nil {
+			t.Errorf("%v: got no error", schemas)
+		}
+	}
+}
+
+func TestInputSchemasPingArgs(t *testing.T) {
+	// Schemas are sent to workers with the other settings in pingArgs.
+	want := pingArgs{InputSchemas: map[int]Schema{
+		2: {{Kind: RepeatedField, Size: 2, BigEndian: true, Fields: Schema{{Kind: FixedField, Size: 3}}}},
+	}}
+	b, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got pingArgs
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.InputSchemas, want.InputSchemas) {
+		t.Errorf("got schemas %+v after encoding; want %+v", got.InputSchemas, want.InputSchemas)
+	}
+}
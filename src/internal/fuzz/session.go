@@ -0,0 +1,338 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// sessionMagic begins every session log written with
+// CoordinateFuzzingOpts.RecordSessionPath.
+const sessionMagic = "go fuzz session v1\n"
+
+// maxSessionDataLen is the longest path or value a session log may hold.
+// It guards against allocating huge buffers for a corrupted log.
+const maxSessionDataLen = 1 << 30
+
+// sessionFlagSplice is set in a record's flags if the call spliced the input
+// with another entry.
+const sessionFlagSplice = 1 << 0
+
+// sessionCall is what a worker needs, beyond the input and the number of
+// values to test, to repeat a call to workerServer.fuzz exactly.
+type sessionCall struct {
+	// RandState and RandInc are the state of the worker's random number
+	// generator before it mutated the input.
+	RandState, RandInc uint64
+
+	// Deflakes lists the counts at which the worker ran a value again to
+	// check its new coverage, in increasing order.
+	Deflakes []int64
+}
+
+// sessionRecord is one call to workerServer.fuzz in a session log.
+type sessionRecord struct {
+	entry  CorpusEntry
+	splice *CorpusEntry
+	count  int64
+	call   sessionCall
+}
+
+// A session log is sessionMagic followed by a record for each call. All
+// integers are uvarints, except the PRNG state, which is two little-endian
+// uint64s. A record is:
+//
+//	flags
+//	entry ref
+//	splice ref, if flags has sessionFlagSplice
+//	randState randInc
+//	count
+//	len(deflakes) deflakes...
+//
+// A ref is the index of the entry among those the log has referred to so
+// far. The first reference to an entry is followed by the length and bytes of
+// its path, then of its data, so each entry is written once however many
+// calls fuzz it.
+
+// sessionRecorder writes a session log. It's safe for concurrent use.
+type sessionRecorder struct {
+	mu  sync.Mutex
+	f   *os.File
+	w   *bufio.Writer
+	ids map[string]uint64
+	err error
+	buf [binary.MaxVarintLen64]byte
+}
+
+// newSessionRecorder creates the session log at path.
+func newSessionRecorder(path string) (*sessionRecorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &sessionRecorder{f: f, w: bufio.NewWriter(f), ids: make(map[string]uint64)}
+	r.w.WriteString(sessionMagic)
+	return r, nil
+}
+
+// record adds a call that tested count values derived from entry, spliced
+// with splice if it's not nil, to the log. Errors are returned by close.
+func (r *sessionRecorder) record(entry CorpusEntry, splice *CorpusEntry, count int64, call sessionCall) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	var flags byte
+	if splice != nil {
+		flags |= sessionFlagSplice
+	}
+	r.w.WriteByte(flags)
+	if r.err = r.writeRef(entry); r.err != nil {
+		return
+	}
+	if splice != nil {
+		if r.err = r.writeRef(*splice); r.err != nil {
+			return
+		}
+	}
+	binary.LittleEndian.PutUint64(r.buf[:], call.RandState)
+	r.w.Write(r.buf[:8])
+	binary.LittleEndian.PutUint64(r.buf[:], call.RandInc)
+	r.w.Write(r.buf[:8])
+	r.writeUvarint(uint64(count))
+	r.writeUvarint(uint64(len(call.Deflakes)))
+	for _, n := range call.Deflakes {
+		r.writeUvarint(uint64(n))
+	}
+}
+
+// writeRef writes a reference to e, and its path and data if it hasn't been
+// referred to before.
+func (r *sessionRecorder) writeRef(e CorpusEntry) error {
+	key := e.Path
+	if key == "" {
+		key = string(e.Data)
+	}
+	if id, ok := r.ids[key]; ok {
+		r.writeUvarint(id)
+		return nil
+	}
+	data, err := CorpusEntryData(e)
+	if err != nil {
+		return err
+	}
+	id := uint64(len(r.ids))
+	r.ids[key] = id
+	r.writeUvarint(id)
+	r.writeUvarint(uint64(len(e.Path)))
+	r.w.WriteString(e.Path)
+	r.writeUvarint(uint64(len(data)))
+	r.w.Write(data)
+	return nil
+}
+
+func (r *sessionRecorder) writeUvarint(x uint64) {
+	n := binary.PutUvarint(r.buf[:], x)
+	r.w.Write(r.buf[:n])
+}
+
+// close flushes and closes the log. It returns the first error recording
+// any call.
+func (r *sessionRecorder) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.err
+	if ferr := r.w.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// sessionReader reads the records of a session log in order.
+type sessionReader struct {
+	path    string
+	f       *os.File
+	r       *bufio.Reader
+	entries []CorpusEntry
+
+	// next is the record peek returned, until advance is called, and err is
+	// the error reading it. err is io.EOF at the end of the log.
+	next *sessionRecord
+	err  error
+
+	// read is the number of records passed by advance.
+	read int64
+}
+
+// openSession opens the session log at path for reading.
+func openSession(path string) (*sessionReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &sessionReader{path: path, f: f, r: bufio.NewReader(f)}
+	magic := make([]byte, len(sessionMagic))
+	if _, err := io.ReadFull(r.r, magic); err != nil || string(magic) != sessionMagic {
+		f.Close()
+		return nil, fmt.Errorf("%s is not a fuzzing session log", path)
+	}
+	return r, nil
+}
+
+// peek returns the next record without consuming it, or io.EOF if there are
+// no more.
+func (r *sessionReader) peek() (*sessionRecord, error) {
+	if r.next == nil && r.err == nil {
+		r.next, r.err = r.readRecord()
+		if r.err != nil && r.err != io.EOF {
+			r.err = fmt.Errorf("reading session log %s: record %d: %w", r.path, r.read, r.err)
+		}
+	}
+	return r.next, r.err
+}
+
+// advance consumes the record peek returned.
+func (r *sessionReader) advance() {
+	r.next = nil
+	r.read++
+}
+
+func (r *sessionReader) readRecord() (*sessionRecord, error) {
+	flags, err := r.r.ReadByte()
+	if err != nil {
+		return nil, err // io.EOF at the end of a complete log
+	}
+	rec := &sessionRecord{}
+	if rec.entry, err = r.readRef(); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if flags&sessionFlagSplice != 0 {
+		splice, err := r.readRef()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		rec.splice = &splice
+	}
+	var state [16]byte
+	if _, err := io.ReadFull(r.r, state[:]); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	rec.call.RandState = binary.LittleEndian.Uint64(state[:8])
+	rec.call.RandInc = binary.LittleEndian.Uint64(state[8:])
+	count, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if count == 0 || count > 1<<62 {
+		return nil, fmt.Errorf("invalid count %d", count)
+	}
+	rec.count = int64(count)
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if n > count {
+		return nil, fmt.Errorf("%d deflakes in a call that tested %d values", n, count)
+	}
+	for i := uint64(0); i < n; i++ {
+		d, err := binary.ReadUvarint(r.r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		rec.call.Deflakes = append(rec.call.Deflakes, int64(d))
+	}
+	return rec, nil
+}
+
+// readRef reads a reference to an entry, and the entry if this is the first
+// reference to it.
+func (r *sessionReader) readRef() (CorpusEntry, error) {
+	id, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return CorpusEntry{}, err
+	}
+	switch {
+	case id < uint64(len(r.entries)):
+		return r.entries[id], nil
+	case id > uint64(len(r.entries)):
+		return CorpusEntry{}, fmt.Errorf("reference to entry %d before entry %d", id, len(r.entries))
+	}
+	path, err := r.readBytes()
+	if err != nil {
+		return CorpusEntry{}, err
+	}
+	data, err := r.readBytes()
+	if err != nil {
+		return CorpusEntry{}, err
+	}
+	e := CorpusEntry{Path: string(path), Data: data}
+	r.entries = append(r.entries, e)
+	return e, nil
+}
+
+func (r *sessionReader) readBytes() ([]byte, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxSessionDataLen {
+		return nil, fmt.Errorf("value of %d bytes is too long", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (r *sessionReader) close() error {
+	return r.f.Close()
+}
+
+// unexpectedEOF returns io.ErrUnexpectedEOF for io.EOF, which in the middle
+// of a record means the log was cut short.
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// replayInput returns input changed to repeat the next call in
+// opts.ReplaySessionPath, or false if there are no more or the log can't be
+// read.
+func (c *coordinator) replayInput(input fuzzInput) (fuzzInput, bool) {
+	rec, err := c.replay.peek()
+	if err != nil {
+		return fuzzInput{}, false
+	}
+	input.entry = rec.entry
+	input.splice = rec.splice
+	input.limit = rec.count
+	input.timeout = 0 // the call must test exactly count values
+	input.replay = &rec.call
+	return input, true
+}
+
+// replayDone returns whether every call in opts.ReplaySessionPath has been
+// replayed, and the error reading the log, if any.
+func (c *coordinator) replayDone() (bool, error) {
+	if _, err := c.replay.peek(); err == io.EOF {
+		return c.countWaiting == 0, nil
+	} else if err != nil {
+		return true, err
+	}
+	return false, nil
+}
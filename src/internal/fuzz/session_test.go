@@ -0,0 +1,134 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSessionLog(t *testing.T) {
+	dir := t.TempDir()
+	entryPath := filepath.Join(dir, "entry")
+	if err := os.WriteFile(entryPath, marshalCorpusFile([]byte("abc")), 0666); err != nil {
+		t.Fatal(err)
+	}
+	onDisk := CorpusEntry{Path: entryPath}
+	generated := CorpusEntry{Data: marshalCorpusFile([]byte("generated"))}
+	want := []sessionRecord{
+		{entry: onDisk, count: 100, call: sessionCall{RandState: 1, RandInc: 3}},
+		{entry: generated, count: 1, call: sessionCall{RandState: 1 << 63, RandInc: 5, Deflakes: []int64{1}}},
+		{entry: onDisk, splice: &generated, count: 7, call: sessionCall{RandState: 2, RandInc: 7, Deflakes: []int64{3, 5}}},
+	}
+
+	logPath := filepath.Join(dir, "session")
+	rec, err := newSessionRecorder(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range want {
+		rec.record(r.entry, r.splice, r.count, r.call)
+	}
+	if err := rec.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := openSession(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.close()
+	for i, w := range want {
+		got, err := r.peek()
+		if err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		data, _ := CorpusEntryData(w.entry)
+		w.entry = CorpusEntry{Path: w.entry.Path, Data: data}
+		if !reflect.DeepEqual(*got, w) {
+			t.Errorf("record %d: got %+v; want %+v", i, *got, w)
+		}
+		r.advance()
+	}
+	if _, err := r.peek(); err != io.EOF {
+		t.Errorf("after the last record, got error %v; want io.EOF", err)
+	}
+
+	// A log that's cut short is an error, not the end of the log.
+	full, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(logPath, full[:len(full)-1], 0666); err != nil {
+		t.Fatal(err)
+	}
+	r, err = openSession(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.close()
+	for {
+		if _, err := r.peek(); err == io.EOF {
+			t.Fatal("truncated log read to the end without an error")
+		} else if err != nil {
+			break
+		}
+		r.advance()
+	}
+}
+
+func TestWorkerReplay(t *testing.T) {
+	var calls []string
+	ws := &workerServer{
+		fuzzFn: func(e CorpusEntry) error {
+			calls = append(calls, string(marshalCorpusFile(e.Values...)))
+			return nil
+		},
+		workerComm: workerComm{memMu: make(chan *sharedMem, 1)},
+		m:          newMutator(),
+	}
+	mem, err := sharedMemTempFile("", 1<<16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mem.Close()
+	ws.memMu <- mem
+
+	const limit = 50
+	input := marshalCorpusFile([]byte("replay me"), int(7))
+	splice := marshalCorpusFile([]byte("other"), int(9))
+	run := func(replay *sessionCall) (sessionCall, []string) {
+		calls = nil
+		m := <-ws.memMu
+		m.setValue(input)
+		m.header().count = 0
+		ws.memMu <- m
+		resp := ws.fuzz(context.Background(), fuzzArgs{Limit: limit, Splice: splice, Replay: replay})
+		if resp.Count != limit {
+			t.Fatalf("fuzz tested %d values; want %d", resp.Count, limit)
+		}
+		m = <-ws.memMu
+		defer func() { ws.memMu <- m }()
+		return sessionCall{RandState: m.header().randState, RandInc: m.header().randInc}, calls
+	}
+
+	call, want := run(nil)
+	// Move the worker's PRNG on, as other calls would.
+	ws.m.r.uint32()
+	if _, got := run(&call); !reflect.DeepEqual(got, want) {
+		t.Errorf("replay tested different values:\ngot  %q\nwant %q", got, want)
+	}
+
+	// Values deflaked in the recorded call are run again.
+	call.Deflakes = []int64{10}
+	want = append(append(append([]string(nil), want[:10]...), want[9]), want[10:limit-1]...)
+	if _, got := run(&call); !reflect.DeepEqual(got, want) {
+		t.Errorf("replay with a deflake tested different values:\ngot  %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// slowEntryFactor is how many times slower than the median corpus entry
+	// an entry must be, on average, for the coordinator to warn about it.
+	slowEntryFactor = 100
+
+	// slowEntryMinEntries is the number of corpus entries that must have been
+	// timed before the coordinator looks for slow ones. With fewer, the
+	// median isn't meaningful.
+	slowEntryMinEntries = 10
+)
+
+// entryTime is the total time spent calling the fuzz function with a corpus
+// entry and values mutated from it, and the number of calls.
+type entryTime struct {
+	total time.Duration
+	count int64
+}
+
+func (t entryTime) average() time.Duration {
+	return t.total / time.Duration(t.count)
+}
+
+// recordEntryTime adds the time a worker spent on result to the time of the
+// corpus entry it fuzzed.
+func (c *coordinator) recordEntryTime(result fuzzResult) {
+	if result.inputPath == "" || result.minimized || result.count == 0 || result.totalDuration <= 0 {
+		return
+	}
+	if c.entryTimes == nil {
+		c.entryTimes = make(map[string]entryTime)
+	}
+	t := c.entryTimes[result.inputPath]
+	t.total += result.totalDuration
+	t.count += result.count
+	c.entryTimes[result.inputPath] = t
+}
+
+// checkSlowEntries prints a warning, once per entry, for each corpus entry
+// whose average time per call is more than slowEntryFactor times the median.
+// Whenever such an entry is fuzzed, it slows the whole run down, so the user
+// may want to investigate it or remove it. It's called periodically.
+func (c *coordinator) checkSlowEntries() {
+	if len(c.entryTimes) < slowEntryMinEntries {
+		return
+	}
+	avgs := make([]time.Duration, 0, len(c.entryTimes))
+	for _, t := range c.entryTimes {
+		avgs = append(avgs, t.average())
+	}
+	sort.Slice(avgs, func(i, j int) bool { return avgs[i] < avgs[j] })
+	median := avgs[len(avgs)/2]
+	if median <= 0 {
+		return
+	}
+	var slow []string
+	for path, t := range c.entryTimes {
+		if t.average() > slowEntryFactor*median && !c.warned["slow entry "+path] {
+			slow = append(slow, path)
+		}
+	}
+	sort.Strings(slow)
+	for _, path := range slow {
+		avg := c.entryTimes[path].average()
+		c.logf(LogWarn, "warning: corpus entry %s takes %v per call on average, %dx the median of %v; it slows down fuzzing whenever it's chosen\n", path, avg, avg/median, median)
+		c.warned["slow entry "+path] = true
+	}
+}
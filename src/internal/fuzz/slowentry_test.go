@@ -0,0 +1,33 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckSlowEntries(t *testing.T) {
+	var log bytes.Buffer
+	c := &coordinator{
+		opts:   CoordinateFuzzingOpts{Log: &log},
+		warned: make(map[string]bool),
+	}
+	for i := 0; i < slowEntryMinEntries; i++ {
+		c.recordEntryTime(fuzzResult{inputPath: fmt.Sprint("fast", i), count: 10, totalDuration: 10 * time.Microsecond})
+	}
+	c.recordEntryTime(fuzzResult{inputPath: "slow", count: 2, totalDuration: 2 * time.Second})
+	c.checkSlowEntries()
+	c.checkSlowEntries()
+	if got := strings.Count(log.String(), "warning: corpus entry"); got != 1 {
+		t.Fatalf("got %d warnings; want 1:\n%s", got, log.String())
+	}
+	if !strings.Contains(log.String(), "corpus entry slow ") {
+		t.Errorf("warning doesn't name the slow entry:\n%s", log.String())
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotEntry describes a file in a corpus snapshot written by
+// writeSnapshot. The list of entries is stored in the snapshot as
+// manifest.json.
+type snapshotEntry struct {
+	// Name is the path of the file in the snapshot. Seed corpus entries are
+	// in "seed/", other corpus entries in "corpus/", and crashers in
+	// "crashers/".
+	Name string
+
+	// Generation and Parent describe the entry's lineage. See CorpusEntry.
+	Generation int    `json:",omitempty"`
+	Parent     string `json:",omitempty"`
+
+	// NewCoverageBits is the number of coverage bits the entry added to the
+	// coverage of the entries before it, when it was added to the corpus.
+	NewCoverageBits int `json:",omitempty"`
+
+	// Err is the error message reported for a crasher.
+	Err string `json:",omitempty"`
+}
+
+// writeSnapshot writes the corpus and crashers to a gzip-compressed tar
+// archive at path, with a manifest describing each file. The archive is
+// written to a temporary file first, then renamed, so an existing file at
+// path is only replaced by a complete archive.
+func (c *coordinator) writeSnapshot(path string) (err error) {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	gz := gzip.NewWriter(f)
+	tw := &tarWriter{w: gz, mtime: time.Now()}
+
+	var manifest []snapshotEntry
+	for _, e := range c.corpus.entries {
+		data, err := CorpusEntryData(e)
+		if err != nil {
+			return err
+		}
+		dir := "corpus/"
+		if e.IsSeed {
+			dir = "seed/"
+		}
+		se := snapshotEntry{
+			Name:            dir + testName(e.Path),
+			Generation:      e.Generation,
+			NewCoverageBits: c.entryNewBits[e.Path],
+		}
+		if e.Parent != "" {
+			se.Parent = testName(e.Parent)
+		}
+		if err := tw.writeFile(se.Name, data); err != nil {
+			return err
+		}
+		manifest = append(manifest, se)
+	}
+	for _, cr := range c.crashers {
+		data, err := ioutil.ReadFile(cr.Path)
+		if err != nil {
+			return err
+		}
+		se := snapshotEntry{Name: "crashers/" + testName(cr.Path), Err: cr.Err}
+		if err := tw.writeFile(se.Name, data); err != nil {
+			return err
+		}
+		manifest = append(manifest, se)
+	}
+	data, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := tw.writeFile("manifest.json", append(data, '\n')); err != nil {
+		return err
+	}
+	if err := tw.close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// tarWriter writes regular files to an archive in the ustar format, which is
+// all snapshots need. archive/tar isn't used because it depends on os/user,
+// which would be linked into every test binary.
+type tarWriter struct {
+	w     io.Writer
+	mtime time.Time
+}
+
+const tarBlockSize = 512
+
+func (tw *tarWriter) writeFile(name string, data []byte) error {
+	if len(name) > 100 {
+		return fmt.Errorf("file name %q is too long for a snapshot", name)
+	}
+	var hdr [tarBlockSize]byte
+	copy(hdr[0:100], name)
+	copy(hdr[100:108], fmt.Sprintf("%07o", 0644))
+	copy(hdr[108:116], fmt.Sprintf("%07o", 0))                // uid
+	copy(hdr[116:124], fmt.Sprintf("%07o", 0))                // gid
+	copy(hdr[124:136], fmt.Sprintf("%011o", len(data)))       // size
+	copy(hdr[136:148], fmt.Sprintf("%011o", tw.mtime.Unix())) // mtime
+	hdr[156] = '0'                                            // regular file
+	copy(hdr[257:265], "ustar\x0000")
+	for i := 148; i < 156; i++ {
+		hdr[i] = ' ' // checksum is computed with blanks in its place
+	}
+	sum := 0
+	for _, b := range hdr {
+		sum += int(b)
+	}
+	copy(hdr[148:156], fmt.Sprintf("%06o\x00 ", sum))
+	if _, err := tw.w.Write(hdr[:]); err != nil {
+		return err
+	}
+	if _, err := tw.w.Write(data); err != nil {
+		return err
+	}
+	return tw.pad(len(data))
+}
+
+// pad writes zeros to fill the last block of a file of n bytes.
+func (tw *tarWriter) pad(n int) error {
+	if r := n % tarBlockSize; r != 0 {
+		_, err := tw.w.Write(make([]byte, tarBlockSize-r))
+		return err
+	}
+	return nil
+}
+
+// close writes the two empty blocks that end an archive.
+func (tw *tarWriter) close() error {
+	_, err := tw.w.Write(make([]byte, 2*tarBlockSize))
+	return err
+}
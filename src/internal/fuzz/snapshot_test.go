@@ -0,0 +1,127 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	crasherPath := filepath.Join(dir, "crasher")
+	if err := ioutil.WriteFile(crasherPath, []byte("boom"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	c := &coordinator{
+		corpus: corpus{entries: []CorpusEntry{
+			{Path: "seed#0", Data: []byte("seed"), IsSeed: true},
+			{Path: filepath.Join(dir, "child"), Data: []byte("child"), Parent: "seed#0", Generation: 1},
+		}},
+		crashers:     []Crasher{{Path: crasherPath, Err: "panic: boom"}},
+		entryNewBits: map[string]int{"seed#0": 3, filepath.Join(dir, "child"): 1},
+	}
+	path := filepath.Join(dir, "snapshot.tar.gz")
+	if err := c.writeSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+
+	files := readSnapshot(t, path)
+	for name, want := range map[string]string{
+		"seed/seed#0":      "seed",
+		"corpus/child":     "child",
+		"crashers/crasher": "boom",
+	} {
+		if got := files[name]; got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+	manifest := readManifest(t, files)
+	want := []snapshotEntry{
+		{Name: "seed/seed#0", NewCoverageBits: 3},
+		{Name: "corpus/child", Generation: 1, Parent: "seed#0", NewCoverageBits: 1},
+		{Name: "crashers/crasher", Err: "panic: boom"},
+	}
+	if !reflect.DeepEqual(manifest, want) {
+		t.Errorf("manifest: got %+v, want %+v", manifest, want)
+	}
+}
+
+func TestSnapshotWarmupCoverage(t *testing.T) {
+	// Seeds tested by workers during warmup are credited with the coverage
+	// they added.
+	path := filepath.Join(t.TempDir(), "snapshot.tar.gz")
+	err := coordinateForTest(t, "cover", CoordinateFuzzingOpts{
+		Limit:        100,
+		SnapshotPath: path,
+		Seed: []CorpusEntry{
+			{Path: "seed#0", Data: marshalCorpusFile([]byte("a")), Values: []interface{}{[]byte("a")}, IsSeed: true},
+			{Path: "seed#1", Data: marshalCorpusFile([]byte("ab")), Values: []interface{}{[]byte("ab")}, IsSeed: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]int)
+	for _, e := range readManifest(t, readSnapshot(t, path)) {
+		got[e.Name] = e.NewCoverageBits
+	}
+	for name, want := range map[string]int{"seed/seed#0": 1, "seed/seed#1": 1} {
+		if got[name] != want {
+			t.Errorf("%s: got NewCoverageBits %d; want %d", name, got[name], want)
+		}
+	}
+}
+
+// readSnapshot returns the contents of each file in the snapshot at path.
+func readSnapshot(t *testing.T, path string) map[string]string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for len(archive) >= tarBlockSize && archive[0] != 0 {
+		name := strings.TrimRight(string(archive[0:100]), "\x00")
+		size, err := strconv.ParseInt(strings.TrimRight(string(archive[124:136]), "\x00"), 8, 64)
+		if err != nil {
+			t.Fatalf("%s: bad size: %v", name, err)
+		}
+		archive = archive[tarBlockSize:]
+		files[name] = string(archive[:size])
+		archive = archive[(size+tarBlockSize-1)/tarBlockSize*tarBlockSize:]
+	}
+	if len(archive) != 2*tarBlockSize || !bytes.Equal(archive, make([]byte, 2*tarBlockSize)) {
+		t.Errorf("archive does not end with two empty blocks")
+	}
+	return files
+}
+
+// readManifest decodes the manifest among the files of a snapshot.
+func readManifest(t *testing.T, files map[string]string) []snapshotEntry {
+	t.Helper()
+	var manifest []snapshotEntry
+	if err := json.Unmarshal([]byte(files["manifest.json"]), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	return manifest
+}
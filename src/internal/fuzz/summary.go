@@ -0,0 +1,251 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import "time"
+
+// Summary describes the outcome of a call to CoordinateFuzzing. It is passed
+// to CoordinateFuzzingOpts.OnFinish once all workers have stopped.
+type Summary struct {
+	// Execs is the total number of calls to the fuzz function, including calls
+	// made during warmup and minimization.
+	Execs int64
+
+	// ExecsPerSec is the average rate of calls to the fuzz function over the
+	// whole run.
+	ExecsPerSec float64
+
+	// Elapsed is the wall clock time between loading the corpus and the end of
+	// the run.
+	Elapsed time.Duration
+
+	// Crashers is the list of crashing inputs found, in the order they
+	// were recorded.
+	Crashers []Crasher
+
+	// MinimizeDuration is the time workers spent minimizing crashers,
+	// including attempts that failed or timed out. It's what's counted
+	// against CoordinateFuzzingOpts.TotalMinimizeBudget.
+	MinimizeDuration time.Duration
+
+	// SeedCrashers is the list of seed corpus entries that crashed when they
+	// were tested before fuzzing. Path is the name of each entry.
+	SeedCrashers []Crasher
+
+	// FilteredCrashers is the number of crashes that were ignored because
+	// CoordinateFuzzingOpts.CrasherFilter returned false.
+	FilteredCrashers int64
+
+	// NewCoverageBits is the number of coverage bits found after the baseline
+	// coverage was gathered from the corpus.
+	NewCoverageBits int
+
+	// CorpusCoverageBits is the number of coverage bits set by the corpus
+	// that was loaded before fuzzing started, once it has been tested.
+	CorpusCoverageBits int
+
+	// CoverageCounters is the number of coverage counters in the test
+	// binary, and UncoveredCounters the number no input reached. They're
+	// only a measure of how much code fuzzing isn't reaching: the compiler
+	// doesn't record which function each counter is in, so the counters
+	// can't be mapped back to source.
+	CoverageCounters  int
+	UncoveredCounters int
+
+	// CorpusGrowth is the number of interesting values added to the corpus.
+	CorpusGrowth int64
+
+	// DedupedCoverage is the number of values that expanded coverage but
+	// were dropped because a value found at about the same time expanded
+	// it in the same way. See CoordinateFuzzingOpts.CoverageDedupWindow.
+	DedupedCoverage int64
+
+	// DroppedInteresting is the number of values that expanded coverage but
+	// weren't added to the corpus because of
+	// CoordinateFuzzingOpts.MaxInterestingPerSec.
+	DroppedInteresting int64
+
+	// SkippedCorpusFiles lists the files in the cache that were skipped
+	// because they couldn't be read or parsed, when
+	// CoordinateFuzzingOpts.SkipCorpusErrors is set.
+	SkippedCorpusFiles []string
+
+	// EvictedEntries is the number of entries removed from the cache to stay
+	// within CoordinateFuzzingOpts.MaxCorpusDiskBytes.
+	EvictedEntries int64
+
+	// CompactedEntries is the number of entries removed from the cache
+	// because other entries had all their coverage. See
+	// CoordinateFuzzingOpts.CompactInterval.
+	CompactedEntries int64
+
+	// GeneratedInputs is the number of inputs created with
+	// CoordinateFuzzingOpts.Generator and sent to workers.
+	GeneratedInputs int64
+
+	// DeflakeRuns is the number of times a value that expanded coverage was
+	// run again to confirm the coverage, and FlakyDeflakeRuns is the number
+	// of those runs that didn't reproduce it. A high ratio suggests the fuzz
+	// function is nondeterministic.
+	DeflakeRuns      int64
+	FlakyDeflakeRuns int64
+
+	// WorkerRestarts is the number of times worker processes were restarted,
+	// for example, after terminating unexpectedly.
+	WorkerRestarts int
+
+	// WorkerQuarantines is the number of times a worker was stopped for a
+	// while because too much of the new coverage it found was flaky. See
+	// CoordinateFuzzingOpts.FlakyWorkerRate.
+	WorkerQuarantines int
+
+	// SharedMemAcquisitions is the number of times the coordinator acquired
+	// the shared memory used to communicate with a worker, and SharedMemWait
+	// is the total time spent waiting to acquire it. A long wait may explain
+	// a low rate of calls to the fuzz function.
+	SharedMemAcquisitions int64
+	SharedMemWait         time.Duration
+
+	// MutatorStats counts the uses of each mutation operator that was applied
+	// at least once, in a fixed order.
+	MutatorStats []MutatorStat
+
+	// Workers breaks down the run by worker, in order of worker ID. It's only
+	// set with CoordinateFuzzingOpts.PerWorkerSummary.
+	Workers []WorkerSummary
+}
+
+// WorkerSummary describes the part of a run done by one worker. A worker
+// much slower than the others, or one that crashes or restarts much more
+// often, may point to a problem with the machine it runs on or with state the
+// fuzz function keeps across calls.
+type WorkerSummary struct {
+	// ID identifies the worker, from 0 to CoordinateFuzzingOpts.Parallel-1.
+	ID int
+
+	// Execs is the number of calls to the fuzz function the worker made, and
+	// ExecsPerSec the average rate of those calls over the whole run.
+	Execs       int64
+	ExecsPerSec float64
+
+	// Crashes is the number of crashing inputs the worker found.
+	Crashes int
+
+	// Restarts is the number of times the worker's process was restarted.
+	Restarts int
+
+	// AvgInputDuration is the average time a call to the fuzz function took
+	// in the worker.
+	AvgInputDuration time.Duration
+}
+
+// Crasher describes an input that caused the fuzz function to fail.
+type Crasher struct {
+	// Path is the file the input was written to. For seed corpus entries,
+	// Path is the name of the entry.
+	Path string
+
+	// Err is the error message reported for the input.
+	Err string
+
+	// Values is the decoded input. It's only set by ListCrashers.
+	Values []interface{}
+
+	// Found is when the crash was found, before the input was minimized.
+	// Sorting crashers by it shows the order they were found in, which helps
+	// triage: the first crash found for a bug is often the simplest. It's
+	// zero for seed corpus crashers and for crashers written by versions
+	// that didn't record it.
+	Found time.Time
+
+	// ReproduceRuns is the number of times the input was run again to measure
+	// how reliably it fails, and Reproduced is the number of those runs that
+	// failed. Both are zero if it wasn't measured. See
+	// CoordinateFuzzingOpts.CrasherReproduceRuns.
+	ReproduceRuns int
+	Reproduced    int
+
+	// Signature identifies the innermost functions in the stack trace of the
+	// crash, so crashers caused by the same bug have the same signature,
+	// whatever their input and error message. It's empty if no stack trace
+	// was reported. See GroupCrashers.
+	Signature string
+}
+
+// summary returns a Summary of the run so far. workers is the list of workers
+// started by CoordinateFuzzing; they must not be running.
+func (c *coordinator) summary(workers []*worker) Summary {
+	s := Summary{
+		Execs:              c.count,
+		Elapsed:            time.Since(c.startTime),
+		Crashers:           c.crashers,
+		MinimizeDuration:   c.crashMinimizeDuration,
+		SeedCrashers:       c.seedCrashers,
+		FilteredCrashers:   c.filteredCrashers,
+		CorpusGrowth:       c.interestingCount,
+		DroppedInteresting: c.droppedInteresting,
+		DedupedCoverage:    c.dedupedCoverage,
+		EvictedEntries:     c.evictedEntries,
+		CompactedEntries:   c.compactedEntries,
+		GeneratedInputs:    c.generatedCount,
+		DeflakeRuns:        c.deflakes,
+		FlakyDeflakeRuns:   c.flakes,
+		MutatorStats:       c.mutatorStats(),
+	}
+	for _, ferr := range c.corpus.skipped {
+		s.SkippedCorpusFiles = append(s.SkippedCorpusFiles, ferr.path)
+	}
+	if secs := s.Elapsed.Seconds(); secs > 0 {
+		s.ExecsPerSec = float64(c.count) / secs
+	}
+	if c.coverageMask != nil && !c.warmupRun() {
+		s.NewCoverageBits = countBits(c.coverageMask) - c.baselineCoverageBits
+		s.CorpusCoverageBits = c.baselineCoverageBits
+		reached, total := c.coverageUtilization()
+		s.CoverageCounters = total
+		s.UncoveredCounters = total - reached
+	}
+	for _, w := range workers {
+		s.WorkerRestarts += w.restarts
+		s.WorkerQuarantines += w.quarantines
+		s.SharedMemAcquisitions += w.memStats.acquisitions
+		s.SharedMemWait += w.memStats.wait
+		if c.opts.PerWorkerSummary {
+			s.Workers = append(s.Workers, w.summary(s.Elapsed))
+		}
+	}
+	return s
+}
+
+// summary returns a WorkerSummary of the worker's part of a run that has
+// lasted elapsed.
+func (w *worker) summary(elapsed time.Duration) WorkerSummary {
+	ws := WorkerSummary{
+		ID:       w.id,
+		Execs:    w.execs,
+		Crashes:  w.crashes,
+		Restarts: w.restarts,
+	}
+	if secs := elapsed.Seconds(); secs > 0 {
+		ws.ExecsPerSec = float64(w.execs) / secs
+	}
+	if w.execs > 0 {
+		ws.AvgInputDuration = w.execDuration / time.Duration(w.execs)
+	}
+	return ws
+}
+
+// recordCrasher adds a crasher that was written to the corpus to the list
+// reported in the summary.
+func (c *coordinator) recordCrasher(result *fuzzResult) {
+	c.crashers = append(c.crashers, Crasher{
+		Path:          result.entry.Path,
+		Err:           result.crasherMsg,
+		Found:         result.found,
+		ReproduceRuns: result.reproduceRuns,
+		Reproduced:    result.reproduced,
+		Signature:     result.signature,
+	})
+}
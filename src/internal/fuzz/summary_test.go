@@ -0,0 +1,47 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestOnFinish(t *testing.T) {
+	var calls int
+	var sum Summary
+	err := coordinateForTest(t, "crash", CoordinateFuzzingOpts{
+		Limit: 100000,
+		Seed: []CorpusEntry{
+			{Path: "seed#0", Data: marshalCorpusFile([]byte("seed")), Values: []interface{}{[]byte("seed")}, IsSeed: true},
+		},
+		OnFinish: func(s Summary) {
+			calls++
+			sum = s
+		},
+	})
+	if err == nil {
+		t.Fatal("fuzzing stopped without finding a crash")
+	}
+	if calls != 1 {
+		t.Fatalf("OnFinish called %d times; want 1", calls)
+	}
+	if sum.Execs == 0 || sum.Elapsed == 0 {
+		t.Errorf("summary reports %d execs in %v; want both non-zero", sum.Execs, sum.Elapsed)
+	}
+	if len(sum.Crashers) != 1 {
+		t.Fatalf("summary has %d crashers; want 1", len(sum.Crashers))
+	}
+	if c := sum.Crashers[0]; !strings.Contains(c.Err, "input contains '!'") {
+		t.Errorf("crasher error is %q; want the fuzz function's error", c.Err)
+	}
+	if _, err := os.Stat(sum.Crashers[0].Path); err != nil {
+		t.Errorf("crasher path: %v", err)
+	}
+	if sum.SharedMemAcquisitions == 0 {
+		t.Error("summary reports no shared memory acquisitions")
+	}
+}
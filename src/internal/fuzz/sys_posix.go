@@ -129,3 +129,28 @@ func isCrashSignal(signal os.Signal) bool {
 		return false
 	}
 }
+
+// setOpenFileLimit sets the soft limit on open file descriptors for the
+// current process to n.
+func setOpenFileLimit(n int) error {
+	var lim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &lim); err != nil {
+		return err
+	}
+	lim.Cur = uint64(n)
+	return syscall.Setrlimit(syscall.RLIMIT_NOFILE, &lim)
+}
+
+// processRSS returns the resident set size of the process with the given pid
+// in bytes. It's only implemented on Linux, where it's read from /proc.
+func processRSS(pid int) (int64, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/statm", pid))
+	if err != nil {
+		return 0, err
+	}
+	var size, resident int64
+	if _, err := fmt.Sscan(string(data), &size, &resident); err != nil {
+		return 0, fmt.Errorf("parsing /proc/%d/statm: %v", pid, err)
+	}
+	return resident * int64(os.Getpagesize()), nil
+}
@@ -42,3 +42,11 @@ func terminationSignal(err error) (os.Signal, bool) {
 func isCrashSignal(signal os.Signal) bool {
 	panic("not implemented")
 }
+
+func setOpenFileLimit(n int) error {
+	panic("not implemented")
+}
+
+func processRSS(pid int) (int64, error) {
+	panic("not implemented")
+}
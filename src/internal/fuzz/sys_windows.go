@@ -150,3 +150,13 @@ func terminationSignal(err error) (os.Signal, bool) {
 func isCrashSignal(signal os.Signal) bool {
 	panic("not implemented: no signals on windows")
 }
+
+// setOpenFileLimit is not implemented on Windows.
+func setOpenFileLimit(n int) error {
+	return fmt.Errorf("not implemented on windows")
+}
+
+// processRSS is not implemented on Windows.
+func processRSS(pid int) (int64, error) {
+	return 0, fmt.Errorf("not implemented on windows")
+}
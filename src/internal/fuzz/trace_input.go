@@ -0,0 +1,108 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// traceStderrSize is the number of bytes at the end of a worker process's
+// standard error that TraceInput returns. Tracing writes much more than a
+// fuzzing run normally does.
+const traceStderrSize = 64 << 20 // 64 MB
+
+// traceGODEBUG is the GODEBUG setting TraceInput adds to the worker process's
+// environment.
+const traceGODEBUG = "allocfreetrace=1"
+
+// InputTrace is the result of TraceInput.
+type InputTrace struct {
+	// Stderr is what the worker process wrote to standard error, including
+	// the trace. If it wrote more than traceStderrSize bytes, only the end is
+	// kept.
+	Stderr []byte
+
+	// Err is the error the fuzz function reported for the input, if any.
+	Err string
+}
+
+// TraceInput runs the fuzz function once with a single suspect input, in a
+// new worker process with heavy runtime tracing enabled (GODEBUG
+// allocfreetrace=1), and returns what the process wrote to standard error.
+// It's a debugging tool for one reproducer: tracing a whole fuzzing run
+// would produce far too much output to be useful.
+//
+// name is the file name of a corpus entry in opts.CorpusDir or opts.CacheDir,
+// for example, the name of a crasher. The worker process is started the same
+// way as by CoordinateFuzzing, with opts.WorkerEnv in its environment.
+func TraceInput(ctx context.Context, opts CoordinateFuzzingOpts, name string) (InputTrace, error) {
+	if name == "" || filepath.Base(name) != name {
+		return InputTrace{}, fmt.Errorf("invalid corpus file name %q", name)
+	}
+	if opts.Log == nil {
+		opts.Log = io.Discard
+	}
+	var entry CorpusEntry
+	for _, dir := range []string{opts.CorpusDir, opts.CacheDir} {
+		if dir == "" {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return InputTrace{}, err
+		}
+		vals, err := readCorpusData(data, opts.Types)
+		if err != nil {
+			return InputTrace{}, fmt.Errorf("%s: %v", path, err)
+		}
+		entry = CorpusEntry{Path: path, Data: data, Values: vals}
+		break
+	}
+	if entry.Path == "" {
+		return InputTrace{}, fmt.Errorf("corpus entry %q not found", name)
+	}
+
+	c := &coordinator{opts: opts}
+	binPath := os.Args[0]
+	args := append([]string{"-test.fuzzworker"}, os.Args[1:]...)
+	env := append(os.Environ(), opts.WorkerEnv...)
+	w, err := newWorker(c, "", binPath, args, env)
+	if err != nil {
+		return InputTrace{}, err
+	}
+	defer w.cleanup()
+	godebug := traceGODEBUG
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "GODEBUG=") {
+			godebug = strings.TrimPrefix(kv, "GODEBUG=") + "," + traceGODEBUG
+		}
+	}
+	w.extraEnv = []string{"GODEBUG=" + godebug}
+	w.stderrSize = traceStderrSize
+
+	if err := w.startAndPing(ctx); err != nil {
+		return InputTrace{}, err
+	}
+	_, resp, err := w.client.fuzz(ctx, entry, fuzzArgs{Warmup: true, Limit: 1})
+	// Stop the worker to make sure all its output was written.
+	stopErr := w.stop()
+	if ctx.Err() != nil {
+		return InputTrace{}, ctx.Err()
+	}
+	if err != nil && resp.Err == "" {
+		// The worker process terminated while calling the fuzz function.
+		resp.Err = fmt.Sprintf("fuzzing process terminated unexpectedly: %v", stopErr)
+	}
+	return InputTrace{Stderr: w.stderr.Bytes(), Err: resp.Err}, nil
+}
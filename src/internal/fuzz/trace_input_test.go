@@ -0,0 +1,53 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTraceInputBadEntry(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "corrupt"), []byte("not a corpus file"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	opts := CoordinateFuzzingOpts{
+		CorpusDir: dir,
+		Types:     []reflect.Type{reflect.TypeOf([]byte(nil))},
+	}
+	for _, name := range []string{"", "../corrupt", "missing", "corrupt"} {
+		if _, err := TraceInput(context.Background(), opts, name); err == nil {
+			t.Errorf("TraceInput(%q): got no error", name)
+		}
+	}
+}
+
+func TestTraceInput(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "crasher"), marshalCorpusFile([]byte("boom!")), 0666); err != nil {
+		t.Fatal(err)
+	}
+	opts := CoordinateFuzzingOpts{
+		CacheDir:  dir,
+		Types:     []reflect.Type{reflect.TypeOf([]byte(nil))},
+		WorkerEnv: []string{testFuzzFnEnv + "=crash"},
+	}
+	tr, err := TraceInput(context.Background(), opts, "crasher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(tr.Err, "input contains '!'") {
+		t.Errorf("got error %q; want the error for the input", tr.Err)
+	}
+	if !bytes.Contains(tr.Stderr, []byte("tracealloc(")) {
+		t.Errorf("worker process's stderr has no allocation trace:\n%s", tr.Stderr)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+const (
+	// defaultMinCoverageUtilization is the default for
+	// CoordinateFuzzingOpts.MinCoverageUtilization.
+	defaultMinCoverageUtilization = 0.01
+
+	// utilizationWarnMinCount is the number of calls to the fuzz function
+	// that must be made after warmup before the coordinator checks how much
+	// of the coverage bitmap has been reached.
+	utilizationWarnMinCount = 100000
+)
+
+// minCoverageUtilization returns the fraction of coverage counters below
+// which the coordinator warns, or 0 if it doesn't. See
+// CoordinateFuzzingOpts.MinCoverageUtilization.
+func (c *coordinator) minCoverageUtilization() float64 {
+	switch u := c.opts.MinCoverageUtilization; {
+	case u < 0:
+		return 0
+	case u == 0:
+		return defaultMinCoverageUtilization
+	default:
+		return u
+	}
+}
+
+// coverageUtilization returns the number of coverage counters any input has
+// reached, and the total number of counters.
+func (c *coordinator) coverageUtilization() (reached, total int) {
+	for _, b := range c.coverageMask {
+		if b != 0 {
+			reached++
+		}
+	}
+	return reached, len(c.coverageMask)
+}
+
+// checkCoverageUtilization prints a warning, once, if after substantial
+// fuzzing the inputs have reached only a small fraction of the coverage
+// counters. It's called periodically.
+func (c *coordinator) checkCoverageUtilization() {
+	threshold := c.minCoverageUtilization()
+	if c.utilizationWarned || threshold == 0 || len(c.coverageMask) == 0 || c.warmupRun() ||
+		c.count-c.warmupCount < utilizationWarnMinCount {
+		return
+	}
+	reached, total := c.coverageUtilization()
+	if float64(reached) >= threshold*float64(total) {
+		return
+	}
+	c.logf(LogWarn, "warning: after %d execs, inputs have reached only %d of %d coverage counters (%.2f%%); the corpus may not be diverse enough, or much of the instrumented code can't be reached from the fuzz function. Consider adding seed inputs with f.Add, and check that the fuzz function passes its inputs to the code being tested\n", c.count, reached, total, 100*float64(reached)/float64(total))
+	c.utilizationWarned = true
+}
@@ -0,0 +1,57 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCheckCoverageUtilization(t *testing.T) {
+	var log bytes.Buffer
+	c := &coordinator{
+		opts:         CoordinateFuzzingOpts{Log: &log},
+		coverageMask: make([]byte, 1000),
+		warmupCount:  10,
+	}
+	c.coverageMask[0] = 0x3
+	c.coverageMask[1] = 0x1
+
+	c.count = c.warmupCount + utilizationWarnMinCount - 1
+	c.checkCoverageUtilization()
+	if log.Len() != 0 {
+		t.Fatalf("warned before enough calls after warmup: %s", log.String())
+	}
+
+	if s := c.summary(nil); s.CoverageCounters != 1000 || s.UncoveredCounters != 998 {
+		t.Errorf("summary reports %d uncovered of %d counters; want 998 of 1000", s.UncoveredCounters, s.CoverageCounters)
+	}
+
+	c.count++
+	c.checkCoverageUtilization()
+	if !strings.Contains(log.String(), "reached only 2 of 1000 coverage counters") {
+		t.Fatalf("got log %q; want a warning that 2 of 1000 counters were reached", log.String())
+	}
+	log.Reset()
+	c.checkCoverageUtilization()
+	if log.Len() != 0 {
+		t.Errorf("warned twice: %s", log.String())
+	}
+
+	// Reaching enough of the bitmap, or a negative threshold, means no
+	// warning.
+	for _, opts := range []CoordinateFuzzingOpts{
+		{Log: &log, MinCoverageUtilization: 0.002},
+		{Log: &log, MinCoverageUtilization: -1},
+	} {
+		c.opts = opts
+		c.utilizationWarned = false
+		c.checkCoverageUtilization()
+		if log.Len() != 0 {
+			t.Errorf("MinCoverageUtilization %v: got warning %s", opts.MinCoverageUtilization, log.String())
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"fmt"
+	"time"
+)
+
+// inProcessWarmupMaxEntries is the largest corpus the coordinator tests in its
+// own process when CoordinateFuzzingOpts.WarmupFn is set. Larger corpora are
+// tested by workers, in parallel.
+const inProcessWarmupMaxEntries = 32
+
+// warmupInProcess tests the corpus queued for warmup by calling
+// opts.WarmupFn in the coordinator's process, then gathers the baseline
+// coverage from the results the same way it would from workers' results.
+// This saves the cost of sending each entry to a worker when there are only
+// a few.
+//
+// warmupInProcess returns false, leaving the corpus queued for workers, if
+// opts.WarmupFn isn't set, if the corpus is too large, if testing it would
+// reach opts.Limit, or if any entry fails, so that workers test the corpus
+// again and report failures as usual.
+func (c *coordinator) warmupInProcess() bool {
+	fn := c.opts.WarmupFn
+	if fn == nil || !c.warmupRun() || c.warmupInputCount > inProcessWarmupMaxEntries {
+		return false
+	}
+	if c.opts.Limit > 0 && int64(c.warmupInputCount) >= c.opts.Limit {
+		// The run ends during warmup, which workers' results detect.
+		return false
+	}
+	var entries []CorpusEntry
+	for {
+		e, ok := c.inputQueue.dequeue()
+		if !ok {
+			break
+		}
+		entries = append(entries, e.(CorpusEntry))
+	}
+	// Workers test the whole corpus again if an entry fails, so calls made
+	// here aren't counted then, and don't use up opts.Limit.
+	count, warmupCount := c.count, c.warmupCount
+	requeue := func() bool {
+		c.count, c.warmupCount = count, warmupCount
+		for _, e := range entries {
+			c.inputQueue.enqueue(e)
+		}
+		return false
+	}
+
+	type entryCoverage struct {
+		path string
+		cov  []byte
+	}
+	var covs []entryCoverage
+	mask := append([]byte(nil), c.coverageMask...)
+	bestFeedback := c.bestFeedback
+	start := time.Now()
+	for _, e := range entries {
+		if e.Values == nil {
+			data, err := CorpusEntryData(e)
+			if err != nil {
+				return requeue()
+			}
+			if e.Values, err = unmarshalCorpusFile(data); err != nil {
+				return requeue()
+			}
+		}
+		takeFeedback() // discard any score reported outside the fuzz function
+		err := callWarmupFn(fn, e)
+		c.count++
+		c.warmupCount++
+		if err != nil {
+			c.logf(LogDebug, "DEBUG corpus entry %s failed during in-process warmup; testing the corpus in workers: %v\n", e.Path, err)
+			return requeue()
+		}
+		if v, ok := takeFeedback(); ok && improvesFeedback(bestFeedback, v) {
+			bestFeedback = &v
+		}
+		// Like a worker, only keep coverage that's new since the entries
+		// tested before.
+		if c.coverageMask != nil && activeFeedback.NewBits(mask, coverageSnapshot) > 0 {
+			cov := append([]byte(nil), coverageSnapshot...)
+			covs = append(covs, entryCoverage{e.Path, cov})
+			activeFeedback.Merge(mask, cov)
+		}
+	}
+	c.duration += time.Since(start)
+
+	c.bestFeedback = bestFeedback
+	for _, ec := range covs {
+		c.recordEntryCoverage(ec.path, ec.cov)
+		c.reportNewCoverage(ec.cov, ec.path)
+		c.updateCoverage(ec.cov)
+		if c.reachesFocus(ec.cov) {
+			c.focused[ec.path] = true
+		}
+	}
+	c.warmupInputLeft = 0
+	c.baselineCoverageBits = countBits(c.coverageMask)
+	c.logf(LogInfo, "fuzz: elapsed: %s, tested %d corpus entries in process, now fuzzing with %d workers\n", c.elapsed(), len(entries), c.opts.Parallel)
+	return true
+}
+
+// callWarmupFn calls fn with e. A panic is returned as an error.
+func callWarmupFn(fn func(CorpusEntry) error, e CorpusEntry) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(e)
+}
@@ -0,0 +1,59 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestWarmupInProcess(t *testing.T) {
+	var calls int
+	fail := ""
+	opts := CoordinateFuzzingOpts{
+		Log:   io.Discard,
+		Types: []reflect.Type{reflect.TypeOf([]byte(nil))},
+		Seed: []CorpusEntry{
+			{Path: "a", Values: []interface{}{[]byte("a")}},
+			{Path: "b", Values: []interface{}{[]byte("b")}},
+		},
+		WarmupFn: func(e CorpusEntry) error {
+			calls++
+			if string(e.Values[0].([]byte)) == fail {
+				return errors.New("boom")
+			}
+			return nil
+		},
+	}
+
+	c, err := newCoordinator(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.warmupInProcess() {
+		t.Fatal("corpus was not tested in process")
+	}
+	if calls != 2 || c.count != 2 || c.warmupRun() {
+		t.Errorf("after warmup: %d calls, count %d, warmupRun() = %v; want 2, 2, false", calls, c.count, c.warmupRun())
+	}
+
+	// If an entry fails, the corpus is left for workers to test.
+	fail = "b"
+	c, err = newCoordinator(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.warmupInProcess() {
+		t.Fatal("corpus with a failing entry was tested in process")
+	}
+	if !c.warmupRun() || c.inputQueue.len != len(opts.Seed) {
+		t.Errorf("corpus was not left queued for workers: warmupRun() = %v, %d entries queued", c.warmupRun(), c.inputQueue.len)
+	}
+	if c.count != 0 || c.warmupCount != 0 {
+		t.Errorf("after failed warmup: count %d, warmupCount %d; want 0, 0, since workers test the corpus again", c.count, c.warmupCount)
+	}
+}
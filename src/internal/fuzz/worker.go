@@ -8,15 +8,23 @@ import (
 	"bytes"
 	"context"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/maphash"
 	"io"
 	"io/ioutil"
+	"math/rand"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
@@ -29,6 +37,16 @@ const (
 	// responding to the coordinator before being stopped.
 	workerTimeoutDuration = 1 * time.Second
 
+	// closeDrainTimeout is the amount of time workerClient.Close waits for the
+	// worker process to close its end of fuzz_out. It's long enough for
+	// worker.stop to interrupt, then kill, a worker process that doesn't exit.
+	closeDrainTimeout = 2 * workerTimeoutDuration
+
+	// spliceInterval is how often the coordinator asks a worker to splice the
+	// input it's fuzzing with another corpus entry. One of every spliceInterval
+	// inputs is spliced.
+	spliceInterval = 8
+
 	// workerExitCode is used as an exit code by fuzz worker processes after an internal error.
 	// This distinguishes internal errors from uncontrolled panics and other crashes.
 	// Keep in sync with internal/fuzz.workerExitCode.
@@ -37,6 +55,34 @@ const (
 	// workerSharedMemSize is the maximum size of the shared memory file used to
 	// communicate with workers. This limits the size of fuzz inputs.
 	workerSharedMemSize = 100 << 20 // 100 MB
+
+	// crasherReproduceTimeout is the most time spent running a crasher again
+	// with CoordinateFuzzingOpts.CrasherReproduceRuns.
+	crasherReproduceTimeout = 10 * time.Second
+
+	// workerRecentInputs is the number of inputs a worker ran last that the
+	// coordinator saves when the worker process terminates for a reason it
+	// can't attribute to a single input.
+	workerRecentInputs = 8
+
+	// workerStderrTailSize is the number of bytes at the end of a worker
+	// process's standard error that the coordinator keeps.
+	workerStderrTailSize = 64 << 10 // 64 KB
+
+	// defaultRSSCheckInterval is how often the coordinator checks the memory
+	// used by each worker process when CoordinateFuzzingOpts.WorkerRSSLimit
+	// is set and WorkerRSSCheckInterval is not.
+	defaultRSSCheckInterval = 1 * time.Second
+
+	// flakyWorkerMinDeflakes is the number of attempts to deflake new coverage
+	// a worker process must make before its rate of flaky coverage is compared
+	// with CoordinateFuzzingOpts.FlakyWorkerRate.
+	flakyWorkerMinDeflakes = 20
+
+	// defaultFlakyWorkerQuarantine is how long a worker is stopped when
+	// CoordinateFuzzingOpts.FlakyWorkerRate is exceeded and
+	// FlakyWorkerQuarantine is not set.
+	defaultFlakyWorkerQuarantine = 10 * time.Second
 )
 
 // worker manages a worker process running a test binary. The worker object
@@ -44,12 +90,14 @@ const (
 // workerClient is used by the coordinator to send RPCs to the worker process,
 // which handles them with workerServer.
 type worker struct {
-	dir     string   // working directory, same as package directory
-	binPath string   // path to test executable
-	args    []string // arguments for test executable
-	env     []string // environment for test executable
+	dir     string      // working directory, same as package directory
+	binPath string      // path to test executable
+	binInfo os.FileInfo // test executable when the worker was created; see checkBinary
+	args    []string    // arguments for test executable
+	env     []string    // environment for test executable
 
 	coordinator *coordinator
+	id          int // index of the worker, used to derive its mutator's seed
 
 	memMu chan *sharedMem // mutex guarding shared memory with worker; persists across processes.
 
@@ -58,18 +106,55 @@ type worker struct {
 	waitErr     error         // last error returned by wait, set before termC is closed.
 	interrupted bool          // true after stop interrupts a running worker.
 	termC       chan struct{} // closed by wait when worker process terminates
+
+	started  bool     // true after the worker process has been started once.
+	restarts int      // number of times the worker process was restarted.
+	extraEnv []string // environment set after env, for example, to minimize a crasher.
+
+	// procs and godebug are the values of GOMAXPROCS and GODEBUG reported by
+	// the running worker process.
+	procs   int
+	godebug string
+
+	// deflakes and flakes count the attempts the current worker process made
+	// to deflake new coverage, and how many of them failed to reproduce it.
+	// quarantines is the number of times the worker was stopped because too
+	// many failed; see flakyRateExceeded.
+	deflakes, flakes int64
+	quarantines      int
+
+	// memStats records time the coordinator spent waiting for shared memory
+	// across all processes started by this worker.
+	memStats memWaitStats
+
+	// execs, execDuration, and crashes are totals of the results the worker
+	// sent to the coordinator, for the per-worker summary. See recordResult.
+	execs        int64
+	execDuration time.Duration
+	crashes      int
+
+	// stderr holds the end of the current worker process's standard error,
+	// which is otherwise discarded. It's used to recognize how the process
+	// terminated; see deadlocked. It keeps stderrSize bytes, or
+	// workerStderrTailSize if stderrSize is zero.
+	stderr     *tailBuffer
+	stderrSize int
 }
 
 func newWorker(c *coordinator, dir, binPath string, args, env []string) (*worker, error) {
-	mem, err := sharedMemTempFile(workerSharedMemSize)
+	mem, err := sharedMemTempFile(c.opts.SharedMemDir, workerSharedMemSize)
 	if err != nil {
 		return nil, err
 	}
 	memMu := make(chan *sharedMem, 1)
 	memMu <- mem
+	// If the binary can't be found, starting it fails with a clearer error
+	// than checkBinary could give.
+	binInfo, _ := os.Stat(binPath)
 	return &worker{
 		dir:         dir,
 		binPath:     binPath,
+		binInfo:     binInfo,
 		args:        args,
 		env:         env[:len(env):len(env)], // copy on append to ensure workers don't overwrite each other.
 		coordinator: c,
@@ -98,10 +183,30 @@ func (w *worker) cleanup() error {
 // those inputs to the worker process, then passes the results back to
 // the coordinator.
 func (w *worker) coordinate(ctx context.Context) error {
+	var rssC <-chan time.Time
+	if w.coordinator.opts.WorkerRSSLimit > 0 {
+		interval := w.coordinator.opts.WorkerRSSCheckInterval
+		if interval <= 0 {
+			interval = defaultRSSCheckInterval
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		rssC = ticker.C
+	}
+
 	// Main event loop.
 	for {
-		// Start or restart the worker if it's not running.
+		// Start or restart the worker if it's not running. A restarted process
+		// has nothing to warm up: worker processes don't load the corpus, and
+		// each call to fuzz carries the coordinator's coverage mask, so the
+		// first call after a restart already knows all the coverage found so
+		// far. There's no corpus state worth caching across restarts.
 		if !w.isRunning() {
+			if w.started {
+				w.restarts++
+				atomic.AddInt64(&w.coordinator.workerRestarts, 1)
+			}
+			w.started = true
 			if err := w.startAndPing(ctx); err != nil {
 				return err
 			}
@@ -116,6 +221,20 @@ func (w *worker) coordinate(ctx context.Context) error {
 			}
 			return ctx.Err()
 
+		case <-rssC:
+			// Restart the worker if it's using too much memory. It's waiting
+			// for input, so no call to the fuzz function is interrupted.
+			rss, err := processRSS(w.cmd.Process.Pid)
+			if err != nil || rss <= w.coordinator.opts.WorkerRSSLimit {
+				// If the process can't be inspected, it probably terminated;
+				// termC reports that.
+				continue
+			}
+			w.coordinator.logf(LogInfo, "fuzz: restarting fuzzing process using %d bytes of memory, more than the limit of %d bytes\n", rss, w.coordinator.opts.WorkerRSSLimit)
+			if err := w.stop(); err != nil && !w.interrupted && !isInterruptError(err) {
+				return err
+			}
+
 		case <-w.termC:
 			// Worker process terminated unexpectedly while waiting for input.
 			err := w.stop()
@@ -148,31 +267,72 @@ func (w *worker) coordinate(ctx context.Context) error {
 		case input := <-w.coordinator.inputC:
 			// Received input from coordinator.
 			args := fuzzArgs{
-				Limit:        input.limit,
-				Timeout:      input.timeout,
-				Warmup:       input.warmup,
-				CoverageData: input.coverageData,
+				Limit:           input.limit,
+				Timeout:         input.timeout,
+				Warmup:          input.warmup,
+				CoverageData:    input.coverageData,
+				CheckGoroutines: input.checkGoroutines,
+				BestFeedback:    input.bestFeedback,
+				InputTimeout:    w.coordinator.opts.InputTimeout,
+				MaxInputSize:    w.coordinator.opts.MaxInputSize,
+				Replay:          input.replay,
+			}
+			splicePath := ""
+			if input.splice != nil {
+				// Splicing is best effort: if the other entry can't be read,
+				// fuzz the input alone.
+				if data, err := CorpusEntryData(*input.splice); err == nil {
+					args.Splice = data
+					splicePath = input.splice.Path
+				}
 			}
 			entry, resp, err := w.client.fuzz(ctx, input.entry, args)
+			if rec := w.coordinator.sessionRec; rec != nil && !input.warmup && input.evalC == nil && resp.Count > 0 {
+				var splice *CorpusEntry
+				if args.Splice != nil {
+					splice = &CorpusEntry{Path: splicePath, Data: args.Splice}
+				}
+				rec.record(input.entry, splice, resp.Count, sessionCall{
+					RandState: resp.RandState,
+					RandInc:   resp.RandInc,
+					Deflakes:  resp.DeflakedAt,
+				})
+			}
+			w.coordinator.logf(LogDebug, "DEBUG worker %d fuzz call returned, count: %d, duration: %s, error: %v\n", w.id, resp.Count, resp.TotalDuration, err)
+			w.deflakes += resp.Deflakes
+			w.flakes += resp.Flakes
 			canMinimize := true
+			var crashSignal os.Signal
 			if err != nil {
 				// Error communicating with worker.
+				// If the worker closed fuzz_out cleanly, it stopped serving calls,
+				// but that doesn't mean it terminated. Give it a moment to exit so
+				// the checks below see how it terminated, if it did.
+				lingering := errors.Is(err, errPipeClosed) && !w.waitForTermination(workerTimeoutDuration)
 				w.stop()
 				if ctx.Err() != nil {
 					// Timeout or interruption.
 					return ctx.Err()
 				}
+				if lingering {
+					// The worker stopped responding without terminating, so the
+					// input didn't crash it. Don't record a crasher.
+					return fmt.Errorf("fuzzing process stopped responding without terminating: %v", err)
+				}
 				if w.interrupted {
 					// Communication error before we stopped the worker.
 					// Report an error, but don't record a crasher.
 					return fmt.Errorf("communicating with fuzzing process: %v", err)
 				}
-				if w.waitErr == nil || isInterruptError(w.waitErr) {
+				if (w.waitErr == nil && !w.exitedInFuzzFn()) || isInterruptError(w.waitErr) {
 					// Worker stopped, either by exiting with status 0 or after being
 					// interrupted with a signal (not sent by coordinator). See comment in
 					// termC case above.
 					//
 					// Since we expect I/O errors around interrupts, ignore this error.
+					// An interrupted worker finishes its call to the fuzz function
+					// before exiting though, so if it exited with status 0 during
+					// the call, the fuzz function made it exit; that's a crash below.
 					return nil
 				}
 				if sig, ok := terminationSignal(w.waitErr); ok && !isCrashSignal(sig) {
@@ -180,14 +340,36 @@ func (w *worker) coordinate(ctx context.Context) error {
 					// specific input to the fuzz function. For example, on Linux,
 					// the kernel (OOM killer) may send SIGKILL to a process using a lot
 					// of memory. Or the shell might send SIGHUP when the terminal
-					// is closed. Don't record a crasher.
-					return fmt.Errorf("fuzzing process terminated by unexpected signal; no crash will be recorded: %v", w.waitErr)
+					// is closed. Don't record a crasher, but save the last few
+					// inputs so the user can see what the worker was doing.
+					err := fmt.Errorf("fuzzing process terminated by unexpected signal; no crash will be recorded: %v", w.waitErr)
+					if dir, serr := w.saveRecentInputs(args, workerRecentInputs); serr != nil {
+						err = fmt.Errorf("%w\nsaving last inputs: %v", err, serr)
+					} else if dir != "" {
+						err = fmt.Errorf("%w\nthe last inputs it ran were saved in %s", err, dir)
+					}
+					return err
 				}
 				// Unexpected termination. Set error message and fall through.
 				// We'll restart the worker on the next iteration.
-				// Don't attempt to minimize this since it crashed the worker.
-				resp.Err = fmt.Sprintf("fuzzing process terminated unexpectedly: %v", w.waitErr)
+				// Don't attempt to minimize this in a worker since it crashed
+				// the worker. If a signal terminated it, the coordinator can
+				// minimize it instead; see minimizeSignal.
 				canMinimize = false
+				if sig, ok := terminationSignal(w.waitErr); ok {
+					crashSignal = sig
+					canMinimize = true
+				}
+				switch {
+				case w.waitErr == nil:
+					resp.Err = "fuzzing process exited with status 0 while calling the fuzz function; the fuzz function or code it calls may have called os.Exit"
+				case w.deadlocked():
+					resp.Err = fmt.Sprintf("fuzzing process deadlocked: all goroutines are asleep: %v", w.waitErr)
+				case w.exhaustedThreads():
+					resp.Err = fmt.Sprintf("fuzzing process exceeded the limit of %d threads: %v", w.coordinator.opts.WorkerMaxThreads, w.waitErr)
+				default:
+					resp.Err = fmt.Sprintf("fuzzing process terminated unexpectedly: %v", w.waitErr)
+				}
 			}
 			result := fuzzResult{
 				limit:         input.limit,
@@ -198,32 +380,131 @@ func (w *worker) coordinate(ctx context.Context) error {
 				crasherMsg:    resp.Err,
 				coverageData:  resp.CoverageData,
 				canMinimize:   canMinimize,
+				warning:       resp.Warning,
+				feedback:      resp.Feedback,
+				parseErr:      resp.ParseErr,
+				crashCoverage: resp.CrashCoverage,
+				inputPath:     input.entry.Path,
+
+				deflakes:        resp.Deflakes,
+				flakes:          resp.Flakes,
+				skippedOversize: resp.SkippedOversize,
+				mutatorOps:      resp.MutatorOps,
+				crashSignal:     crashSignal,
+			}
+			if result.parseErr != "" {
+				result.entry = input.entry
+			}
+			if w.coordinator.opts.RecordDerivation && err == nil && resp.Err == "" && !input.warmup && !input.generated {
+				result.derivation = &entryDerivation{
+					Parent:    input.entry.Path,
+					Splice:    splicePath,
+					RandState: resp.RandState,
+					RandInc:   resp.RandInc,
+					Mutations: resp.Count,
+				}
+			}
+			if result.crasherMsg != "" {
+				result.env = w.runEnv()
+				if err != nil && w.stderr != nil {
+					// The process terminated, so its stack trace, if it
+					// printed one, is in its output rather than resp.Err.
+					result.signature = crashSignature(string(w.stderr.Bytes()))
+				}
+				// Crashers that will be minimized are measured once they
+				// are, below.
+				if !input.warmup && (!result.canMinimize || !w.coordinator.minimizationAllowed) {
+					if err := w.measureReproduce(ctx, &result); err != nil {
+						return err
+					}
+				}
 			}
+			if input.evalC != nil {
+				input.evalC <- result
+				continue
+			}
+			w.recordResult(result, true)
 			w.coordinator.resultC <- result
+			if w.flakyRateExceeded() {
+				if err := w.quarantine(ctx); err != nil {
+					return err
+				}
+			}
 
 		case input := <-w.coordinator.minimizeC:
 			// Received input to minimize from coordinator.
-			result, err := w.minimize(ctx, input)
+			// A crasher may only reproduce with the settings it was found with,
+			// so restart the worker process with those settings if they differ.
+			if err := w.restartWithEnv(ctx, input.env); err != nil {
+				return err
+			}
+			start := time.Now()
+			var result fuzzResult
+			var err error
+			if input.crashSignal != nil {
+				result, err = w.minimizeSignal(ctx, input)
+			} else {
+				result, err = w.minimize(ctx, input)
+				if err == nil && input.crasherMsg != "" && w.coordinator.opts.VerifyMinimizedCrashers && result.entry.Path != input.entry.Path {
+					err = w.verifyMinimized(ctx, input, &result)
+				}
+			}
+			if err == nil && input.crasherMsg != "" {
+				err = w.measureReproduce(ctx, &result)
+			}
 			if err != nil {
-				// Error minimizing. Send back the original input. If it didn't cause
+				// Error minimizing. Send back the original input, or a partially
+				// minimized crasher saved before the error. If it didn't cause
 				// an error before, report it as causing an error now.
 				// TODO: double-check this is handled correctly when
 				// implementing -keepfuzzing.
+				entry := input.entry
+				if input.crasherMsg != "" && result.entry.Data != nil {
+					entry = result.entry
+				}
 				result = fuzzResult{
-					entry:       input.entry,
+					entry:       entry,
 					crasherMsg:  input.crasherMsg,
 					canMinimize: false,
 					limit:       input.limit,
+					minimized:   true,
 				}
 				if result.crasherMsg == "" {
 					result.crasherMsg = err.Error()
 				}
 			}
+			if input.crasherMsg != "" {
+				// Charge the whole attempt to opts.TotalMinimizeBudget,
+				// including attempts that failed or timed out.
+				result.minimizeDuration = time.Since(start)
+			}
+			result.inputPath = input.entry.Path
+			result.env = input.env
+			result.crashCoverage = input.crashCoverage
+			if w.extraEnv != nil {
+				// Go back to the usual settings for fuzzing. The worker is
+				// restarted at the top of the loop.
+				if w.isRunning() {
+					w.stop()
+				}
+				w.extraEnv = nil
+			}
+			w.recordResult(result, false)
 			w.coordinator.resultC <- result
 		}
 	}
 }
 
+// recordResult adds result to the worker's totals. A crash is counted when
+// it's found, not again when it's minimized.
+func (w *worker) recordResult(result fuzzResult, found bool) {
+	w.execs += result.count
+	w.execDuration += result.totalDuration
+	if found && result.crasherMsg != "" {
+		w.crashes++
+	}
+}
+
 // minimize tells a worker process to attempt to find a smaller value that
 // either causes an error (if we started minimizing because we found an input
 // that causes an error) or preserves new coverage (if we started minimizing
@@ -236,29 +517,78 @@ func (w *worker) minimize(ctx context.Context, input fuzzMinimizeInput) (min fuz
 	}
 
 	args := minimizeArgs{
-		Limit:        input.limit,
-		Timeout:      input.timeout,
-		KeepCoverage: input.keepCoverage,
+		Limit:              input.limit,
+		Timeout:            input.timeout,
+		KeepCoverage:       input.keepCoverage,
+		InputTimeout:       w.coordinator.opts.InputTimeout,
+		FewestCoverageBits: w.coordinator.opts.MinimizeCoverageBits,
+		CoverageSubset:     w.coordinator.opts.MinimizeCoverageSubset,
 	}
 	entry, resp, err := w.client.minimize(ctx, input.entry, args)
+	w.coordinator.logf(LogDebug, "DEBUG worker %d minimize call returned, count: %d, duration: %s, stop reason: %s, error: %v\n", w.id, resp.Count, resp.Duration, resp.StopReason, err)
 	if err != nil {
 		// Error communicating with worker.
+		// The worker saves each smaller input it verifies in shared memory, so
+		// if it got that far, entry is smaller than the original but still
+		// interesting for the same reason. Otherwise, fall back to the original.
+		partial := entry.Data != nil && entry.Path != input.entry.Path
+		if !partial {
+			entry = input.entry
+		}
 		w.stop()
+		if ctx.Err() != nil {
+			// The call returned as soon as ctx was done, while the worker
+			// process was still minimizing, so it may have saved a smaller
+			// input since. Now that it has stopped, use the last one.
+			entry, partial = input.entry, false
+			if e, ok := w.minimizeCheckpoint(input.entry); ok {
+				entry, partial = e, true
+			}
+		}
+		if partial && input.crasherMsg != "" {
+			w.coordinator.logf(LogInfo, "fuzz: minimization stopped early; using input partially minimized from %d to %d bytes\n", len(input.entry.Data), len(entry.Data))
+		}
 		if ctx.Err() != nil || w.interrupted || isInterruptError(w.waitErr) {
 			// Worker was interrupted, possibly by the user pressing ^C.
 			// Normally, workers can handle interrupts and timeouts gracefully and
 			// will return without error. An error here indicates the worker
 			// may not have been in a good state, but the error won't be meaningful
-			// to the user. Just return the original crasher without logging anything.
+			// to the user. Just return the crasher without logging anything.
 			return fuzzResult{
-				entry:        input.entry,
+				entry:        entry,
 				crasherMsg:   input.crasherMsg,
 				coverageData: input.keepCoverage,
 				canMinimize:  false,
 				limit:        input.limit,
+				minimized:    true,
 			}, nil
 		}
-		return fuzzResult{}, fmt.Errorf("fuzzing process terminated unexpectedly while minimizing: %w", w.waitErr)
+		var min fuzzResult
+		if partial {
+			min.entry = entry
+		}
+		if w.deadlocked() {
+			return min, fmt.Errorf("fuzzing process deadlocked while minimizing: %w", w.waitErr)
+		}
+		return min, fmt.Errorf("fuzzing process terminated unexpectedly while minimizing: %w", w.waitErr)
+	}
+
+	if resp.ParseErr != "" {
+		// The worker couldn't decode the input, so it can't be minimized.
+		// Return a crasher as it is so it's still recorded. The coordinator
+		// skips other inputs.
+		result := fuzzResult{
+			entry:       input.entry,
+			crasherMsg:  input.crasherMsg,
+			canMinimize: false,
+			limit:       input.limit,
+			count:       resp.Count,
+			minimized:   true,
+		}
+		if input.crasherMsg == "" {
+			result.parseErr = resp.ParseErr
+		}
+		return result, nil
 	}
 
 	if input.crasherMsg != "" && resp.Err == "" && !resp.Success {
@@ -273,9 +603,352 @@ func (w *worker) minimize(ctx context.Context, input fuzzMinimizeInput) (min fuz
 		limit:         input.limit,
 		count:         resp.Count,
 		totalDuration: resp.Duration,
+		minimized:     true,
+	}, nil
+}
+
+// verifyMinimized runs min, a crasher minimized from input, once in a newly
+// started worker process, since the process that minimized it may have
+// accumulated state the crash depends on. If min doesn't cause an error
+// there, verifyMinimized replaces it with input, as it was found, and sets
+// min.unverified. The signal crashers minimizeSignal returns don't need this:
+// each candidate runs in a fresh process.
+func (w *worker) verifyMinimized(ctx context.Context, input fuzzMinimizeInput, min *fuzzResult) error {
+	if w.isRunning() {
+		w.stop()
+	}
+	if err := w.startAndPing(ctx); err != nil {
+		return err
+	}
+	min.count++
+	args := fuzzArgs{Warmup: true, Limit: 1, InputTimeout: w.coordinator.opts.InputTimeout}
+	_, resp, err := w.client.fuzz(ctx, min.entry, args)
+	if err != nil {
+		// If the input terminated the worker process, it reproduces the
+		// crash, though perhaps not with the same message. Any other error
+		// says nothing about the input, so keep the input that's known to
+		// crash.
+		crashed := w.stopAfterError(err)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if crashed {
+			return nil
+		}
+	} else if resp.Err != "" {
+		return nil
+	}
+	min.entry = input.entry
+	min.crasherMsg = input.crasherMsg
+	min.unverified = true
+	return nil
+}
+
+// measureReproduce runs the crasher in result again up to
+// opts.CrasherReproduceRuns times, in a newly started worker process, and
+// records how many of the runs failed. The process is restarted after each
+// run that terminates it. Measuring stops early after
+// crasherReproduceTimeout; runs that were cut short, or that ended with an
+// error unrelated to the input, aren't counted.
+func (w *worker) measureReproduce(ctx context.Context, result *fuzzResult) error {
+	runs := w.coordinator.opts.CrasherReproduceRuns
+	if runs <= 0 {
+		return nil
+	}
+	mctx, cancel := context.WithTimeout(ctx, crasherReproduceTimeout)
+	defer cancel()
+	if w.isRunning() {
+		w.stop()
+	}
+	args := fuzzArgs{Warmup: true, Limit: 1, InputTimeout: w.coordinator.opts.InputTimeout}
+	for i := 0; i < runs; i++ {
+		if !w.isRunning() {
+			if err := w.startAndPing(mctx); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if mctx.Err() != nil {
+					break
+				}
+				return err
+			}
+		}
+		_, resp, err := w.client.fuzz(mctx, result.entry, args)
+		crashed := resp.Err != ""
+		if err != nil {
+			crashed = w.stopAfterError(err)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if mctx.Err() != nil {
+				break
+			}
+			if !crashed {
+				continue
+			}
+		}
+		result.count++
+		result.reproduceRuns++
+		if crashed {
+			result.reproduced++
+		}
+	}
+	return nil
+}
+
+// stopAfterError stops the worker process after err, an error communicating
+// with it while it ran an input, and returns whether the input crashed it.
+// This follows the checks coordinate makes before recording a crasher:
+// a process that stopped responding without terminating, that was
+// interrupted, that exited cleanly outside the fuzz function, or that was
+// terminated by a signal an input doesn't cause, didn't crash.
+func (w *worker) stopAfterError(err error) bool {
+	lingering := errors.Is(err, errPipeClosed) && !w.waitForTermination(workerTimeoutDuration)
+	w.stop()
+	if lingering || w.interrupted {
+		return false
+	}
+	if (w.waitErr == nil && !w.exitedInFuzzFn()) || isInterruptError(w.waitErr) {
+		return false
+	}
+	if sig, ok := terminationSignal(w.waitErr); ok && !isCrashSignal(sig) {
+		return false
+	}
+	return true
+}
+
+// minimizeSignal minimizes a crasher that terminated the worker process with
+// a signal, like SIGSEGV. The worker process can't report such a crash, so
+// the coordinator drives minimization instead: it makes each candidate and
+// runs it once in the worker process, restarting the process after each
+// crash. A candidate is kept only if it terminates the process with the same
+// signal as the original input, so minimization doesn't drift to a different
+// failure.
+func (w *worker) minimizeSignal(ctx context.Context, input fuzzMinimizeInput) (min fuzzResult, err error) {
+	start := time.Now()
+	if input.timeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, input.timeout)
+		defer cancel()
+	}
+	vals, err := unmarshalCorpusFile(input.entry.Data)
+	if err != nil {
+		return fuzzResult{}, err
+	}
+	var count int64
+	shouldStop := func() bool {
+		return ctx.Err() != nil || (input.limit > 0 && count >= input.limit)
+	}
+	var accepted int
+	tested := make(map[[sha256.Size]byte]bool)
+	// try runs vals once in the worker process and returns whether it
+	// terminated the process with input.crashSignal.
+	try := func() bool {
+		data := marshalCorpusFile(vals...)
+		h := sha256.Sum256(data)
+		if tested[h] {
+			return false
+		}
+		tested[h] = true
+		if !w.isRunning() {
+			if err := w.startAndPing(ctx); err != nil {
+				return false
+			}
+		}
+		count++
+		args := fuzzArgs{Warmup: true, Limit: 1, InputTimeout: w.coordinator.opts.InputTimeout}
+		if _, _, err := w.client.fuzz(ctx, CorpusEntry{Data: data}, args); err == nil {
+			// The candidate didn't terminate the process. It may have caused
+			// an error, but that's a different failure.
+			return false
+		}
+		w.stop()
+		if sig, ok := terminationSignal(w.waitErr); !ok || sig != input.crashSignal {
+			return false
+		}
+		accepted++
+		return true
+	}
+
+	if !try() {
+		return fuzzResult{}, fmt.Errorf("attempted to minimize but could not reproduce")
+	}
+	for pass := 1; !shouldStop(); pass++ {
+		accepted = 0
+		minimizeValues(vals, w.coordinator.opts.MinMutatedLen, try, shouldStop)
+		if accepted == 0 {
+			w.coordinator.logf(LogDebug, "DEBUG worker %d minimized signal crasher, converged after %d passes\n", w.id, pass)
+			break
+		}
+	}
+
+	data := marshalCorpusFile(vals...)
+	h := sha256.Sum256(data)
+	d := time.Since(start)
+	return fuzzResult{
+		entry: CorpusEntry{
+			Path:       fmt.Sprintf("%x", h[:4]),
+			Parent:     input.entry.Parent,
+			Data:       data,
+			Values:     vals,
+			Generation: input.entry.Generation,
+		},
+		crasherMsg:    input.crasherMsg,
+		canMinimize:   false,
+		limit:         input.limit,
+		count:         count,
+		totalDuration: d,
+		minimized:     true,
 	}, nil
 }
 
+// minimizeCheckpoint returns the last value the worker process saved in
+// shared memory while minimizing input, if it's different from input. The
+// process must have stopped, so it's not writing the value. The worker
+// process only saves values it verified; see workerServer.minimizeInput.
+func (w *worker) minimizeCheckpoint(input CorpusEntry) (CorpusEntry, bool) {
+	mem := <-w.memMu
+	if mem == nil {
+		return CorpusEntry{}, false
+	}
+	defer func() { w.memMu <- mem }()
+	inp, err := CorpusEntryData(input)
+	if err != nil || bytes.Equal(inp, mem.valueRef()) {
+		return CorpusEntry{}, false
+	}
+	data := mem.valueCopy()
+	vals, err := unmarshalCorpusFile(data)
+	if err != nil {
+		// The process terminated while writing the value.
+		return CorpusEntry{}, false
+	}
+	h := sha256.Sum256(data)
+	return CorpusEntry{
+		Path:       fmt.Sprintf("%x", h[:4]),
+		Parent:     input.Parent,
+		Data:       data,
+		Values:     vals,
+		Generation: input.Generation,
+	}, true
+}
+
+// exitedInFuzzFn returns whether the last worker process terminated while it
+// was calling the fuzz function.
+func (w *worker) exitedInFuzzFn() bool {
+	mem := <-w.memMu
+	if mem == nil {
+		return false
+	}
+	defer func() { w.memMu <- mem }()
+	return mem.header().inFuzzFn
+}
+
+// flakyRateExceeded returns whether too many of the current worker process's
+// attempts to deflake new coverage have failed. See
+// CoordinateFuzzingOpts.FlakyWorkerRate.
+func (w *worker) flakyRateExceeded() bool {
+	rate := w.coordinator.opts.FlakyWorkerRate
+	return rate > 0 && w.deflakes >= flakyWorkerMinDeflakes &&
+		float64(w.flakes) > rate*float64(w.deflakes)
+}
+
+// quarantine stops the worker process and waits for
+// CoordinateFuzzingOpts.FlakyWorkerQuarantine before returning, so the
+// coordinator's main loop restarts it fresh. quarantine returns early with
+// ctx.Err() if ctx is cancelled.
+func (w *worker) quarantine(ctx context.Context) error {
+	d := w.coordinator.opts.FlakyWorkerQuarantine
+	if d <= 0 {
+		d = defaultFlakyWorkerQuarantine
+	}
+	w.coordinator.logf(LogWarn, "warning: %d of %d attempts by worker %d to deflake new coverage failed; stopping it for %v\n", w.flakes, w.deflakes, w.id, d)
+	w.quarantines++
+	if err := w.stop(); err != nil && !w.interrupted && !isInterruptError(err) {
+		return err
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// runEnv returns the settings the running worker process reported when it
+// started, in the form "key=value".
+func (w *worker) runEnv() []string {
+	env := []string{fmt.Sprintf("GOMAXPROCS=%d", w.procs)}
+	if w.godebug != "" {
+		env = append(env, "GODEBUG="+w.godebug)
+	}
+	return env
+}
+
+// randomRuntimeEnv returns environment settings that randomize the scheduler
+// and garbage collector of a new worker process. See
+// CoordinateFuzzingOpts.RandomizeRuntime.
+func (w *worker) randomRuntimeEnv() []string {
+	seed := int64(w.coordinator.runSeed("runtime", w.id, w.restarts))
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	r := rand.New(rand.NewSource(seed))
+	var godebug []string
+	for _, kv := range w.env {
+		if strings.HasPrefix(kv, "GODEBUG=") {
+			godebug = []string{strings.TrimPrefix(kv, "GODEBUG=")}
+		}
+	}
+	godebug = append(godebug,
+		fmt.Sprintf("gcstoptheworld=%d", r.Intn(2)),
+		fmt.Sprintf("asyncpreemptoff=%d", r.Intn(2)))
+	return []string{
+		fmt.Sprintf("GOMAXPROCS=%d", 1+r.Intn(runtime.NumCPU())),
+		"GODEBUG=" + strings.Join(godebug, ","),
+	}
+}
+
+// restartWithEnv restarts the worker process with the given environment
+// settings unless it's already running with them. If env is empty, the
+// worker process is left alone.
+func (w *worker) restartWithEnv(ctx context.Context, env []string) error {
+	if len(env) == 0 || equalEnv(w.runEnv(), env) {
+		return nil
+	}
+	if w.isRunning() {
+		w.stop()
+	}
+	w.extraEnv = env
+	return w.startAndPing(ctx)
+}
+
+func equalEnv(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// waitForTermination waits up to d for the worker process to terminate and
+// returns whether it did.
+func (w *worker) waitForTermination(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-w.termC:
+		return true
+	case <-t.C:
+		return false
+	}
+}
+
 func (w *worker) isRunning() bool {
 	return w.cmd != nil
 }
@@ -295,7 +968,37 @@ func (w *worker) startAndPing(ctx context.Context) error {
 	if err := w.start(); err != nil {
 		return err
 	}
-	if err := w.client.ping(ctx); err != nil {
+	// The coordinator's mutator must make the same choices as the worker's
+	// to reconstruct inputs, so both use the same weights.
+	weights := w.coordinator.opts.MutatorWeights
+	if err := w.client.m.setWeights(weights); err != nil {
+		w.stop()
+		return err
+	}
+	// Write a value to shared memory that the worker process hashes in its
+	// response, to check that both processes mapped the same memory.
+	// Otherwise, values would be silently corrupted later.
+	mem, ok := w.client.acquireMem()
+	if !ok {
+		w.stop()
+		return errSharedMemClosed
+	}
+	mem.setValue([]byte(fmt.Sprintf("shared memory check %d", time.Now().UnixNano())))
+	wantHash := sha256.Sum256(mem.valueRef())
+	w.memMu <- mem
+
+	resp, err := w.client.ping(ctx, pingArgs{
+		MutatorWeights: weights,
+		MaxMutatedLen:  w.coordinator.opts.MaxMutatedLen,
+		MinMutatedLen:  w.coordinator.opts.MinMutatedLen,
+		Dictionary:     w.coordinator.opts.Dictionary,
+		InputSchemas:   w.coordinator.opts.InputSchemas,
+		MaxThreads:     w.coordinator.opts.WorkerMaxThreads,
+		MaxOpenFiles:   w.coordinator.opts.WorkerMaxOpenFiles,
+		Seed:           w.coordinator.runSeed("worker", w.id, w.restarts),
+		FeedbackSource: w.coordinator.opts.FeedbackSource,
+	})
+	if err != nil {
 		w.stop()
 		if ctx.Err() != nil {
 			return ctx.Err()
@@ -307,6 +1010,35 @@ func (w *worker) startAndPing(ctx context.Context) error {
 		// TODO: record and return stderr.
 		return fmt.Errorf("fuzzing process terminated without fuzzing: %w", err)
 	}
+	if !bytes.Equal(resp.MemHash, wantHash[:]) {
+		w.stop()
+		return errors.New("shared memory not functioning: the fuzzing process read a different value than was written")
+	}
+	if resp.Err != "" {
+		w.stop()
+		return errors.New(resp.Err)
+	}
+	w.procs = resp.GOMAXPROCS
+	w.godebug = resp.GODEBUG
+	return nil
+}
+
+// checkBinary returns an error if the test executable was replaced or
+// modified since the worker was created, for example, because it was rebuilt
+// during the run. A new build may have different coverage instrumentation,
+// which the coordinator can't use with the coverage it gathered so far.
+func (w *worker) checkBinary() error {
+	if w.binInfo == nil {
+		return nil
+	}
+	fi, err := os.Stat(w.binPath)
+	if err != nil {
+		// Starting the process reports this.
+		return nil
+	}
+	if !os.SameFile(fi, w.binInfo) || !fi.ModTime().Equal(w.binInfo.ModTime()) || fi.Size() != w.binInfo.Size() {
+		return fmt.Errorf("test binary %s changed since fuzzing started; restart the run", w.binPath)
+	}
 	return nil
 }
 
@@ -327,10 +1059,22 @@ func (w *worker) start() (err error) {
 	w.waitErr = nil
 	w.interrupted = false
 	w.termC = nil
+	w.deflakes, w.flakes = 0, 0
+	if err := w.checkBinary(); err != nil {
+		return err
+	}
 
 	cmd := exec.Command(w.binPath, w.args...)
 	cmd.Dir = w.dir
-	cmd.Env = w.env[:len(w.env):len(w.env)] // copy on append to ensure workers don't overwrite each other.
+	cmd.Env = append(w.env[:len(w.env):len(w.env)], w.extraEnv...) // copy on append to ensure workers don't overwrite each other.
+	if w.extraEnv == nil && w.coordinator.opts.RandomizeRuntime {
+		cmd.Env = append(cmd.Env, w.randomRuntimeEnv()...)
+	}
+	w.stderr = &tailBuffer{max: workerStderrTailSize}
+	if w.stderrSize > 0 {
+		w.stderr.max = w.stderrSize
+	}
+	cmd.Stderr = w.stderr
 
 	// Create the "fuzz_in" and "fuzz_out" pipes so we can communicate with
 	// the worker. We don't use stdin and stdout, since the test binary may
@@ -367,7 +1111,14 @@ func (w *worker) start() (err error) {
 	w.termC = make(chan struct{})
 	comm := workerComm{fuzzIn: fuzzInW, fuzzOut: fuzzOutR, memMu: w.memMu}
 	m := newMutator()
+	m.maxLen = w.coordinator.opts.MaxMutatedLen
+	m.minLen = w.coordinator.opts.MinMutatedLen
+	m.dictionary = w.coordinator.opts.Dictionary
+	m.schemas = w.coordinator.opts.InputSchemas
 	w.client = newWorkerClient(comm, m)
+	w.client.memStats = &w.memStats
+
+	w.coordinator.logf(LogDebug, "DEBUG worker %d started process %d\n", w.id, cmd.Process.Pid)
 
 	go func() {
 		w.waitErr = w.cmd.Wait()
@@ -377,6 +1128,120 @@ func (w *worker) start() (err error) {
 	return nil
 }
 
+// saveRecentInputs writes up to n of the last inputs that the worker process
+// ran during a call to workerServer.fuzz with args to a new temporary
+// directory, and returns the directory's name. Files are named in the order
+// the inputs were run, starting with "00". It must be called after the worker
+// process terminated, before shared memory is used again.
+//
+// Inputs aren't copied anywhere while fuzzing. Like workerClient.fuzz, the
+// coordinator reconstructs them from the input and PRNG state in shared
+// memory, which remain after the worker process terminates.
+func (w *worker) saveRecentInputs(args fuzzArgs, n int) (dir string, err error) {
+	mem := <-w.memMu
+	if mem == nil {
+		return "", errSharedMemClosed
+	}
+	defer func() { w.memMu <- mem }()
+	hdr := mem.header()
+	if hdr.count == 0 {
+		return "", nil
+	}
+	vals, err := unmarshalCorpusFile(mem.valueCopy())
+	if err != nil {
+		return "", err
+	}
+
+	var recent [][]byte
+	if args.Warmup {
+		recent = append(recent, mem.valueCopy())
+	} else {
+		m := newMutator()
+		if err := m.setWeights(w.coordinator.opts.MutatorWeights); err != nil {
+			return "", err
+		}
+		m.maxLen = w.coordinator.opts.MaxMutatedLen
+		m.minLen = w.coordinator.opts.MinMutatedLen
+		m.dictionary = w.coordinator.opts.Dictionary
+		m.schemas = w.coordinator.opts.InputSchemas
+		m.r.restore(hdr.randState, hdr.randInc)
+		spliceWith(m, vals, args.Splice, cap(mem.valueRef()))
+		// Deflake calls ran the previous input again, so they're not
+		// replayed.
+		mutations := hdr.count - hdr.deflakes
+		for i := int64(0); i < mutations; i++ {
+			// Inputs skipped for being oversized weren't run.
+			if m.mutateWithin(vals, cap(mem.valueRef()), args.MaxInputSize) && mutations-i <= int64(n) {
+				recent = append(recent, marshalCorpusFile(vals...))
+			}
+		}
+	}
+
+	dir, err = ioutil.TempDir("", "fuzz-last-inputs-")
+	if err != nil {
+		return "", err
+	}
+	for i, data := range recent {
+		if err := ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("%02d", i)), data, 0666); err != nil {
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// deadlockMessage is printed by the runtime when every goroutine in a program
+// is blocked, just before the program exits.
+const deadlockMessage = "fatal error: all goroutines are asleep - deadlock!"
+
+// deadlocked returns whether the last worker process terminated because the
+// runtime detected a deadlock. It must only be called after the process
+// terminated. The runtime only detects deadlocks when no goroutine could ever
+// run again, so for example, an input that blocks while a timer is pending
+// (see CoordinateFuzzingOpts.InputTimeout) is not reported as a deadlock.
+func (w *worker) deadlocked() bool {
+	return w.stderr != nil && bytes.Contains(w.stderr.Bytes(), []byte(deadlockMessage))
+}
+
+// threadExhaustionMessage is printed by the runtime when a program exceeds the
+// limit set with runtime/debug.SetMaxThreads.
+const threadExhaustionMessage = "fatal error: thread exhaustion"
+
+// exhaustedThreads returns whether the last worker process terminated because
+// it exceeded opts.WorkerMaxThreads. It must only be called after the process
+// terminated.
+func (w *worker) exhaustedThreads() bool {
+	return w.stderr != nil && bytes.Contains(w.stderr.Bytes(), []byte(threadExhaustionMessage))
+}
+
+// tailBuffer is an io.Writer that keeps the last max bytes written to it.
+// It's safe for concurrent use.
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := len(p)
+	if len(p) > b.max {
+		p = p[len(p)-b.max:]
+	}
+	if drop := len(b.buf) + len(p) - b.max; drop > 0 {
+		b.buf = append(b.buf[:0], b.buf[drop:]...)
+	}
+	b.buf = append(b.buf, p...)
+	return n, nil
+}
+
+// Bytes returns a copy of the bytes kept in b.
+func (b *tailBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf...)
+}
+
 // stop tells the worker process to exit by closing w.client, then blocks until
 // it terminates. If the worker doesn't terminate after a short time, stop
 // signals it with os.Interrupt (where supported), then os.Kill.
@@ -386,10 +1251,16 @@ func (w *worker) start() (err error) {
 //
 // stop must be called at least once after start returns successfully, even if
 // the worker process terminates unexpectedly.
-func (w *worker) stop() error {
+func (w *worker) stop() (err error) {
 	if w.termC == nil {
 		panic("worker was not started successfully")
 	}
+	if w.cmd != nil {
+		pid := w.cmd.Process.Pid
+		defer func() {
+			w.coordinator.logf(LogDebug, "DEBUG worker %d stopped process %d: %v\n", w.id, pid, err)
+		}()
+	}
 	select {
 	case <-w.termC:
 		// Worker already terminated.
@@ -451,7 +1322,7 @@ func (w *worker) stop() error {
 
 			case nil:
 				// Still waiting. Print a message to let the user know why.
-				fmt.Fprintf(w.coordinator.opts.Log, "waiting for fuzzing process to terminate...\n")
+				w.coordinator.logf(LogInfo, "waiting for fuzzing process to terminate...\n")
 			}
 		}
 	}
@@ -505,6 +1376,19 @@ type minimizeArgs struct {
 	// keep in minimized values. When provided, the worker will reject inputs that
 	// don't cause at least one of these bits to be set.
 	KeepCoverage []byte
+
+	// InputTimeout is the time each call to the fuzz function may take before
+	// the input is considered to have caused an error.
+	InputTimeout time.Duration
+
+	// FewestCoverageBits makes the worker reject minimized values that hit
+	// more coverage bits in total than the value it has so far, when
+	// KeepCoverage is set.
+	FewestCoverageBits bool
+
+	// CoverageSubset makes the worker reject minimized values that hit any
+	// coverage bit the original value didn't, when KeepCoverage is set.
+	CoverageSubset bool
 }
 
 // minimizeResponse contains results from workerServer.minimize.
@@ -529,6 +1413,14 @@ type minimizeResponse struct {
 
 	// Count is the number of values tested.
 	Count int64
+
+	// ParseErr is set if the value in shared memory couldn't be decoded.
+	// No minimization occurred.
+	ParseErr string
+
+	// StopReason describes why minimization stopped, for example,
+	// "converged after 2 passes".
+	StopReason string
 }
 
 // fuzzArgs contains arguments to workerServer.fuzz. The value to fuzz is
@@ -550,6 +1442,34 @@ type fuzzArgs struct {
 	// CoverageData is the coverage data. If set, the worker should update its
 	// local coverage data prior to fuzzing.
 	CoverageData []byte
+
+	// CheckGoroutines indicates whether the worker should periodically sample
+	// the number of running goroutines and warn if it keeps growing.
+	CheckGoroutines bool
+
+	// BestFeedback is the highest score the fuzz function has reported with
+	// ReportFeedback in any worker, or nil if none has been reported. Inputs
+	// with higher scores are reported as interesting.
+	BestFeedback *int64
+
+	// InputTimeout is the time each call to the fuzz function may take before
+	// the input is reported as a crasher. See CoordinateFuzzingOpts.InputTimeout.
+	InputTimeout time.Duration
+
+	// Splice is the encoded value of another corpus entry, or nil. If set, the
+	// worker splices it with the value in shared memory before mutating, using
+	// the same PRNG, so the caller can reconstruct inputs; see spliceWith.
+	Splice []byte
+
+	// MaxInputSize is the largest size of a mutated input the worker should
+	// test. See CoordinateFuzzingOpts.MaxInputSize.
+	MaxInputSize int
+
+	// Replay, if not nil, is a call recorded with
+	// CoordinateFuzzingOpts.RecordSessionPath for the worker to repeat: it
+	// restores the PRNG state and tests exactly Limit values, deflaking
+	// where the recorded call did, whatever coverage they reach now.
+	Replay *sessionCall
 }
 
 // fuzzResponse contains results from workerServer.fuzz.
@@ -561,6 +1481,11 @@ type fuzzResponse struct {
 	// Count is the number of values tested.
 	Count int64
 
+	// RandState and RandInc are the state of the worker's random number
+	// generator before it mutated the input. Like Count, the client reads
+	// them from shared memory.
+	RandState, RandInc uint64 `json:"-"`
+
 	// CoverageData is set if the value in shared memory expands coverage
 	// and therefore may be interesting to the coordinator.
 	CoverageData []byte
@@ -568,13 +1493,99 @@ type fuzzResponse struct {
 	// Err is the error string caused by the value in shared memory, which is
 	// non-empty if the value in shared memory caused a crash.
 	Err string
+
+	// Warning is a message about a likely problem with the fuzz function,
+	// like leaking goroutines, which the coordinator should show to the user.
+	Warning string
+
+	// Feedback is the score the fuzz function reported with ReportFeedback for
+	// the value in shared memory, if it was higher than fuzzArgs.BestFeedback.
+	// Like CoverageData, it indicates the value may be interesting.
+	Feedback *int64
+
+	// ParseErr is set if the value in shared memory couldn't be decoded, for
+	// example, because a corpus file was corrupted. No fuzzing occurred.
+	ParseErr string
+
+	// CrashCoverage is the coverage snapshot taken after the call that
+	// caused Err, if coverage is enabled.
+	CrashCoverage []byte
+
+	// SkippedOversize is the number of mutated inputs, included in Count,
+	// that were not tested because they were larger than
+	// fuzzArgs.MaxInputSize.
+	SkippedOversize int64
+
+	// Deflakes is the number of times a value that expanded coverage was run
+	// again to check the coverage reproduces, and Flakes is the number of
+	// those times it didn't.
+	Deflakes, Flakes int64
+
+	// DeflakedAt lists the counts at which values were run again to
+	// deflake them, so the call can be repeated exactly; see
+	// fuzzArgs.Replay.
+	DeflakedAt []int64
+
+	// MutatorOps is the number of times each mutation operator was applied
+	// during the call, indexed by operator number. It's nil if no values
+	// were mutated. Since every value the worker tests is mutated from the
+	// input it was given, if CoverageData is set, the operators applied at
+	// least once are the ones that led to it.
+	MutatorOps []int64
 }
 
 // pingArgs contains arguments to workerServer.ping.
-type pingArgs struct{}
+type pingArgs struct {
+	// MutatorWeights are the weights the worker's mutator should use. See
+	// CoordinateFuzzingOpts.MutatorWeights.
+	MutatorWeights map[string]int
+
+	// MaxMutatedLen is the largest length the mutator may grow a []byte or
+	// string value to. See CoordinateFuzzingOpts.MaxMutatedLen.
+	MaxMutatedLen int
+
+	// MinMutatedLen is the shortest length the mutator and minimization may
+	// shrink a []byte or string value to. See
+	// CoordinateFuzzingOpts.MinMutatedLen.
+	MinMutatedLen int
+
+	// Dictionary holds tokens for dictionary mutations. See
+	// CoordinateFuzzingOpts.Dictionary.
+	Dictionary [][]byte
+
+	// InputSchemas holds the layout of []byte arguments. See
+	// CoordinateFuzzingOpts.InputSchemas.
+	InputSchemas map[int]Schema
+
+	// MaxThreads and MaxOpenFiles, if positive, limit the OS threads and open
+	// file descriptors the worker process may use. See
+	// CoordinateFuzzingOpts.WorkerMaxThreads and WorkerMaxOpenFiles.
+	MaxThreads, MaxOpenFiles int
+
+	// Seed, if non-zero, is the seed for the worker's mutator, derived from
+	// CoordinateFuzzingOpts.RunID.
+	Seed uint64
+
+	// FeedbackSource is the name of the FeedbackSource the worker reports
+	// coverage from. See CoordinateFuzzingOpts.FeedbackSource.
+	FeedbackSource string
+}
 
 // pingResponse contains results from workerServer.ping.
-type pingResponse struct{}
+type pingResponse struct {
+	// GOMAXPROCS and GODEBUG are the settings the worker process is running
+	// with. The coordinator records them with crashers.
+	GOMAXPROCS int
+	GODEBUG    string
+
+	// MemHash is the SHA-256 hash of the value in shared memory, which the
+	// coordinator uses to check that shared memory works.
+	MemHash []byte
+
+	// Err is set if the worker process couldn't apply the settings in
+	// pingArgs.
+	Err string
+}
 
 // workerComm holds pipes and shared memory used for communication
 // between the coordinator process (client) and a worker process (server).
@@ -607,6 +1618,30 @@ type workerServer struct {
 	// fuzzFn runs the worker's fuzz function on the given input and returns
 	// an error if it finds a crasher (the process may also exit or crash).
 	fuzzFn func(CorpusEntry) error
+
+	// goroutines detects leaked goroutines when fuzzArgs.CheckGoroutines
+	// is set. It's created on first use.
+	goroutines *goroutineProbe
+
+	// inputTimeout is the time each call to fuzzFn may take before the input
+	// is reported as a crasher. It's set from the arguments of the current
+	// call. If zero, fuzzFn is called directly with no time limit.
+	inputTimeout time.Duration
+
+	// hungCalls is the number of calls to fuzzFn that took longer than
+	// inputTimeout and still haven't returned. It's accessed atomically.
+	// While it's non-zero, coverage isn't reported: the hung calls keep
+	// updating the coverage counters, so the snapshot doesn't belong to any
+	// one input.
+	hungCalls int32
+
+	// fewestCoverageBits is set from minimizeArgs.FewestCoverageBits for the
+	// current call to minimize.
+	fewestCoverageBits bool
+
+	// coverageSubset is set from minimizeArgs.CoverageSubset for the current
+	// call to minimize.
+	coverageSubset bool
 }
 
 // serve reads serialized RPC messages on fuzzIn. When serve receives a message,
@@ -620,8 +1655,8 @@ type workerServer struct {
 // does not return errors from method calls; those are passed through serialized
 // responses.
 func (ws *workerServer) serve(ctx context.Context) error {
-	enc := json.NewEncoder(ws.fuzzOut)
-	dec := json.NewDecoder(&contextReader{ctx: ctx, r: ws.fuzzIn})
+	enc := json.NewEncoder(eintrWriter{ws.fuzzOut})
+	dec := json.NewDecoder(&contextReader{ctx: ctx, r: eintrReader{ws.fuzzIn}})
 	for {
 		var c call
 		if err := dec.Decode(&c); err != nil {
@@ -670,6 +1705,7 @@ func (ws *workerServer) fuzz(ctx context.Context, args fuzzArgs) (resp fuzzRespo
 	}
 	start := time.Now()
 	defer func() { resp.TotalDuration = time.Since(start) }()
+	ws.inputTimeout = args.InputTimeout
 
 	if args.Timeout != 0 {
 		var cancel func()
@@ -677,6 +1713,9 @@ func (ws *workerServer) fuzz(ctx context.Context, args fuzzArgs) (resp fuzzRespo
 		defer cancel()
 	}
 	mem := <-ws.memMu
+	if args.Replay != nil {
+		ws.m.r.restore(args.Replay.RandState, args.Replay.RandInc)
+	}
 	ws.m.r.save(&mem.header().randState, &mem.header().randInc)
 	defer func() {
 		resp.Count = mem.header().count
@@ -688,36 +1727,68 @@ func (ws *workerServer) fuzz(ctx context.Context, args fuzzArgs) (resp fuzzRespo
 
 	vals, err := unmarshalCorpusFile(mem.valueCopy())
 	if err != nil {
-		panic(err)
+		resp.ParseErr = fmt.Sprintf("corpus entry failed to parse: %v", err)
+		return resp
 	}
 
 	shouldStop := func() bool {
 		return args.Limit > 0 && mem.header().count >= args.Limit
 	}
-	fuzzOnce := func(entry CorpusEntry) (dur time.Duration, cov []byte, errMsg string) {
+	if args.CheckGoroutines && ws.goroutines == nil {
+		ws.goroutines = newGoroutineProbe()
+	}
+	// fuzzOnce calls the fuzz function with entry. If checkCoverage is true and
+	// the call found new coverage, fuzzOnce returns the new coverage snapshot.
+	fuzzOnce := func(entry CorpusEntry, checkCoverage bool) (dur time.Duration, cov []byte, errMsg string) {
 		mem.header().count++
+		takeFeedback() // discard any score reported outside the fuzz function
 		start := time.Now()
-		err := ws.fuzzFn(entry)
+		mem.header().inFuzzFn = true
+		err := ws.callFuzzFn(entry)
+		mem.header().inFuzzFn = false
 		dur = time.Since(start)
+		if args.CheckGoroutines {
+			ws.goroutines.calls++
+			if ws.goroutines.calls%goroutineSampleInterval == 0 {
+				if w := ws.goroutines.sample(runtime.NumGoroutine()); w != "" {
+					resp.Warning = w
+				}
+			}
+		}
 		if err != nil {
 			errMsg = err.Error()
 			if errMsg == "" {
 				errMsg = "fuzz function failed with no input"
 			}
+			if ws.coverageMask != nil && atomic.LoadInt32(&ws.hungCalls) == 0 {
+				resp.CrashCoverage = coverageSnapshot
+			}
 			return dur, nil, errMsg
 		}
-		if ws.coverageMask != nil && countNewCoverageBits(ws.coverageMask, coverageSnapshot) > 0 {
+		if checkCoverage && ws.coverageMask != nil && atomic.LoadInt32(&ws.hungCalls) == 0 &&
+			activeFeedback.NewBits(ws.coverageMask, coverageSnapshot) > 0 {
 			return dur, coverageSnapshot, ""
 		}
 		return dur, nil, ""
 	}
 
+	// improvedFeedback returns the score reported for the last input if it's
+	// higher than the best score seen so far.
+	improvedFeedback := func() *int64 {
+		v, ok := takeFeedback()
+		if !ok || !improvesFeedback(args.BestFeedback, v) {
+			return nil
+		}
+		return &v
+	}
+
 	if args.Warmup {
-		dur, _, errMsg := fuzzOnce(CorpusEntry{Values: vals})
+		dur, _, errMsg := fuzzOnce(CorpusEntry{Values: vals}, true)
 		if errMsg != "" {
 			resp.Err = errMsg
 			return resp
 		}
+		resp.Feedback = improvedFeedback()
 		resp.InterestingDuration = dur
 		if coverageEnabled {
 			resp.CoverageData = coverageSnapshot
@@ -725,28 +1796,80 @@ func (ws *workerServer) fuzz(ctx context.Context, args fuzzArgs) (resp fuzzRespo
 		return resp
 	}
 
+	ws.m.opCounts = make([]int64, numMutatorOps())
+	defer func() {
+		resp.MutatorOps = ws.m.opCounts
+		ws.m.opCounts = nil
+	}()
+	spliceWith(ws.m, vals, args.Splice, cap(mem.valueRef()))
+	if args.Replay != nil {
+		return ws.replayFuzz(ctx, args, vals, mem, fuzzOnce, resp)
+	}
 	for {
 		select {
 		case <-ctx.Done():
 			return resp
 
 		default:
-			ws.m.mutate(vals, cap(mem.valueRef()))
+			if !ws.m.mutateWithin(vals, cap(mem.valueRef()), args.MaxInputSize) {
+				// Count the skipped input anyway: the coordinator replays
+				// count mutations to reconstruct the last input.
+				mem.header().count++
+				resp.SkippedOversize++
+				if shouldStop() {
+					return resp
+				}
+				continue
+			}
 			entry := CorpusEntry{Values: vals}
-			dur, cov, errMsg := fuzzOnce(entry)
+			dur, cov, errMsg := fuzzOnce(entry, true)
 			if errMsg != "" {
 				resp.Err = errMsg
+				writeCrasherToMem(vals, mem)
 				return resp
 			}
+			if fb := improvedFeedback(); fb != nil {
+				// Like new coverage below, run the same values once more to
+				// deflake, and report the lower of the two scores.
+				if !shouldStop() {
+					resp.DeflakedAt = append(resp.DeflakedAt, mem.header().count)
+					mem.header().deflakes++
+					dur, _, errMsg = fuzzOnce(entry, false)
+					if errMsg != "" {
+						resp.Err = errMsg
+						writeCrasherToMem(vals, mem)
+						return resp
+					}
+					resp.Deflakes++
+					if again := improvedFeedback(); again == nil {
+						resp.Flakes++
+						fb = nil
+					} else if *again < *fb {
+						fb = again
+					}
+				}
+				if fb != nil {
+					resp.Feedback = fb
+					resp.InterestingDuration = dur
+					return resp
+				}
+			}
 			if cov != nil {
 				// Found new coverage. Before reporting to the coordinator,
 				// run the same values once more to deflake.
 				if !shouldStop() {
-					dur, cov, errMsg = fuzzOnce(entry)
+					resp.DeflakedAt = append(resp.DeflakedAt, mem.header().count)
+					mem.header().deflakes++
+					dur, cov, errMsg = fuzzOnce(entry, true)
 					if errMsg != "" {
 						resp.Err = errMsg
+						writeCrasherToMem(vals, mem)
 						return resp
 					}
+					resp.Deflakes++
+					if cov == nil {
+						resp.Flakes++
+					}
 				}
 				if cov != nil {
 					resp.CoverageData = cov
@@ -761,14 +1884,99 @@ func (ws *workerServer) fuzz(ctx context.Context, args fuzzArgs) (resp fuzzRespo
 	}
 }
 
+// replayFuzz repeats the call described by args.Replay, once vals has been
+// spliced, like the loop in fuzz: it tests the same mutations of vals, and
+// runs values again where the recorded call deflaked them. It only stops
+// early for a crash, and reports coverage if the last value tested expands
+// it.
+func (ws *workerServer) replayFuzz(ctx context.Context, args fuzzArgs, vals []interface{}, mem *sharedMem, fuzzOnce func(CorpusEntry, bool) (time.Duration, []byte, string), resp fuzzResponse) fuzzResponse {
+	deflakes := args.Replay.Deflakes
+	var dur time.Duration
+	var cov []byte
+	for mem.header().count < args.Limit {
+		if ctx.Err() != nil {
+			return resp
+		}
+		if !ws.m.mutateWithin(vals, cap(mem.valueRef()), args.MaxInputSize) {
+			mem.header().count++
+			resp.SkippedOversize++
+			cov = nil
+			continue
+		}
+		entry := CorpusEntry{Values: vals}
+		var errMsg string
+		dur, cov, errMsg = fuzzOnce(entry, true)
+		for errMsg == "" && len(deflakes) > 0 && deflakes[0] == mem.header().count {
+			deflakes = deflakes[1:]
+			resp.Deflakes++
+			resp.DeflakedAt = append(resp.DeflakedAt, mem.header().count)
+			mem.header().deflakes++
+			dur, cov, errMsg = fuzzOnce(entry, true)
+		}
+		if errMsg != "" {
+			resp.Err = errMsg
+			writeCrasherToMem(vals, mem)
+			return resp
+		}
+	}
+	if cov != nil {
+		resp.CoverageData = cov
+		resp.InterestingDuration = dur
+	}
+	return resp
+}
+
+// valuesSize returns the total length of the []byte and string values in vals.
+func valuesSize(vals []interface{}) int {
+	n := 0
+	for _, v := range vals {
+		switch v := v.(type) {
+		case []byte:
+			n += len(v)
+		case string:
+			n += len(v)
+		}
+	}
+	return n
+}
+
+// spliceWith splices vals with the values encoded in data using m, and keeps
+// their []byte and string values in m for splice mutations. It only clears
+// them if data is nil or can't be decoded. workerServer.fuzz and
+// workerClient.fuzz both call spliceWith before mutating, so they produce
+// the same values from the same PRNG state.
+func spliceWith(m *mutator, vals []interface{}, data []byte, maxBytes int) {
+	m.spliceVals = nil
+	if data == nil {
+		return
+	}
+	other, err := unmarshalCorpusFile(data)
+	if err != nil {
+		return
+	}
+	for _, v := range other {
+		switch v := v.(type) {
+		case []byte:
+			m.spliceVals = append(m.spliceVals, v)
+		case string:
+			m.spliceVals = append(m.spliceVals, []byte(v))
+		}
+	}
+	m.splice(vals, other, maxBytes)
+}
+
 func (ws *workerServer) minimize(ctx context.Context, args minimizeArgs) (resp minimizeResponse) {
 	start := time.Now()
 	defer func() { resp.Duration = time.Now().Sub(start) }()
+	ws.inputTimeout = args.InputTimeout
+	ws.fewestCoverageBits = args.FewestCoverageBits
+	ws.coverageSubset = args.CoverageSubset
 	mem := <-ws.memMu
 	defer func() { ws.memMu <- mem }()
 	vals, err := unmarshalCorpusFile(mem.valueCopy())
 	if err != nil {
-		panic(err)
+		resp.ParseErr = fmt.Sprintf("corpus entry failed to parse: %v", err)
+		return resp
 	}
 	if args.Timeout != 0 {
 		var cancel func()
@@ -776,10 +1984,14 @@ func (ws *workerServer) minimize(ctx context.Context, args minimizeArgs) (resp m
 		defer cancel()
 	}
 
-	// Minimize the values in vals, then write to shared memory. We only write
-	// to shared memory after completing minimization. If the worker terminates
-	// unexpectedly before then, the coordinator will use the original input.
-	resp.Success, err = ws.minimizeInput(ctx, vals, &mem.header().count, args.Limit, args.KeepCoverage)
+	// Minimize the values in vals, then write to shared memory. While
+	// minimizing, each smaller value that's verified to be interesting is also
+	// written to shared memory, so shared memory always holds an interesting
+	// value. If the worker terminates unexpectedly, the coordinator will use
+	// the last value written, which may be the original input. If a candidate
+	// fails while minimizing a value that expands coverage, it's written
+	// instead, marked as the crasher.
+	resp.Success, resp.StopReason, err = ws.minimizeInput(ctx, vals, &mem.header().count, args.Limit, args.KeepCoverage, mem)
 	if resp.Success {
 		writeToMem(vals, mem)
 	}
@@ -796,150 +2008,203 @@ func (ws *workerServer) minimize(ctx context.Context, args minimizeArgs) (resp m
 // every call to fuzzFn, it marshals the new vals and writes it to the provided
 // mem just in case an unrecoverable error occurs. It uses the context to
 // determine how long to run, stopping once closed. It returns a bool
-// indicating whether minimization was successful and an error if one was found.
-func (ws *workerServer) minimizeInput(ctx context.Context, vals []interface{}, count *int64, limit int64, keepCoverage []byte) (success bool, retErr error) {
+// indicating whether minimization was successful, a description of why it
+// stopped, and an error if one was found.
+//
+// The transformations are applied in passes over all the values, until a
+// pass doesn't make any value smaller. Candidates that were already tested
+// aren't tested again, so minimization can't loop forever between the same
+// states, and no call to fuzzFn is wasted.
+//
+// If mem is not nil, each time a smaller value is verified to be interesting,
+// minimizeInput writes it to mem as a checkpoint. If minimization is cut short,
+// the coordinator can use the checkpoint instead of the original value.
+func (ws *workerServer) minimizeInput(ctx context.Context, vals []interface{}, count *int64, limit int64, keepCoverage []byte, mem *sharedMem) (success bool, stopReason string, retErr error) {
 	wantError := keepCoverage == nil
 	shouldStop := func() bool {
 		return ctx.Err() != nil ||
 			(limit > 0 && *count >= limit) ||
 			(retErr != nil && !wantError)
 	}
+	// stopped describes why shouldStop returned true.
+	stopped := func() string {
+		switch {
+		case ctx.Err() != nil:
+			return "timed out or interrupted"
+		case limit > 0 && *count >= limit:
+			return "reached limit"
+		default:
+			return "fuzz function failed"
+		}
+	}
 	if shouldStop() {
-		return false, nil
+		return false, stopped(), nil
 	}
 
 	// Check that the original value preserves coverage or causes an error.
 	// If not, then whatever caused us to think the value was interesting may
 	// have been a flake, and we can't minimize it.
 	*count++
-	if retErr = ws.fuzzFn(CorpusEntry{Values: vals}); retErr == nil && wantError {
-		return false, nil
+	if retErr = ws.callFuzzFn(CorpusEntry{Values: vals}); retErr == nil && wantError {
+		return false, "original input did not fail", nil
 	} else if retErr != nil && !wantError {
-		return false, retErr
+		return false, "original input failed", retErr
 	} else if keepCoverage != nil && !hasCoverageBit(keepCoverage, coverageSnapshot) {
-		return false, nil
+		return false, "original input lost coverage", nil
+	}
+	seed := maphash.MakeSeed()
+	tested := map[uint64]bool{valuesHash(seed, vals): true}
+
+	// If ws.fewestCoverageBits is set, minimizing an interesting value also
+	// narrows the behavior it exercises: a candidate is rejected if it hits
+	// more coverage bits in total than the best value so far, so the result
+	// hits the new bits with as little other code as possible.
+	bestBits := countBits(coverageSnapshot)
+
+	// If ws.coverageSubset is set, a candidate is also rejected if it hits
+	// any bit the original value didn't, so the result never exercises
+	// more than the original did.
+	var origCoverage []byte
+	if keepCoverage != nil && ws.coverageSubset {
+		origCoverage = append([]byte(nil), coverageSnapshot...)
 	}
 
-	var valI int
-	// tryMinimized runs the fuzz function with candidate replacing the value
-	// at index valI. tryMinimized returns whether the input with candidate is
+	var accepted int
+	// try runs the fuzz function with vals. try returns whether vals is
 	// interesting for the same reason as the original input: it returns
 	// an error if one was expected, or it preserves coverage.
-	tryMinimized := func(candidate interface{}) bool {
-		prev := vals[valI]
-		// Set vals[valI] to the candidate after it has been
-		// properly cast. We know that candidate must be of
-		// the same type as prev, so use that as a reference.
-		switch c := candidate.(type) {
-		case float64:
-			switch prev.(type) {
-			case float32:
-				vals[valI] = float32(c)
-			case float64:
-				vals[valI] = c
-			default:
-				panic("impossible")
-			}
-		case uint:
-			switch prev.(type) {
-			case uint:
-				vals[valI] = c
-			case uint8:
-				vals[valI] = uint8(c)
-			case uint16:
-				vals[valI] = uint16(c)
-			case uint32:
-				vals[valI] = uint32(c)
-			case uint64:
-				vals[valI] = uint64(c)
-			case int:
-				vals[valI] = int(c)
-			case int8:
-				vals[valI] = int8(c)
-			case int16:
-				vals[valI] = int16(c)
-			case int32:
-				vals[valI] = int32(c)
-			case int64:
-				vals[valI] = int64(c)
-			default:
-				panic("impossible")
-			}
-		case []byte:
-			switch prev.(type) {
-			case []byte:
-				vals[valI] = c
-			case string:
-				vals[valI] = string(c)
-			default:
-				panic("impossible")
-			}
-		default:
-			panic("impossible")
+	try := func() bool {
+		h := valuesHash(seed, vals)
+		if tested[h] {
+			// Testing it again would give the same result.
+			return false
 		}
+		tested[h] = true
 		*count++
-		err := ws.fuzzFn(CorpusEntry{Values: vals})
+		err := ws.callFuzzFn(CorpusEntry{Values: vals})
 		if err != nil {
 			retErr = err
+			if mem != nil {
+				if wantError {
+					writeToMem(vals, mem)
+				} else {
+					// The candidate is undone, so record the values that
+					// failed for the coordinator to write as the crasher.
+					writeCrasherToMem(vals, mem)
+				}
+			}
+			if wantError {
+				accepted++
+			}
 			return wantError
 		}
-		if keepCoverage != nil && hasCoverageBit(keepCoverage, coverageSnapshot) {
+		if keepCoverage != nil && hasCoverageBit(keepCoverage, coverageSnapshot) &&
+			(!ws.fewestCoverageBits || countBits(coverageSnapshot) <= bestBits) &&
+			(origCoverage == nil || countNewCoverageBits(origCoverage, coverageSnapshot) == 0) {
+			bestBits = countBits(coverageSnapshot)
+			accepted++
+			if mem != nil {
+				writeToMem(vals, mem)
+			}
 			return true
 		}
-		vals[valI] = prev
 		return false
 	}
 
-	for valI = range vals {
+	var minLen int
+	if ws.m != nil {
+		minLen = ws.m.minLen
+	}
+	for pass := 1; ; pass++ {
+		accepted = 0
+		minimizeValues(vals, minLen, try, shouldStop)
 		if shouldStop() {
-			break
+			return (wantError || retErr == nil), stopped(), retErr
 		}
-		switch v := vals[valI].(type) {
-		case bool:
-			continue // can't minimize
-		case float32:
-			minimizeFloat(float64(v), tryMinimized, shouldStop)
-		case float64:
-			minimizeFloat(v, tryMinimized, shouldStop)
-		case uint:
-			minimizeInteger(v, tryMinimized, shouldStop)
-		case uint8:
-			minimizeInteger(uint(v), tryMinimized, shouldStop)
-		case uint16:
-			minimizeInteger(uint(v), tryMinimized, shouldStop)
-		case uint32:
-			minimizeInteger(uint(v), tryMinimized, shouldStop)
-		case uint64:
-			if uint64(uint(v)) != v {
-				// Skip minimizing a uint64 on 32 bit platforms, since we'll truncate the
-				// value when casting
-				continue
-			}
-			minimizeInteger(uint(v), tryMinimized, shouldStop)
-		case int:
-			minimizeInteger(uint(v), tryMinimized, shouldStop)
-		case int8:
-			minimizeInteger(uint(v), tryMinimized, shouldStop)
-		case int16:
-			minimizeInteger(uint(v), tryMinimized, shouldStop)
-		case int32:
-			minimizeInteger(uint(v), tryMinimized, shouldStop)
-		case int64:
-			if int64(int(v)) != v {
-				// Skip minimizing a int64 on 32 bit platforms, since we'll truncate the
-				// value when casting
-				continue
-			}
-			minimizeInteger(uint(v), tryMinimized, shouldStop)
-		case string:
-			minimizeBytes([]byte(v), tryMinimized, shouldStop)
+		if accepted == 0 {
+			return (wantError || retErr == nil), fmt.Sprintf("converged after %d passes", pass), retErr
+		}
+	}
+}
+
+// valuesHash returns a hash of vals, so minimizeInput can skip candidates it
+// already tested. It hashes []byte and string values directly, since encoding
+// every candidate with marshalCorpusFile would cost about as much as testing
+// it. A collision only means a candidate is skipped.
+func valuesHash(seed maphash.Seed, vals []interface{}) uint64 {
+	var h maphash.Hash
+	h.SetSeed(seed)
+	var n [binary.MaxVarintLen64]byte
+	for _, v := range vals {
+		switch v := v.(type) {
 		case []byte:
-			minimizeBytes(v, tryMinimized, shouldStop)
+			h.Write(n[:binary.PutUvarint(n[:], uint64(len(v)))])
+			h.Write(v)
+		case string:
+			h.Write(n[:binary.PutUvarint(n[:], uint64(len(v)))])
+			h.WriteString(v)
 		default:
-			panic("unreachable")
+			fmt.Fprintf(&h, "%T(%v);", v, v)
+		}
+	}
+	return h.Sum64()
+}
+
+// maxHungCalls is the number of calls to the fuzz function that may run past
+// their deadline at the same time. Once there are this many, the worker process
+// gives up and panics, terminating the process.
+const maxHungCalls = 8
+
+// callFuzzFn calls ws.fuzzFn with entry.
+//
+// If ws.inputTimeout is set, fuzzFn is called on a separate goroutine, and
+// callFuzzFn returns an error if it doesn't return in time. The goroutine is
+// left running, since there's no way to stop it. This only works for fuzz
+// functions that eventually yield to the scheduler. If too many calls are left
+// running, callFuzzFn panics so the process is restarted; the coordinator
+// will record the input in shared memory as a crasher.
+func (ws *workerServer) callFuzzFn(entry CorpusEntry) error {
+	if ws.inputTimeout <= 0 {
+		return ws.fuzzFn(entry)
+	}
+	if n := atomic.LoadInt32(&ws.hungCalls); n >= maxHungCalls {
+		panic(fmt.Sprintf("%d calls to the fuzz function did not return after %v", n, ws.inputTimeout))
+	}
+
+	// The call may outlive callFuzzFn, and the caller mutates the values in
+	// place for the next input, so give the call its own copy.
+	entry.Values = cloneValues(entry.Values)
+	done := make(chan error)
+	go func() {
+		err := ws.fuzzFn(entry)
+		select {
+		case done <- err:
+		default:
+			// callFuzzFn stopped waiting for this call.
+			atomic.AddInt32(&ws.hungCalls, -1)
+		}
+	}()
+	t := time.NewTimer(ws.inputTimeout)
+	defer t.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-t.C:
+		atomic.AddInt32(&ws.hungCalls, 1)
+		return fmt.Errorf("fuzz function did not return after %v", ws.inputTimeout)
+	}
+}
+
+// cloneValues returns a copy of vals that shares no memory with vals.
+func cloneValues(vals []interface{}) []interface{} {
+	c := make([]interface{}, len(vals))
+	for i, v := range vals {
+		if b, ok := v.([]byte); ok && b != nil {
+			v = append(make([]byte, 0, len(b)), b...)
 		}
+		c[i] = v
 	}
-	return (wantError || retErr == nil), retErr
+	return c
 }
 
 func writeToMem(vals []interface{}, mem *sharedMem) {
@@ -947,10 +2212,57 @@ func writeToMem(vals []interface{}, mem *sharedMem) {
 	mem.setValue(b)
 }
 
-// ping does nothing. The coordinator calls this method to ensure the worker
-// has called F.Fuzz and can communicate.
+// writeCrasherToMem writes vals, which caused an error, to shared memory and
+// marks them as the crasher, so the coordinator records exactly the values
+// that were tested. The coordinator otherwise reconstructs them by replaying
+// the mutator, which is only reliable if the mutator is deterministic. If the
+// encoded values don't fit, shared memory is left alone.
+func writeCrasherToMem(vals []interface{}, mem *sharedMem) {
+	b := marshalCorpusFile(vals...)
+	if len(b) > cap(mem.valueRef()) {
+		return
+	}
+	mem.setValue(b)
+	mem.header().crasherValue = true
+}
+
+// ping reports the settings the worker is running with and a hash of the value
+// in shared memory. The coordinator calls this method to ensure the worker has
+// called F.Fuzz and can communicate.
+// ping also applies settings that are fixed for the life of the worker process.
 func (ws *workerServer) ping(ctx context.Context, args pingArgs) pingResponse {
-	return pingResponse{}
+	if err := ws.m.setWeights(args.MutatorWeights); err != nil {
+		// The coordinator checks the weights before starting workers.
+		panic(err)
+	}
+	ws.m.maxLen = args.MaxMutatedLen
+	ws.m.minLen = args.MinMutatedLen
+	ws.m.dictionary = args.Dictionary
+	ws.m.schemas = args.InputSchemas
+	if args.Seed != 0 {
+		ws.m.r = newPcgRandSeed(args.Seed, 1)
+	}
+	var errMsg string
+	if err := setFeedbackSource(args.FeedbackSource); err != nil {
+		errMsg = err.Error()
+	}
+	if args.MaxThreads > 0 {
+		debug.SetMaxThreads(args.MaxThreads)
+	}
+	if args.MaxOpenFiles > 0 {
+		if err := setOpenFileLimit(args.MaxOpenFiles); err != nil {
+			errMsg = fmt.Sprintf("setting the open file limit to %d: %v", args.MaxOpenFiles, err)
+		}
+	}
+	mem := <-ws.memMu
+	h := sha256.Sum256(mem.valueRef())
+	ws.memMu <- mem
+	return pingResponse{
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		GODEBUG:    os.Getenv("GODEBUG"),
+		MemHash:    h[:],
+		Err:        errMsg,
+	}
 }
 
 // workerClient is a minimalist RPC client. The coordinator process uses a
@@ -960,6 +2272,32 @@ type workerClient struct {
 	workerComm
 	mu sync.Mutex
 	m  *mutator
+
+	// memStats, if not nil, records time spent waiting for shared memory.
+	memStats *memWaitStats
+}
+
+// memWaitStats records how often the coordinator acquired a worker's shared
+// memory and how long it waited to do so. Waiting indicates contention with
+// another goroutine, like one cleaning up the worker. The worker process
+// serves calls one at a time, so it doesn't wait on its side.
+type memWaitStats struct {
+	acquisitions int64
+	wait         time.Duration
+}
+
+// acquireMem receives shared memory from wc.memMu, recording the time spent
+// waiting in wc.memStats. ok is false if memMu was closed.
+func (wc *workerClient) acquireMem() (mem *sharedMem, ok bool) {
+	if wc.memStats == nil {
+		mem, ok = <-wc.memMu
+		return mem, ok
+	}
+	start := time.Now()
+	mem, ok = <-wc.memMu
+	wc.memStats.acquisitions++
+	wc.memStats.wait += time.Since(start)
+	return mem, ok
 }
 
 func newWorkerClient(comm workerComm, m *mutator) *workerClient {
@@ -968,7 +2306,8 @@ func newWorkerClient(comm workerComm, m *mutator) *workerClient {
 
 // Close shuts down the connection to the RPC server (the worker process) by
 // closing fuzz_in. Close drains fuzz_out (avoiding a SIGPIPE in the worker),
-// and closes it after the worker process closes the other end.
+// and closes it after the worker process closes the other end, or after
+// closeDrainTimeout, whichever comes first.
 func (wc *workerClient) Close() error {
 	wc.mu.Lock()
 	defer wc.mu.Unlock()
@@ -981,12 +2320,28 @@ func (wc *workerClient) Close() error {
 	}
 
 	// Drain fuzzOut and close it. When the server exits, the kernel will close
-	// its end of fuzzOut, and we'll get EOF.
-	if _, err := io.Copy(ioutil.Discard, wc.fuzzOut); err != nil {
+	// its end of fuzzOut, and we'll get EOF. If that doesn't happen in time,
+	// because the server is wedged or another process inherited its end of
+	// fuzzOut, close fuzzOut anyway, which stops the drain. worker.stop kills
+	// a server that's still running by then.
+	drainC := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(ioutil.Discard, wc.fuzzOut)
+		drainC <- err
+	}()
+	t := time.NewTimer(closeDrainTimeout)
+	defer t.Stop()
+	select {
+	case err := <-drainC:
+		if err != nil {
+			wc.fuzzOut.Close()
+			return err
+		}
+		return wc.fuzzOut.Close()
+	case <-t.C:
 		wc.fuzzOut.Close()
-		return err
+		return fmt.Errorf("worker process did not close fuzz_out within %v", closeDrainTimeout)
 	}
-	return wc.fuzzOut.Close()
 }
 
 // errSharedMemClosed is returned by workerClient methods that cannot access
@@ -998,17 +2353,24 @@ func (wc *workerClient) Close() error {
 // interrupted.
 var errSharedMemClosed = errors.New("internal error: shared memory was closed and unmapped")
 
+// errPipeClosed is returned by workerClient methods when the worker process
+// closed fuzz_out between responses. The worker does this when its serve loop
+// returns, which usually happens because the process is exiting. Whether
+// the input crashed the process depends on how it terminated.
+var errPipeClosed = errors.New("fuzzing process closed fuzz_out")
+
 // minimize tells the worker to call the minimize method. See
 // workerServer.minimize.
 func (wc *workerClient) minimize(ctx context.Context, entryIn CorpusEntry, args minimizeArgs) (entryOut CorpusEntry, resp minimizeResponse, err error) {
 	wc.mu.Lock()
 	defer wc.mu.Unlock()
 
-	mem, ok := <-wc.memMu
+	mem, ok := wc.acquireMem()
 	if !ok {
 		return CorpusEntry{}, minimizeResponse{}, errSharedMemClosed
 	}
 	mem.header().count = 0
+	mem.header().crasherValue = false
 	inp, err := CorpusEntryData(entryIn)
 	if err != nil {
 		return CorpusEntry{}, minimizeResponse{}, err
@@ -1018,13 +2380,18 @@ func (wc *workerClient) minimize(ctx context.Context, entryIn CorpusEntry, args
 
 	c := call{Minimize: &args}
 	callErr := wc.callLocked(ctx, c, &resp)
-	mem, ok = <-wc.memMu
+	mem, ok = wc.acquireMem()
 	if !ok {
 		return CorpusEntry{}, minimizeResponse{}, errSharedMemClosed
 	}
 	defer func() { wc.memMu <- mem }()
 	resp.Count = mem.header().count
-	if resp.Success {
+	// If the call failed, the worker may still have saved a smaller value
+	// it verified before stopping. See workerServer.minimizeInput.
+	checkpoint := callErr != nil && !bytes.Equal(inp, mem.valueRef())
+	// If a candidate failed, the worker wrote it to shared memory, so the
+	// crasher is what was tested, not the last interesting value.
+	if resp.Success || checkpoint || mem.header().crasherValue {
 		entryOut.Data = mem.valueCopy()
 		entryOut.Values, err = unmarshalCorpusFile(entryOut.Data)
 		h := sha256.Sum256(entryOut.Data)
@@ -1049,11 +2416,14 @@ func (wc *workerClient) fuzz(ctx context.Context, entryIn CorpusEntry, args fuzz
 	wc.mu.Lock()
 	defer wc.mu.Unlock()
 
-	mem, ok := <-wc.memMu
+	mem, ok := wc.acquireMem()
 	if !ok {
 		return CorpusEntry{}, fuzzResponse{}, errSharedMemClosed
 	}
 	mem.header().count = 0
+	mem.header().deflakes = 0
+	mem.header().crasherValue = false
+	mem.header().inFuzzFn = false
 	inp, err := CorpusEntryData(entryIn)
 	if err != nil {
 		return CorpusEntry{}, fuzzResponse{}, err
@@ -1063,31 +2433,45 @@ func (wc *workerClient) fuzz(ctx context.Context, entryIn CorpusEntry, args fuzz
 
 	c := call{Fuzz: &args}
 	callErr := wc.callLocked(ctx, c, &resp)
-	mem, ok = <-wc.memMu
+	mem, ok = wc.acquireMem()
 	if !ok {
 		return CorpusEntry{}, fuzzResponse{}, errSharedMemClosed
 	}
 	defer func() { wc.memMu <- mem }()
 	resp.Count = mem.header().count
+	resp.RandState, resp.RandInc = mem.header().randState, mem.header().randInc
 
-	if !bytes.Equal(inp, mem.valueRef()) {
+	// The worker may write the crasher to shared memory and then fail to
+	// respond, for example, if it's terminated while stopping, so trust the
+	// header regardless of callErr.
+	crasherValue := mem.header().crasherValue
+	if !crasherValue && !bytes.Equal(inp, mem.valueRef()) {
 		panic("workerServer.fuzz modified input")
 	}
 	needEntryOut := callErr != nil || resp.Err != "" ||
-		(!args.Warmup && resp.CoverageData != nil)
+		(!args.Warmup && (resp.CoverageData != nil || resp.Feedback != nil))
 	if needEntryOut {
-		valuesOut, err := unmarshalCorpusFile(inp)
-		if err != nil {
-			panic(fmt.Sprintf("unmarshaling fuzz input value after call: %v", err))
-		}
-		wc.m.r.restore(mem.header().randState, mem.header().randInc)
-		if !args.Warmup {
-			// Only mutate the valuesOut if fuzzing actually occurred.
-			for i := int64(0); i < mem.header().count; i++ {
-				wc.m.mutate(valuesOut, cap(mem.valueRef()))
+		var dataOut []byte
+		if crasherValue {
+			// The worker wrote the exact values that caused the error.
+			dataOut = mem.valueCopy()
+		} else {
+			valuesOut, err := unmarshalCorpusFile(inp)
+			if err != nil {
+				panic(fmt.Sprintf("unmarshaling fuzz input value after call: %v", err))
 			}
+			wc.m.r.restore(mem.header().randState, mem.header().randInc)
+			if !args.Warmup {
+				// Only mutate the valuesOut if fuzzing actually occurred.
+				// Calls that deflaked a value ran it again without
+				// mutating it.
+				spliceWith(wc.m, valuesOut, args.Splice, cap(mem.valueRef()))
+				for i := mem.header().deflakes; i < mem.header().count; i++ {
+					wc.m.mutateWithin(valuesOut, cap(mem.valueRef()), args.MaxInputSize)
+				}
+			}
+			dataOut = marshalCorpusFile(valuesOut...)
 		}
-		dataOut := marshalCorpusFile(valuesOut...)
 
 		h := sha256.Sum256(dataOut)
 		name := fmt.Sprintf("%x", h[:4])
@@ -1108,23 +2492,31 @@ func (wc *workerClient) fuzz(ctx context.Context, entryIn CorpusEntry, args fuzz
 }
 
 // ping tells the worker to call the ping method. See workerServer.ping.
-func (wc *workerClient) ping(ctx context.Context) error {
+func (wc *workerClient) ping(ctx context.Context, args pingArgs) (resp pingResponse, err error) {
 	wc.mu.Lock()
 	defer wc.mu.Unlock()
-	c := call{Ping: &pingArgs{}}
-	var resp pingResponse
-	return wc.callLocked(ctx, c, &resp)
+	c := call{Ping: &args}
+	err = wc.callLocked(ctx, c, &resp)
+	return resp, err
 }
 
 // callLocked sends an RPC from the coordinator to the worker process and waits
 // for the response. The callLocked may be cancelled with ctx.
 func (wc *workerClient) callLocked(ctx context.Context, c call, resp interface{}) (err error) {
-	enc := json.NewEncoder(wc.fuzzIn)
-	dec := json.NewDecoder(&contextReader{ctx: ctx, r: wc.fuzzOut})
+	enc := json.NewEncoder(&contextWriter{ctx: ctx, w: eintrWriter{wc.fuzzIn}})
+	dec := json.NewDecoder(&contextReader{ctx: ctx, r: eintrReader{wc.fuzzOut}})
 	if err := enc.Encode(c); err != nil {
 		return err
 	}
-	return dec.Decode(resp)
+	switch err := dec.Decode(resp); err {
+	case io.EOF:
+		// No part of a response was read.
+		return errPipeClosed
+	case io.ErrUnexpectedEOF:
+		return fmt.Errorf("fuzzing process closed fuzz_out in the middle of a response: %w", err)
+	default:
+		return err
+	}
 }
 
 // contextReader wraps a Reader with a Context. If the context is cancelled
@@ -1162,3 +2554,79 @@ func (cr *contextReader) Read(b []byte) (int, error) {
 		return n, err
 	}
 }
+
+// contextWriter wraps a Writer with a Context. If the context is cancelled
+// while the underlying writer is blocked, Write returns immediately.
+//
+// This is useful for writing to a pipe that the process at the other end has
+// stopped reading, for example, because it's stuck in a call to the fuzz
+// function. Once Write returns an error, the data may still be written
+// later, so the pipe must not be used again.
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (cw *contextWriter) Write(b []byte) (int, error) {
+	if ctxErr := cw.ctx.Err(); ctxErr != nil {
+		return 0, ctxErr
+	}
+	done := make(chan struct{})
+
+	// This goroutine may stay blocked after Write returns because the
+	// underlying write is blocked. It writes a copy of b, since the caller
+	// may reuse b after Write returns.
+	b = append([]byte(nil), b...)
+	var n int
+	var err error
+	go func() {
+		n, err = cw.w.Write(b)
+		close(done)
+	}()
+
+	select {
+	case <-cw.ctx.Done():
+		return 0, cw.ctx.Err()
+	case <-done:
+		return n, err
+	}
+}
+
+// eintrReader retries reads that fail with EINTR before reading anything.
+// The runtime normally retries interrupted system calls, but pipe I/O
+// may still report EINTR on some platforms when a signal like SIGINT
+// arrives, which must not be mistaken for a failure to communicate.
+type eintrReader struct {
+	r io.Reader
+}
+
+func (er eintrReader) Read(b []byte) (int, error) {
+	for {
+		n, err := er.r.Read(b)
+		if n == 0 && errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		return n, err
+	}
+}
+
+// eintrWriter retries writes that fail with EINTR, continuing after any
+// bytes that were written. See eintrReader.
+type eintrWriter struct {
+	w io.Writer
+}
+
+func (ew eintrWriter) Write(b []byte) (int, error) {
+	written := 0
+	for written < len(b) {
+		n, err := ew.w.Write(b[written:])
+		written += n
+		if err != nil {
+			if errors.Is(err, syscall.EINTR) {
+				continue
+			}
+			return written, err
+		}
+	}
+	return written, nil
+}
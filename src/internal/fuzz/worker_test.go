@@ -5,25 +5,44 @@
 package fuzz
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"errors"
 	"flag"
 	"fmt"
 	"internal/race"
 	"io"
+	"math/rand"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"reflect"
+	"runtime"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 )
 
 var benchmarkWorkerFlag = flag.Bool("benchmarkworker", false, "")
 
+var killWorkerFlag = flag.Bool("killworker", false, "")
+
 func TestMain(m *testing.M) {
 	flag.Parse()
 	if *benchmarkWorkerFlag {
 		runBenchmarkWorker()
 		return
 	}
+	if *killWorkerFlag {
+		runKillWorker()
+		return
+	}
+	if name := os.Getenv(testFuzzFnEnv); name != "" {
+		runTestFuzzWorker(name)
+		return
+	}
 	os.Exit(m.Run())
 }
 
@@ -40,7 +59,7 @@ func BenchmarkWorkerFuzzOverhead(b *testing.B) {
 		workerComm: workerComm{memMu: make(chan *sharedMem, 1)},
 	}
 
-	mem, err := sharedMemTempFile(workerSharedMemSize)
+	mem, err := sharedMemTempFile("", workerSharedMemSize)
 	if err != nil {
 		b.Fatalf("failed to create temporary shared memory file: %s", err)
 	}
@@ -66,6 +85,271 @@ func BenchmarkWorkerFuzzOverhead(b *testing.B) {
 	}
 }
 
+func TestCallFuzzFnTimeout(t *testing.T) {
+	release := make(chan struct{})
+	ws := &workerServer{
+		fuzzFn: func(e CorpusEntry) error {
+			if e.Values[0].([]byte) == nil {
+				<-release
+			}
+			return nil
+		},
+		inputTimeout: 10 * time.Millisecond,
+	}
+	if err := ws.callFuzzFn(CorpusEntry{Values: []interface{}{[]byte(nil)}}); err == nil {
+		t.Error("callFuzzFn returned nil error for a call that didn't return")
+	}
+	if err := ws.callFuzzFn(CorpusEntry{Values: []interface{}{[]byte{}}}); err != nil {
+		t.Errorf("callFuzzFn returned unexpected error: %v", err)
+	}
+	close(release)
+}
+
+func TestCallFuzzFnTimeoutCopiesValues(t *testing.T) {
+	release := make(chan struct{})
+	got := make(chan []byte, 1)
+	ws := &workerServer{
+		fuzzFn: func(e CorpusEntry) error {
+			<-release
+			got <- e.Values[0].([]byte)
+			return nil
+		},
+		inputTimeout: 10 * time.Millisecond,
+	}
+	b := []byte("abc")
+	if err := ws.callFuzzFn(CorpusEntry{Values: []interface{}{b}}); err == nil {
+		t.Fatal("callFuzzFn returned nil error for a call that didn't return")
+	}
+	// Mutate the values in place for the next input, like workerServer.fuzz.
+	b[0] = 'x'
+	close(release)
+	if v := <-got; string(v) != "abc" {
+		t.Errorf("hung call saw %q; want %q", v, "abc")
+	}
+}
+
+func TestWorkerNoCoverageWhileHung(t *testing.T) {
+	registerTestFeedback.Do(func() {
+		RegisterFeedbackSource("test", theTestFeedback)
+	})
+	if err := setFeedbackSource("test"); err != nil {
+		t.Fatal(err)
+	}
+	defer setFeedbackSource("")
+
+	mem, err := sharedMemTempFile("", 1<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mem.Close()
+	ws := &workerServer{
+		fuzzFn: func(e CorpusEntry) error {
+			setTestCoverage(e.Values[0].([]byte))
+			return nil
+		},
+		workerComm:   workerComm{memMu: make(chan *sharedMem, 1)},
+		m:            &mutator{r: newPcgRandSeed(1, 1)},
+		coverageMask: make([]byte, len(coverageSnapshot)),
+	}
+	writeToMem([]interface{}{[]byte("abcd")}, mem)
+	ws.memMu <- mem
+
+	// Every input finds new coverage, but a hung call would be updating the
+	// counters at the same time.
+	ws.hungCalls = 1
+	if resp := ws.fuzz(context.Background(), fuzzArgs{Limit: 100}); resp.CoverageData != nil {
+		t.Error("worker reported coverage while a call was hung")
+	}
+	ws.hungCalls = 0
+	mem.header().count = 0
+	if resp := ws.fuzz(context.Background(), fuzzArgs{Limit: 100}); resp.CoverageData == nil {
+		t.Error("worker reported no coverage with no hung calls")
+	}
+}
+
+func TestWorkerParseError(t *testing.T) {
+	ws := &workerServer{
+		fuzzFn:     func(CorpusEntry) error { return nil },
+		workerComm: workerComm{memMu: make(chan *sharedMem, 1)},
+		m:          newMutator(),
+	}
+	mem, err := sharedMemTempFile("", 1<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mem.Close()
+	mem.setValue([]byte("not a corpus file"))
+	ws.memMu <- mem
+
+	if resp := ws.fuzz(context.Background(), fuzzArgs{Limit: 1}); resp.ParseErr == "" {
+		t.Errorf("fuzz: got no ParseErr for a malformed value; response: %+v", resp)
+	}
+	if resp := ws.minimize(context.Background(), minimizeArgs{Limit: 1}); resp.ParseErr == "" {
+		t.Errorf("minimize: got no ParseErr for a malformed value; response: %+v", resp)
+	}
+}
+
+func TestWorkerMaxInputSize(t *testing.T) {
+	const maxSize = 500
+	ws := &workerServer{
+		fuzzFn: func(e CorpusEntry) error {
+			if n := len(e.Values[0].([]byte)); n > maxSize {
+				t.Errorf("fuzz function called with %d-byte input; MaxInputSize is %d", n, maxSize)
+			}
+			return nil
+		},
+		workerComm: workerComm{memMu: make(chan *sharedMem, 1)},
+		m:          &mutator{r: newPcgRandSeed(1, 1)},
+	}
+	mem, err := sharedMemTempFile("", 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mem.Close()
+	writeToMem([]interface{}{make([]byte, 1000)}, mem)
+	ws.memMu <- mem
+
+	resp := ws.fuzz(context.Background(), fuzzArgs{Limit: 100, MaxInputSize: maxSize})
+	if resp.Count != 100 {
+		t.Errorf("got Count %d; want 100", resp.Count)
+	}
+	if resp.SkippedOversize == 0 {
+		t.Error("got SkippedOversize 0; want some inputs skipped")
+	}
+}
+
+func TestMutateWithinRestoresOversized(t *testing.T) {
+	const maxSize = 12
+	m := &mutator{r: newPcgRandSeed(1, 1)}
+	vals := []interface{}{make([]byte, 8), "abc"}
+	var skipped int
+	for i := 0; i < 1000; i++ {
+		before := marshalCorpusFile(vals...)
+		if m.mutateWithin(vals, 1<<10, maxSize) {
+			if n := valuesSize(vals); n > maxSize {
+				t.Fatalf("mutateWithin kept %d bytes of values; maxSize is %d", n, maxSize)
+			}
+			continue
+		}
+		skipped++
+		if after := marshalCorpusFile(vals...); !bytes.Equal(before, after) {
+			t.Fatalf("oversized mutation wasn't undone: got\n%s\nwant\n%s", after, before)
+		}
+	}
+	if skipped == 0 {
+		t.Error("no mutations were oversized; want some skipped")
+	}
+}
+
+func TestWorkerCrasherValue(t *testing.T) {
+	fn := func(e CorpusEntry) error {
+		if len(e.Values[0].([]byte)) > 4 {
+			return errors.New("too long")
+		}
+		return nil
+	}
+	ws := &workerServer{
+		fuzzFn:     fn,
+		workerComm: workerComm{memMu: make(chan *sharedMem, 1)},
+		m:          &mutator{r: newPcgRandSeed(1, 1)},
+	}
+	mem, err := sharedMemTempFile("", 1<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mem.Close()
+	writeToMem([]interface{}{[]byte("abcd")}, mem)
+	ws.memMu <- mem
+
+	resp := ws.fuzz(context.Background(), fuzzArgs{Limit: 1000})
+	if resp.Err == "" {
+		t.Fatal("fuzz function did not fail")
+	}
+	if !mem.header().crasherValue {
+		t.Fatal("crasher was not written to shared memory")
+	}
+	vals, err := unmarshalCorpusFile(mem.valueCopy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fn(CorpusEntry{Values: vals}); err == nil {
+		t.Errorf("value in shared memory %q does not cause an error", vals[0])
+	}
+}
+
+func TestWorkerMinimizeCrasherValue(t *testing.T) {
+	registerTestFeedback.Do(func() {
+		RegisterFeedbackSource("test", theTestFeedback)
+	})
+	if err := setFeedbackSource("test"); err != nil {
+		t.Fatal(err)
+	}
+	defer setFeedbackSource("")
+
+	// Minimizing "xyz" for the coverage of 'x' tries "x", which fails.
+	fn := func(e CorpusEntry) error {
+		b := e.Values[0].([]byte)
+		setTestCoverage(b)
+		if string(b) == "x" {
+			return errors.New("boom")
+		}
+		return nil
+	}
+	setTestCoverage([]byte("x"))
+	keepCoverage := append([]byte(nil), coverageSnapshot...)
+	ws := &workerServer{
+		fuzzFn:     fn,
+		workerComm: workerComm{memMu: make(chan *sharedMem, 1)},
+	}
+	mem, err := sharedMemTempFile("", 1<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mem.Close()
+	writeToMem([]interface{}{[]byte("xyz")}, mem)
+	ws.memMu <- mem
+
+	resp := ws.minimize(context.Background(), minimizeArgs{KeepCoverage: keepCoverage})
+	if resp.Err != "boom" {
+		t.Fatalf("got error %q; want the candidate that failed to be reported", resp.Err)
+	}
+	if !mem.header().crasherValue {
+		t.Fatal("crasher was not written to shared memory")
+	}
+	vals, err := unmarshalCorpusFile(mem.valueCopy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := vals[0].([]byte); string(got) != "x" {
+		t.Errorf("value in shared memory is %q; want the crasher %q", got, "x")
+	}
+}
+
+func TestWorkerInFuzzFn(t *testing.T) {
+	mem, err := sharedMemTempFile("", 1<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mem.Close()
+	ws := &workerServer{
+		fuzzFn: func(CorpusEntry) error {
+			if !mem.header().inFuzzFn {
+				t.Error("inFuzzFn not set while calling the fuzz function")
+			}
+			return nil
+		},
+		workerComm: workerComm{memMu: make(chan *sharedMem, 1)},
+		m:          newMutator(),
+	}
+	writeToMem([]interface{}{[]byte("abc")}, mem)
+	ws.memMu <- mem
+
+	ws.fuzz(context.Background(), fuzzArgs{Limit: 10})
+	if mem.header().inFuzzFn {
+		t.Error("inFuzzFn still set after fuzzing")
+	}
+}
+
 // BenchmarkWorkerPing acts as the coordinator and measures the time it takes
 // a worker to respond to N pings. This is a rough measure of our RPC latency.
 func BenchmarkWorkerPing(b *testing.B) {
@@ -75,7 +359,7 @@ func BenchmarkWorkerPing(b *testing.B) {
 	b.SetParallelism(1)
 	w := newWorkerForTest(b)
 	for i := 0; i < b.N; i++ {
-		if err := w.client.ping(context.Background()); err != nil {
+		if _, err := w.client.ping(context.Background(), pingArgs{}); err != nil {
 			b.Fatal(err)
 		}
 	}
@@ -155,3 +439,707 @@ func runBenchmarkWorker() {
 		panic(err)
 	}
 }
+
+func TestTailBuffer(t *testing.T) {
+	b := &tailBuffer{max: 8}
+	for _, s := range []string{"abc", "defgh", "ij", "0123456789"} {
+		if n, err := b.Write([]byte(s)); n != len(s) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v; want %d, nil", s, n, err, len(s))
+		}
+	}
+	if got, want := string(b.Bytes()), "23456789"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+	b.Write([]byte("xy"))
+	if got, want := string(b.Bytes()), "456789xy"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestSaveRecentInputs(t *testing.T) {
+	c, err := newCoordinator(CoordinateFuzzingOpts{
+		Types: []reflect.Type{reflect.TypeOf([]byte(nil))},
+		Log:   io.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := newWorker(c, "", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.cleanup()
+
+	// Simulate a worker process that ran 20 inputs before terminating.
+	mem := <-w.memMu
+	vals := []interface{}{[]byte("abc")}
+	writeToMem(vals, mem)
+	m := newMutator()
+	m.r.save(&mem.header().randState, &mem.header().randInc)
+	mem.header().count = 20
+	var want [][]byte
+	for i := 0; i < 20; i++ {
+		m.mutate(vals, cap(mem.valueRef()))
+		if i >= 16 {
+			want = append(want, marshalCorpusFile(vals...))
+		}
+	}
+	w.memMu <- mem
+
+	dir, err := w.saveRecentInputs(fuzzArgs{}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for i, data := range want {
+		got, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("%02d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("input %d: got %q, want %q", i, got, data)
+		}
+	}
+}
+
+// eintrOnce fails its first read or write with EINTR, then behaves like buf.
+type eintrOnce struct {
+	buf         bytes.Buffer
+	interrupted bool
+}
+
+func (e *eintrOnce) Read(b []byte) (int, error) {
+	if !e.interrupted {
+		e.interrupted = true
+		return 0, &os.PathError{Op: "read", Path: "fuzz_out", Err: syscall.EINTR}
+	}
+	return e.buf.Read(b)
+}
+
+func (e *eintrOnce) Write(b []byte) (int, error) {
+	if !e.interrupted {
+		e.interrupted = true
+		return 0, &os.PathError{Op: "write", Path: "fuzz_in", Err: syscall.EINTR}
+	}
+	return e.buf.Write(b)
+}
+
+func TestEINTRRetry(t *testing.T) {
+	w := &eintrOnce{}
+	if n, err := (eintrWriter{w}).Write([]byte("hello")); n != 5 || err != nil {
+		t.Fatalf("Write: got %d, %v; want 5, nil", n, err)
+	}
+	r := &eintrOnce{buf: w.buf}
+	b := make([]byte, 5)
+	if n, err := (eintrReader{r}).Read(b); n != 5 || err != nil || string(b) != "hello" {
+		t.Errorf("Read: got %d, %v, %q; want 5, nil, \"hello\"", n, err, b)
+	}
+}
+
+func TestProcessRSS(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("processRSS is only implemented on linux")
+	}
+	rss, err := processRSS(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rss <= 0 {
+		t.Errorf("got RSS %d; want a positive number of bytes", rss)
+	}
+}
+
+func TestRandomRuntimeEnv(t *testing.T) {
+	c, err := newCoordinator(CoordinateFuzzingOpts{
+		Types: []reflect.Type{reflect.TypeOf([]byte(nil))},
+		Log:   io.Discard,
+		RunID: "test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &worker{coordinator: c, env: []string{"GODEBUG=fuzzseed=1"}}
+	env := w.randomRuntimeEnv()
+	if len(env) != 2 || !strings.HasPrefix(env[0], "GOMAXPROCS=") || !strings.HasPrefix(env[1], "GODEBUG=fuzzseed=1,gcstoptheworld=") {
+		t.Errorf("unexpected environment: %q", env)
+	}
+	if again := w.randomRuntimeEnv(); !equalEnv(env, again) {
+		t.Errorf("got %q, then %q for the same RunID", env, again)
+	}
+}
+
+func TestFlakyRateExceeded(t *testing.T) {
+	c := &coordinator{opts: CoordinateFuzzingOpts{FlakyWorkerRate: 0.5}}
+	for _, tc := range []struct {
+		deflakes, flakes int64
+		want             bool
+	}{
+		{flakyWorkerMinDeflakes - 1, flakyWorkerMinDeflakes - 1, false},
+		{flakyWorkerMinDeflakes, flakyWorkerMinDeflakes / 2, false},
+		{flakyWorkerMinDeflakes, flakyWorkerMinDeflakes/2 + 1, true},
+	} {
+		w := &worker{coordinator: c, deflakes: tc.deflakes, flakes: tc.flakes}
+		if got := w.flakyRateExceeded(); got != tc.want {
+			t.Errorf("%d of %d deflakes failed: got %v, want %v", tc.flakes, tc.deflakes, got, tc.want)
+		}
+	}
+}
+
+func TestWorkerSummary(t *testing.T) {
+	c := &coordinator{opts: CoordinateFuzzingOpts{PerWorkerSummary: true}, startTime: time.Now().Add(-10 * time.Second)}
+	w0 := &worker{coordinator: c, id: 0, restarts: 2}
+	w1 := &worker{coordinator: c, id: 1}
+	w0.recordResult(fuzzResult{count: 100, totalDuration: time.Second, crasherMsg: "boom"}, true)
+	w0.recordResult(fuzzResult{count: 50, totalDuration: time.Second / 2, crasherMsg: "boom"}, false)
+	w1.recordResult(fuzzResult{count: 10, totalDuration: time.Second}, true)
+
+	s := c.summary([]*worker{w0, w1})
+	if len(s.Workers) != 2 {
+		t.Fatalf("got %d worker summaries; want 2", len(s.Workers))
+	}
+	if got := s.Workers[0]; got.Execs != 150 || got.Crashes != 1 || got.Restarts != 2 || got.AvgInputDuration != 10*time.Millisecond || got.ExecsPerSec <= 0 {
+		t.Errorf("got worker 0 summary %+v", got)
+	}
+	if got := s.Workers[1]; got.ID != 1 || got.Execs != 10 || got.AvgInputDuration != 100*time.Millisecond {
+		t.Errorf("got worker 1 summary %+v", got)
+	}
+
+	c.opts.PerWorkerSummary = false
+	if s := c.summary([]*worker{w0, w1}); s.Workers != nil {
+		t.Errorf("got worker summaries %v without PerWorkerSummary", s.Workers)
+	}
+}
+
+func TestSharedMemDir(t *testing.T) {
+	dir := t.TempDir()
+	mem, err := sharedMemTempFile(dir, 1<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mem.Close()
+	if got := filepath.Dir(mem.f.Name()); got != dir {
+		t.Errorf("shared memory file created in %s; want %s", got, dir)
+	}
+
+	opts := CoordinateFuzzingOpts{
+		Types:        []reflect.Type{reflect.TypeOf([]byte(nil))},
+		Log:          io.Discard,
+		SharedMemDir: filepath.Join(dir, "missing"),
+	}
+	if _, err := newCoordinator(opts); err == nil {
+		t.Error("newCoordinator succeeded with a missing SharedMemDir")
+	}
+}
+
+// runKillWorker runs a worker process whose fuzz function kills the process
+// if its input contains '!'.
+func runKillWorker() {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	fn := func(e CorpusEntry) error {
+		p, _ := os.FindProcess(os.Getpid())
+		switch b := e.Values[0].([]byte); {
+		case bytes.Contains(b, []byte("!")):
+			p.Kill()
+			select {}
+		case bytes.Contains(b, []byte("#")):
+			// Like an unrecovered panic.
+			os.Exit(2)
+		case bytes.Contains(b, []byte("?")):
+			// Like a terminal closing, not a crash caused by the input.
+			p.Signal(syscall.SIGHUP)
+			select {}
+		}
+		return nil
+	}
+	if err := RunFuzzWorker(ctx, fn); err != nil && err != ctx.Err() {
+		panic(err)
+	}
+}
+
+// testFuzzFnEnv is set in the environment of worker processes started by
+// coordinateForTest to the name of the fuzz function in testFuzzFns they run.
+const testFuzzFnEnv = "GO_FUZZ_TEST_FUZZ_FN"
+
+// testFuzzFns are the fuzz functions worker processes started by
+// coordinateForTest may run. Each takes a single []byte and reports a
+// coverage bit for each byte in it, byte value modulo 32, through the "test"
+// FeedbackSource.
+var testFuzzFns = map[string]func(CorpusEntry) error{
+	// cover never fails.
+	"cover": func(e CorpusEntry) error {
+		setTestCoverage(e.Values[0].([]byte))
+		return nil
+	},
+	// crash fails for inputs containing '!'.
+	"crash": func(e CorpusEntry) error {
+		b := e.Values[0].([]byte)
+		setTestCoverage(b)
+		if bytes.Contains(b, []byte("!")) {
+			return fmt.Errorf("input contains '!'")
+		}
+		return nil
+	},
+	// feedback reports the number of 'a' bytes in the input as its score,
+	// without any coverage.
+	"feedback": func(e CorpusEntry) error {
+		setTestCoverage(nil)
+		ReportFeedback(int64(bytes.Count(e.Values[0].([]byte), []byte("a"))))
+		return nil
+	},
+	// exit makes the worker process exit with status 2 for inputs
+	// containing '!'.
+	"exit": func(e CorpusEntry) error {
+		if bytes.Contains(e.Values[0].([]byte), []byte("!")) {
+			os.Exit(2)
+		}
+		return nil
+	},
+	// closeout closes fuzz_out for inputs containing '!', so the worker
+	// process stops responding, but keeps running.
+	"closeout": func(e CorpusEntry) error {
+		if bytes.Contains(e.Values[0].([]byte), []byte("!")) {
+			os.NewFile(4, "fuzz_out").Close()
+			select {}
+		}
+		return nil
+	},
+	// randfeedback reports a random score, without any coverage.
+	"randfeedback": func(e CorpusEntry) error {
+		setTestCoverage(nil)
+		ReportFeedback(rand.Int63())
+		return nil
+	},
+	// flakyexit reports coverage only on every other call, so new coverage
+	// never survives being run again to deflake it, and fuzzing continues.
+	// It makes the worker process exit with status 2 for inputs containing
+	// '!', like exit.
+	"flakyexit": func(e CorpusEntry) error {
+		b := e.Values[0].([]byte)
+		flakyExitCalls++
+		if flakyExitCalls%2 == 0 {
+			b = nil
+		}
+		setTestCoverage(b)
+		if bytes.Contains(e.Values[0].([]byte), []byte("!")) {
+			os.Exit(2)
+		}
+		return nil
+	},
+}
+
+// flakyExitCalls is the number of calls to the "flakyexit" fuzz function.
+var flakyExitCalls int
+
+// setTestCoverage sets the coverage snapshot for an input containing b.
+func setTestCoverage(b []byte) {
+	ResetCoverage()
+	for _, c := range b {
+		theTestFeedback.cur[c/8%4] |= 1 << (c % 8)
+	}
+	SnapshotCoverage()
+}
+
+// runTestFuzzWorker runs a worker process for coordinateForTest.
+func runTestFuzzWorker(name string) {
+	registerTestFeedback.Do(func() {
+		RegisterFeedbackSource("test", theTestFeedback)
+	})
+	fn, ok := testFuzzFns[name]
+	if !ok {
+		panic(fmt.Sprintf("unknown fuzz function %q", name))
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	if err := RunFuzzWorker(ctx, fn); err != nil && err != ctx.Err() {
+		panic(err)
+	}
+}
+
+func TestWorkerClosesFuzzOut(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("worker processes don't communicate through inherited file descriptors on Windows")
+	}
+	seed := []CorpusEntry{
+		{Path: "seed#0", Data: marshalCorpusFile([]byte("seed")), Values: []interface{}{[]byte("seed")}, IsSeed: true},
+	}
+
+	// A worker that closes fuzz_out but keeps running wasn't crashed by its
+	// input, so no crasher is recorded.
+	dir := t.TempDir()
+	err := coordinateForTest(t, "closeout", CoordinateFuzzingOpts{Seed: seed, CorpusDir: dir})
+	if err == nil || !strings.Contains(err.Error(), "stopped responding without terminating") {
+		t.Errorf("got error %v; want the worker to have stopped responding", err)
+	}
+	if n := countFiles(t, dir); n != 0 {
+		t.Errorf("%d crashers written for a worker that closed fuzz_out", n)
+	}
+
+	// A worker that terminates while testing an input was crashed by it.
+	dir = t.TempDir()
+	err = coordinateForTest(t, "exit", CoordinateFuzzingOpts{Seed: seed, CorpusDir: dir})
+	if err == nil || !strings.Contains(err.Error(), "terminated unexpectedly") {
+		t.Errorf("got error %v; want the worker to have terminated unexpectedly", err)
+	}
+	if n := countFiles(t, dir); n != 1 {
+		t.Errorf("%d crashers written for a worker that terminated; want 1", n)
+	}
+}
+
+func TestWorkerExitAfterDeflakes(t *testing.T) {
+	// The worker process exits without responding, so the coordinator
+	// reconstructs the crasher knowing only how many of the calls before it
+	// deflaked values instead of mutating them.
+	seed := []CorpusEntry{
+		{Path: "seed#0", Data: marshalCorpusFile([]byte("seed")), Values: []interface{}{[]byte("seed")}, IsSeed: true},
+	}
+	for i := 0; i < 5; i++ {
+		var sum Summary
+		err := coordinateForTest(t, "flakyexit", CoordinateFuzzingOpts{
+			Seed:     seed,
+			OnFinish: func(s Summary) { sum = s },
+		})
+		if err == nil || len(sum.Crashers) != 1 {
+			t.Fatalf("got error %v and %d crashers; want the crash recorded", err, len(sum.Crashers))
+		}
+		data, err := os.ReadFile(sum.Crashers[0].Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vals, err := unmarshalCorpusFile(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := vals[0].([]byte); !bytes.Contains(b, []byte("!")) {
+			t.Fatalf("recorded crasher %q doesn't make the worker exit", b)
+		}
+	}
+}
+
+func TestMinimizeSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("worker processes aren't terminated by signals on Windows")
+	}
+	c, err := newCoordinator(CoordinateFuzzingOpts{
+		Types: []reflect.Type{reflect.TypeOf([]byte(nil))},
+		Log:   io.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := append(os.Args[1:], "-killworker")
+	w, err := newWorker(c, "", os.Args[0], args, os.Environ())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.cleanup()
+	defer func() {
+		if w.isRunning() {
+			w.stop()
+		}
+	}()
+
+	input := fuzzMinimizeInput{
+		entry:       CorpusEntry{Data: marshalCorpusFile([]byte("abc!def"))},
+		crasherMsg:  "fuzzing process terminated unexpectedly",
+		crashSignal: os.Kill,
+	}
+	result, err := w.minimizeSignal(context.Background(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.entry.Values[0].([]byte); string(got) != "!" {
+		t.Errorf("got minimized input %q; want %q", got, "!")
+	}
+
+	// An input that terminates the worker with a different signal, or not at
+	// all, can't be minimized.
+	input.crashSignal = os.Interrupt
+	if _, err := w.minimizeSignal(context.Background(), input); err == nil {
+		t.Error("minimized input that doesn't terminate the worker with the expected signal")
+	}
+}
+
+func TestVerifyMinimized(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("worker processes aren't terminated by signals on Windows")
+	}
+	c, err := newCoordinator(CoordinateFuzzingOpts{
+		Types: []reflect.Type{reflect.TypeOf([]byte(nil))},
+		Log:   io.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := append(os.Args[1:], "-killworker")
+	w, err := newWorker(c, "", os.Args[0], args, os.Environ())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.cleanup()
+	defer func() {
+		if w.isRunning() {
+			w.stop()
+		}
+	}()
+
+	input := fuzzMinimizeInput{
+		entry:      CorpusEntry{Path: "orig", Data: marshalCorpusFile([]byte("abc#def"))},
+		crasherMsg: "fuzzing process terminated unexpectedly",
+	}
+	min := fuzzResult{entry: CorpusEntry{Path: "min", Data: marshalCorpusFile([]byte("#"))}}
+	if err := w.verifyMinimized(context.Background(), input, &min); err != nil {
+		t.Fatal(err)
+	}
+	if min.unverified || min.entry.Path != "min" {
+		t.Errorf("crasher that reproduces was replaced by %s", min.entry.Path)
+	}
+
+	min = fuzzResult{entry: CorpusEntry{Path: "min", Data: marshalCorpusFile([]byte("abc"))}}
+	if err := w.verifyMinimized(context.Background(), input, &min); err != nil {
+		t.Fatal(err)
+	}
+	if !min.unverified || min.entry.Path != "orig" {
+		t.Errorf("crasher that doesn't reproduce was kept as %s", min.entry.Path)
+	}
+
+	// A signal the input didn't cause doesn't verify it.
+	min = fuzzResult{entry: CorpusEntry{Path: "min", Data: marshalCorpusFile([]byte("?"))}}
+	if err := w.verifyMinimized(context.Background(), input, &min); err != nil {
+		t.Fatal(err)
+	}
+	if !min.unverified || min.entry.Path != "orig" {
+		t.Errorf("crasher whose process was terminated by SIGHUP was kept as %s", min.entry.Path)
+	}
+}
+
+func TestMeasureReproduce(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("worker processes aren't terminated by signals on Windows")
+	}
+	c, err := newCoordinator(CoordinateFuzzingOpts{
+		Types:                []reflect.Type{reflect.TypeOf([]byte(nil))},
+		Log:                  io.Discard,
+		CrasherReproduceRuns: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := append(os.Args[1:], "-killworker")
+	w, err := newWorker(c, "", os.Args[0], args, os.Environ())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.cleanup()
+	defer func() {
+		if w.isRunning() {
+			w.stop()
+		}
+	}()
+
+	for _, tc := range []struct {
+		data             []byte
+		reproduced, runs int
+	}{
+		{[]byte("#"), 3, 3},
+		{[]byte("abc"), 0, 3},
+		// The process is terminated by a signal the input didn't cause, so
+		// no run counts.
+		{[]byte("?"), 0, 0},
+	} {
+		result := fuzzResult{entry: CorpusEntry{Data: marshalCorpusFile(tc.data)}}
+		if err := w.measureReproduce(context.Background(), &result); err != nil {
+			t.Fatal(err)
+		}
+		if result.reproduceRuns != tc.runs || result.reproduced != tc.reproduced {
+			t.Errorf("%q: reproduced in %d of %d runs; want %d of %d", tc.data, result.reproduced, result.reproduceRuns, tc.reproduced, tc.runs)
+		}
+	}
+}
+
+func TestWorkerMaxOpenFiles(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the limit above which setting it fails is only known on Linux")
+	}
+	for _, tc := range []struct {
+		limit int
+		ok    bool
+	}{
+		{256, true},
+		// More than the kernel allows any process to open.
+		{1 << 40, false},
+	} {
+		c, err := newCoordinator(CoordinateFuzzingOpts{
+			Types:              []reflect.Type{reflect.TypeOf([]byte(nil))},
+			Log:                io.Discard,
+			WorkerMaxOpenFiles: tc.limit,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		args := append(os.Args[1:], "-killworker")
+		w, err := newWorker(c, "", os.Args[0], args, os.Environ())
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = w.startAndPing(context.Background())
+		if w.isRunning() {
+			w.stop()
+		}
+		w.cleanup()
+		if tc.ok && err != nil {
+			t.Errorf("limit %d: %v", tc.limit, err)
+		} else if !tc.ok && (err == nil || !strings.Contains(err.Error(), "open file limit")) {
+			t.Errorf("limit %d: got error %v; want an error setting the open file limit", tc.limit, err)
+		}
+	}
+}
+
+func TestWorkerBinaryChanged(t *testing.T) {
+	bin := filepath.Join(t.TempDir(), "fuzz.test")
+	if err := os.WriteFile(bin, []byte("old build"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	c := &coordinator{}
+	w, err := newWorker(c, "", bin, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.cleanup()
+	if err := w.checkBinary(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bin, []byte("new build!"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.start(); err == nil || !strings.Contains(err.Error(), "changed since fuzzing started") {
+		t.Errorf("starting a worker after the binary changed: got error %v; want a changed binary error", err)
+	}
+}
+
+func TestWorkerClientCloseTimeout(t *testing.T) {
+	inR, inW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inR.Close()
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// outW is never closed, like the end of fuzz_out held by a wedged worker
+	// process.
+	defer outW.Close()
+
+	wc := &workerClient{workerComm: workerComm{fuzzIn: inW, fuzzOut: outR}}
+	done := make(chan error, 1)
+	go func() { done <- wc.Close() }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Close succeeded without the worker closing fuzz_out")
+		}
+	case <-time.After(closeDrainTimeout + 10*time.Second):
+		t.Fatal("Close did not return")
+	}
+}
+
+func TestPingMemHash(t *testing.T) {
+	mem, err := sharedMemTempFile("", 1<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mem.Close()
+	ws := &workerServer{
+		workerComm: workerComm{memMu: make(chan *sharedMem, 1)},
+		m:          newMutator(),
+	}
+	ws.memMu <- mem
+	defer setFeedbackSource("")
+
+	for _, v := range []string{"shared memory check 1", "shared memory check 2"} {
+		mem.setValue([]byte(v))
+		want := sha256.Sum256([]byte(v))
+		if resp := ws.ping(context.Background(), pingArgs{}); !bytes.Equal(resp.MemHash, want[:]) {
+			t.Errorf("ping returned hash %x of shared memory holding %q; want %x", resp.MemHash, v, want)
+		}
+	}
+}
+
+func TestWorkerClientMemWait(t *testing.T) {
+	var stats memWaitStats
+	wc := &workerClient{
+		workerComm: workerComm{memMu: make(chan *sharedMem, 1)},
+		memStats:   &stats,
+	}
+	const delay = 10 * time.Millisecond
+	go func() {
+		time.Sleep(delay)
+		wc.memMu <- nil
+	}()
+	if _, ok := wc.acquireMem(); !ok {
+		t.Fatal("acquireMem: memMu closed")
+	}
+	wc.memMu <- nil
+	if _, ok := wc.acquireMem(); !ok {
+		t.Fatal("acquireMem: memMu closed")
+	}
+	if stats.acquisitions != 2 {
+		t.Errorf("got %d acquisitions; want 2", stats.acquisitions)
+	}
+	if stats.wait < delay {
+		t.Errorf("got wait %v; want at least %v", stats.wait, delay)
+	}
+}
+
+func TestContextWriter(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	// Nothing reads from the pipe, so a write larger than its buffer blocks.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	cw := &contextWriter{ctx: ctx, w: w}
+	if _, err := cw.Write(make([]byte, 1<<20)); err != context.DeadlineExceeded {
+		t.Errorf("got error %v; want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestMinimizeCheckpoint(t *testing.T) {
+	c, err := newCoordinator(CoordinateFuzzingOpts{
+		Types: []reflect.Type{reflect.TypeOf([]byte(nil))},
+		Log:   io.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := newWorker(c, "", os.Args[0], nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.cleanup()
+
+	input := CorpusEntry{Parent: "p", Data: marshalCorpusFile([]byte("abcdef"))}
+	setMem := func(b []byte) {
+		mem := <-w.memMu
+		mem.setValue(b)
+		w.memMu <- mem
+	}
+	setMem(input.Data)
+	if _, ok := w.minimizeCheckpoint(input); ok {
+		t.Error("found a checkpoint when shared memory holds the input")
+	}
+	setMem([]byte("go test fuzz v1\n[]byte(\"ab"))
+	if _, ok := w.minimizeCheckpoint(input); ok {
+		t.Error("found a checkpoint when shared memory holds a partly written value")
+	}
+	setMem(marshalCorpusFile([]byte("ab")))
+	e, ok := w.minimizeCheckpoint(input)
+	if !ok || e.Parent != "p" || string(e.Values[0].([]byte)) != "ab" {
+		t.Errorf("got checkpoint %+v, %v; want the value in shared memory", e, ok)
+	}
+}
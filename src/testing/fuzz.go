@@ -403,7 +403,8 @@ func (f *F) Fuzz(ff interface{}) {
 				creator: pc[:n],
 				chatty:  f.chatty,
 			},
-			context: f.testContext,
+			context:     f.testContext,
+			fuzzContext: f.fuzzContext,
 		}
 		t.w = indenter{&t.common}
 		if t.chatty != nil {
@@ -485,6 +486,78 @@ func (f *F) Fuzz(ff interface{}) {
 	}
 }
 
+// FuzzDiff is like Fuzz, but it compares two implementations of the same
+// thing with each input, which is called differential fuzzing. fn and ref
+// must be functions of the same type, which take the same arguments as a
+// function passed to Fuzz and return a result and an error. For example:
+//
+//     f.FuzzDiff(
+//         func(t *testing.T, b []byte) (*Value, error) { return Parse(b) },
+//         func(t *testing.T, b []byte) (*Value, error) { return oldParse(b) },
+//     )
+//
+// ref is usually a reference implementation, like a simpler or older version
+// of fn, or one written from a specification. The implementations agree on
+// an input if both return an error, or if neither does and their results are
+// equal according to reflect.DeepEqual. Otherwise, the input fails, and the
+// error shows what each implementation returned.
+//
+// Both functions are called by the fuzz function, so the coverage that
+// guides fuzzing is the union of their coverage, and a failure or panic in
+// either one is reported as usual.
+func (f *F) FuzzDiff(fn, ref interface{}) {
+	f.Helper()
+	fnVal, refVal := reflect.ValueOf(fn), reflect.ValueOf(ref)
+	fnType := fnVal.Type()
+	if fnType.Kind() != reflect.Func || refVal.Type() != fnType {
+		panic("testing: F.FuzzDiff must receive two functions of the same type")
+	}
+	if fnType.NumOut() != 2 || fnType.Out(1) != reflect.TypeOf((*error)(nil)).Elem() {
+		panic("testing: F.FuzzDiff functions must return a result and an error")
+	}
+	var in []reflect.Type
+	for i := 0; i < fnType.NumIn(); i++ {
+		in = append(in, fnType.In(i))
+	}
+	ff := reflect.MakeFunc(reflect.FuncOf(in, nil, false), func(args []reflect.Value) []reflect.Value {
+		t := args[0].Interface().(*T)
+		t.Helper()
+		got, want := fnVal.Call(args), refVal.Call(args)
+		out, err := got[0].Interface(), got[1].Interface()
+		refOut, refErr := want[0].Interface(), want[1].Interface()
+		switch {
+		case err != nil && refErr != nil:
+		case err != nil:
+			t.Errorf("implementations disagree: fuzz function returned error %q, reference returned %#v", err, refOut)
+		case refErr != nil:
+			t.Errorf("implementations disagree: fuzz function returned %#v, reference returned error %q", out, refErr)
+		case !reflect.DeepEqual(out, refOut):
+			t.Errorf("implementations disagree: fuzz function returned %#v, reference returned %#v", out, refOut)
+		}
+		return nil
+	})
+	f.Fuzz(ff.Interface())
+}
+
+// ReportFeedback reports a score for the input the fuzz function that t was
+// passed to is testing. While fuzzing, an input that produces a higher score
+// than any input before it is added to the corpus and mutated further, even if
+// it doesn't expand coverage. This can direct fuzzing toward a goal, like
+// making a parser reach a target state: report how close the input got. If
+// ReportFeedback is called more than once for an input, the highest score is
+// used.
+//
+// Like coverage, the score should depend only on the input: an input is run
+// again before it's added, and dropped if it doesn't produce the same score.
+// ReportFeedback has no effect when not fuzzing, or if t isn't running a fuzz
+// function or one of its subtests.
+func (t *T) ReportFeedback(score int64) {
+	if t.fuzzContext == nil || t.fuzzContext.mode != fuzzWorker {
+		return
+	}
+	t.fuzzContext.deps.ReportFeedback(score)
+}
+
 func (f *F) report() {
 	if *isFuzzWorker || f.parent == nil {
 		return
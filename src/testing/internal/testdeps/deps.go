@@ -159,6 +159,7 @@ func (TestDeps) CoordinateFuzzing(
 		Types:           types,
 		CorpusDir:       corpusDir,
 		CacheDir:        cacheDir,
+		FailOnSeedCrash: true,
 	})
 	if err == ctx.Err() {
 		return nil
@@ -197,3 +198,7 @@ func (TestDeps) ResetCoverage() {
 func (TestDeps) SnapshotCoverage() {
 	fuzz.SnapshotCoverage()
 }
+
+func (TestDeps) ReportFeedback(score int64) {
+	fuzz.ReportFeedback(score)
+}
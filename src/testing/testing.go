@@ -768,6 +768,10 @@ type T struct {
 	isParallel bool
 	isEnvSet   bool
 	context    *testContext // For running tests and subtests.
+
+	// fuzzContext is set if the test runs a fuzz function, or is a subtest
+	// of one. See T.ReportFeedback.
+	fuzzContext *fuzzContext
 }
 
 func (c *common) private() {}
@@ -1409,7 +1413,8 @@ func (t *T) Run(name string, f func(t *T)) bool {
 			creator: pc[:n],
 			chatty:  t.chatty,
 		},
-		context: t.context,
+		context:     t.context,
+		fuzzContext: t.fuzzContext,
 	}
 	t.w = indenter{&t.common}
 
@@ -1524,6 +1529,7 @@ func (f matchStringOnly) ReadCorpus(string, []reflect.Type) ([]corpusEntry, erro
 func (f matchStringOnly) CheckCorpus([]interface{}, []reflect.Type) error { return nil }
 func (f matchStringOnly) ResetCoverage()                                  {}
 func (f matchStringOnly) SnapshotCoverage()                               {}
+func (f matchStringOnly) ReportFeedback(int64)                            {}
 
 // Main is an internal function, part of the implementation of the "go test" command.
 // It was exported because it is cross-package and predates "internal" packages.
@@ -1572,6 +1578,7 @@ type testDeps interface {
 	CheckCorpus([]interface{}, []reflect.Type) error
 	ResetCoverage()
 	SnapshotCoverage()
+	ReportFeedback(int64)
 }
 
 // MainStart is meant for use by tests generated by 'go test'.